@@ -20,7 +20,9 @@ import (
 	"net/http"
 
 	"github.com/88250/gulu"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -55,3 +57,192 @@ func chatGPTWithAction(c *gin.Context) {
 	action := arg["action"].(string)
 	ret.Data = model.ChatGPTWithAction(ids, action)
 }
+
+func askAI(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	question := arg["question"].(string)
+	var boxes []string
+	if notebooksArg, ok := arg["notebooks"].([]interface{}); ok {
+		for _, notebook := range notebooksArg {
+			boxes = append(boxes, notebook.(string))
+		}
+	}
+
+	result, err := model.AskAI(question, boxes)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = result // 请求中途失败时返回不完整的回答
+		return
+	}
+	ret.Data = result
+}
+
+// chatGPTStream 以 SSE 方式返回 AI 对话的增量内容
+//
+// 事件类型：
+//   - delta: 增量内容
+//   - done: 对话完成，数据为 {conversationID, content, incomplete}
+//   - error: 请求失败，数据为错误信息。请求中途失败时会先发送 incomplete 为 true 的 done 事件
+func chatGPTStream(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		c.JSON(http.StatusOK, ret)
+		return
+	}
+
+	var conversationID, boxID string
+	if nil != arg["conversationID"] {
+		conversationID = arg["conversationID"].(string)
+	}
+	if nil != arg["notebook"] {
+		boxID = arg["notebook"].(string)
+	}
+	msg := arg["msg"].(string)
+	var ids []string
+	if idsArg, ok := arg["ids"].([]interface{}); ok {
+		for _, id := range idsArg {
+			ids = append(ids, id.(string))
+		}
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("Transfer-Encoding", "chunked")
+
+	defer UnifiedSSE.WaitGroup.Done()
+	UnifiedSSE.WaitGroup.Add(1)
+
+	clientGone := c.Request.Context().Done()
+	retConversationID, content, err := model.ChatGPTStream(conversationID, boxID, msg, ids, func(delta string) bool {
+		select {
+		case <-clientGone:
+			return false
+		default:
+		}
+
+		UnifiedSSE.SSEvent(c, &sse.Event{Event: "delta", Data: delta})
+		c.Writer.Flush()
+		return true
+	})
+	if "" != retConversationID {
+		UnifiedSSE.SSEvent(c, &sse.Event{Event: "done", Data: map[string]interface{}{
+			"conversationID": retConversationID,
+			"content":        content,
+			"incomplete":     nil != err,
+		}})
+		c.Writer.Flush()
+	}
+	if err != nil {
+		UnifiedSSE.SSEvent(c, &sse.Event{Event: "error", Data: err.Error()})
+		c.Writer.Flush()
+	}
+}
+
+func getAIConversation(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	messages, err := model.GetAIConversation(id)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = messages
+}
+
+func getAIProfiles(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	profiles, actions := model.GetAIProfiles()
+	ret.Data = map[string]interface{}{
+		"profiles": profiles,
+		"actions":  actions,
+	}
+}
+
+func setAIProfile(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	profile := &conf.AIProfile{}
+	if err = gulu.JSON.UnmarshalJSON(param, profile); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	profile, err = model.SetAIProfile(profile)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = profile
+}
+
+func removeAIProfile(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	model.RemoveAIProfile(id)
+}
+
+func setAIActionProfile(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	action := arg["action"].(string)
+	var profileID string
+	if nil != arg["profile"] {
+		profileID = arg["profile"].(string)
+	}
+	if err := model.SetAIActionProfile(action, profileID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+}
@@ -396,6 +396,113 @@ func getUnusedAssets(c *gin.Context) {
 	}
 }
 
+func getAssetMeta(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	p := arg["path"].(string)
+	ret.Data = map[string]interface{}{
+		"meta": model.GetAssetMeta(p),
+	}
+}
+
+func reindexAssetMeta(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	model.IndexAssetMetaJob()
+}
+
+func optimizeImages(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var paths []string
+	if nil != arg["paths"] {
+		for _, p := range arg["paths"].([]interface{}) {
+			paths = append(paths, p.(string))
+		}
+	}
+
+	// 未传入的参数使用图片配置
+	opt := *model.Conf.Image
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(param, &opt); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	if 1 > opt.Quality || 100 < opt.Quality {
+		opt.Quality = model.Conf.Image.Quality
+	}
+
+	results, saved, err := model.OptimizeImages(paths, &opt)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"results": results,
+		"saved":   saved,
+	}
+}
+
+func getDuplicateAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"duplicateAssets": model.GetDuplicateAssets(),
+	}
+}
+
+func dedupeAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	hash := arg["hash"].(string)
+	var keep string
+	if nil != arg["keep"] {
+		keep = arg["keep"].(string)
+	}
+
+	removed, err := model.DedupeAssets(hash, keep)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	if nil == removed {
+		removed = []string{}
+	}
+	ret.Data = map[string]interface{}{
+		"removed": removed,
+	}
+}
+
 func getMissingAssets(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -729,8 +729,15 @@ func updateBlock(c *gin.Context) {
 		}
 	}
 
+	if precondition := txPrecondition(id, arg); nil != precondition {
+		transactions[0].Preconditions = []*model.TxPrecondition{precondition}
+	}
+
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
+	if txConflicted(transactions, ret) {
+		return
+	}
 
 	ret.Data = transactions
 	broadcastTransactions(transactions)
@@ -822,6 +829,7 @@ func batchUpdateBlock(c *gin.Context) {
 	}
 
 	var blocks []*updateBlockArg
+	tx := &model.Transaction{}
 	luteEngine := util.NewLute()
 	for _, blockArg := range blocksArg {
 		blockMap := blockArg.(map[string]interface{})
@@ -862,10 +870,12 @@ func batchUpdateBlock(c *gin.Context) {
 			Block:    block,
 			Tree:     tree,
 		})
+		if precondition := txPrecondition(id, blockMap); nil != precondition {
+			tx.Preconditions = append(tx.Preconditions, precondition)
+		}
 	}
 
 	var ops []*model.Operation
-	tx := &model.Transaction{}
 	transactions := []*model.Transaction{tx}
 	for _, upBlock := range blocks {
 		block := upBlock.Block
@@ -912,6 +922,9 @@ func batchUpdateBlock(c *gin.Context) {
 	tx.DoOperations = ops
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
+	if txConflicted(transactions, ret) {
+		return
+	}
 
 	ret.Data = transactions
 	broadcastTransactions(transactions)
@@ -949,6 +962,33 @@ func deleteBlock(c *gin.Context) {
 	broadcastTransactions(transactions)
 }
 
+// txPrecondition 解析乐观并发控制参数 expectedUpdated 和 expectedHash，类似 HTTP If-Match。
+func txPrecondition(id string, arg map[string]interface{}) *model.TxPrecondition {
+	updated, _ := arg["expectedUpdated"].(string)
+	hash, _ := arg["expectedHash"].(string)
+	if "" == updated && "" == hash {
+		return nil
+	}
+	return &model.TxPrecondition{ID: id, Updated: updated, Hash: hash}
+}
+
+// txConflicted 检查事务是否因前置条件不满足而未执行，是的话返回冲突块的当前状态。
+func txConflicted(transactions []*model.Transaction, ret *gulu.Result) bool {
+	var conflicts []*model.TxConflict
+	for _, tx := range transactions {
+		tx.WaitForCommit()
+		conflicts = append(conflicts, tx.Conflicts()...)
+	}
+	if 1 > len(conflicts) {
+		return false
+	}
+
+	ret.Code = model.TxErrCodeConflict
+	ret.Msg = "block has been modified"
+	ret.Data = map[string]interface{}{"conflicts": conflicts}
+	return true
+}
+
 func broadcastTransactions(transactions []*model.Transaction) {
 	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
 	evt.Data = transactions
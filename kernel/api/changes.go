@@ -0,0 +1,50 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getChangesSince(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	cursor := c.Query("cursor")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if http.MethodPost == c.Request.Method {
+		arg, ok := util.JsonArg(c, ret)
+		if !ok {
+			return
+		}
+
+		if cursorArg, ok := arg["cursor"].(string); ok {
+			cursor = cursorArg
+		}
+		if limitArg, ok := arg["limit"].(float64); ok {
+			limit = int(limitArg)
+		}
+	}
+
+	ret.Data = model.GetChangesSince(cursor, limit)
+}
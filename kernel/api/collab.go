@@ -0,0 +1,96 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getCollabDocSeq(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"id":  id,
+		"seq": model.GetCollabDocSeq(id),
+	}
+}
+
+func setCollabPresence(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	app := arg["app"].(string)
+	session := arg["session"].(string)
+	var user, rootID, blockID string
+	if nil != arg["user"] {
+		user = arg["user"].(string)
+	}
+	if nil != arg["rootID"] {
+		rootID = arg["rootID"].(string)
+		if "" != rootID && util.InvalidIDPattern(rootID, ret) {
+			return
+		}
+	}
+	if nil != arg["blockID"] {
+		blockID = arg["blockID"].(string)
+		if "" != blockID && util.InvalidIDPattern(blockID, ret) {
+			return
+		}
+	}
+
+	model.SetCollabPresence(app, session, user, rootID, blockID)
+}
+
+func getCollabPresences(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	rootID := arg["rootID"].(string)
+	if util.InvalidIDPattern(rootID, ret) {
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"presences": model.GetCollabPresences(rootID),
+	}
+}
@@ -0,0 +1,207 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func addComment(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	blockID := arg["blockID"].(string)
+	if util.InvalidIDPattern(blockID, ret) {
+		return
+	}
+	content := arg["content"].(string)
+	var parentID, author string
+	if nil != arg["parentID"] {
+		parentID = arg["parentID"].(string)
+		if "" != parentID && util.InvalidIDPattern(parentID, ret) {
+			return
+		}
+	}
+	// 作者由服务端根据会话确定，不使用客户端传入的作者
+	author = model.GetSessionAuthor(c)
+
+	comment, err := model.AddComment(blockID, parentID, author, content)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = comment
+}
+
+func updateComment(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+	content := arg["content"].(string)
+
+	comment, err := model.UpdateComment(id, content)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = comment
+}
+
+func resolveComment(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+	resolved := true
+	if nil != arg["resolved"] {
+		resolved = arg["resolved"].(bool)
+	}
+
+	comment, err := model.ResolveComment(id, resolved)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = comment
+}
+
+func removeComment(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	if err := model.RemoveComment(id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func getBlockComments(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	blockID := arg["blockID"].(string)
+	if util.InvalidIDPattern(blockID, ret) {
+		return
+	}
+
+	threads, err := model.GetBlockComments(blockID)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"threads": threads,
+	}
+}
+
+func getDocComments(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	rootID := arg["rootID"].(string)
+	if util.InvalidIDPattern(rootID, ret) {
+		return
+	}
+
+	threads, err := model.GetDocComments(rootID)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"threads": threads,
+	}
+}
+
+func getOpenComments(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var notebook string
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+		if "" != notebook && util.InvalidIDPattern(notebook, ret) {
+			return
+		}
+	}
+	limit := 128
+	if nil != arg["limit"] {
+		limit = int(arg["limit"].(float64))
+	}
+
+	ret.Data = map[string]interface{}{
+		"threads": model.GetOpenComments(notebook, limit),
+	}
+}
@@ -59,6 +59,37 @@ func exportAttributeView(c *gin.Context) {
 	}
 }
 
+func exportGraph(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id, query string
+	if idArg := arg["id"]; nil != idArg {
+		id = idArg.(string)
+	}
+	if queryArg := arg["k"]; nil != queryArg {
+		query = queryArg.(string)
+	}
+	format := arg["format"].(string)
+	name, filePath, err := model.ExportGraph(id, query, format)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"name": name,
+		"path": filePath,
+	}
+}
+
 func exportEPUB(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -32,6 +32,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -203,6 +204,13 @@ func getFile(c *gin.Context) {
 		c.JSON(http.StatusAccepted, ret)
 		return
 	}
+	if data, err = filesys.DecryptTreeData(data); err != nil {
+		logging.LogErrorf("decrypt file [%s] failed: %s", fileAbsPath, err)
+		ret.Code = http.StatusInternalServerError
+		ret.Msg = err.Error()
+		c.JSON(http.StatusAccepted, ret)
+		return
+	}
 
 	contentType := mime.TypeByExtension(filepath.Ext(fileAbsPath))
 	if "" == contentType {
@@ -424,6 +432,14 @@ func putFile(c *gin.Context) {
 				break
 			}
 
+			if boxID := filesys.TreeFileBoxID(fileAbsPath); "" != boxID {
+				// 写入加密笔记本的文档时需要加密
+				if data, err = filesys.EncryptTreeData(boxID, data); err != nil {
+					logging.LogErrorf("encrypt file [%s] failed: %s", fileAbsPath, err)
+					break
+				}
+			}
+
 			err = filelock.WriteFile(fileAbsPath, data)
 			if err != nil {
 				logging.LogErrorf("write file [%s] failed: %s", fileAbsPath, err)
@@ -287,6 +287,78 @@ func heading2Doc(c *gin.Context) {
 	util.PushEvent(evt)
 }
 
+func splitDoc(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	level := 2
+	if nil != arg["level"] {
+		level = int(arg["level"].(float64))
+	}
+	box, paths, err := model.SplitDoc(id, level)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	model.FlushTxQueue()
+	util.PushReloadFiletree()
+	util.PushReloadProtyle(id)
+
+	ret.Data = map[string]interface{}{
+		"box":   box,
+		"paths": paths,
+	}
+}
+
+func mergeDocs(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var ids []string
+	for _, id := range arg["ids"].([]interface{}) {
+		ids = append(ids, id.(string))
+	}
+	for _, id := range ids {
+		if util.InvalidIDPattern(id, ret) {
+			return
+		}
+	}
+
+	targetID, err := model.MergeDocs(ids)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	model.FlushTxQueue()
+	util.PushReloadFiletree()
+	util.PushReloadProtyle(targetID)
+
+	ret.Data = map[string]interface{}{
+		"id": targetID,
+	}
+}
+
 func li2Doc(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -476,6 +548,53 @@ func getIDsByHPath(c *gin.Context) {
 	ret.Data = ids
 }
 
+func getDocRedirects(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var notebook string
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+	}
+	ret.Data = model.GetDocRedirects(notebook)
+}
+
+func pruneDocRedirects(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var notebook string
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+	}
+	var hPaths []string
+	if nil != arg["paths"] {
+		for _, p := range arg["paths"].([]interface{}) {
+			hPaths = append(hPaths, p.(string))
+		}
+	}
+
+	count, err := model.PruneDocRedirects(notebook, hPaths)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"count": count,
+	}
+}
+
 func moveDocs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
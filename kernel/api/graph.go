@@ -137,3 +137,39 @@ func getLocalGraph(c *gin.Context) {
 	}
 	util.RandomSleep(200, 500)
 }
+
+func analyzeGraph(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id, query, centrality, fromID, toID string
+	if nil != arg["id"] {
+		id = arg["id"].(string)
+		if "" != id && util.InvalidIDPattern(id, ret) {
+			return
+		}
+	}
+	if nil != arg["k"] {
+		query = arg["k"].(string)
+	}
+	if nil != arg["centrality"] {
+		centrality = arg["centrality"].(string)
+	}
+	if nil != arg["from"] {
+		fromID = arg["from"].(string)
+	}
+	if nil != arg["to"] {
+		toID = arg["to"].(string)
+	}
+	limit := 32
+	if nil != arg["limit"] {
+		limit = int(arg["limit"].(float64))
+	}
+
+	ret.Data = model.AnalyzeGraph(id, query, centrality, limit, fromID, toID)
+}
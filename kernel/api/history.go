@@ -222,3 +222,57 @@ func rollbackNotebookHistory(c *gin.Context) {
 		return
 	}
 }
+
+func getBlockHistory(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	var rootID string
+	if nil != arg["rootID"] {
+		rootID = arg["rootID"].(string)
+	}
+
+	versions, err := model.GetBlockHistory(id, rootID)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"versions": versions,
+	}
+}
+
+func restoreBlockHistory(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	source := arg["source"].(string)
+	ref := arg["ref"].(string)
+	if err := model.RestoreBlockHistory(id, source, ref); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
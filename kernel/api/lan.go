@@ -0,0 +1,173 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getLANPeers(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"peers": model.GetLANPeers(),
+		"lan":   model.Conf.Sync.LAN,
+	}
+}
+
+func setSyncLAN(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(arg["lan"])
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	lan := &conf.LAN{}
+	if err = gulu.JSON.UnmarshalJSON(data, lan); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	model.SetSyncLAN(lan)
+	ret.Data = map[string]interface{}{
+		"lan": model.Conf.Sync.LAN,
+	}
+}
+
+func performLANSync(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	if err := model.SyncLAN(true); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+// 以下接口供其他内核调用，使用仓库密钥签名认证，请求和响应数据都是加密的
+
+func lanPing(c *gin.Context) {
+	lanJSON(c, model.GetLANSelf())
+}
+
+func lanSync(c *gin.Context) {
+	go model.SyncLANStore()
+	c.Status(http.StatusOK)
+}
+
+func lanGetObject(c *gin.Context) {
+	data, err := model.GetLANObject(c.Query("key"))
+	if err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+	lanData(c, data)
+}
+
+func lanPutObject(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+
+	if err = model.PutLANObject(c.Query("key"), data, "true" == c.Query("overwrite")); err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func lanRemoveObject(c *gin.Context) {
+	if err := model.RemoveLANObject(c.Query("key")); err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func lanListObjects(c *gin.Context) {
+	objects, err := model.ListLANObjects(c.Query("prefix"))
+	if err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+	lanJSON(c, objects)
+}
+
+func lanMissingObjects(c *gin.Context) {
+	var keys []string
+	if err := c.ShouldBindJSON(&keys); err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+
+	missing, err := model.MissingLANObjects(keys)
+	if err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+	lanJSON(c, missing)
+}
+
+func lanJSON(c *gin.Context, v interface{}) {
+	data, err := gulu.JSON.MarshalJSON(v)
+	if err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+	lanData(c, data)
+}
+
+func lanData(c *gin.Context, data []byte) {
+	data, err := model.EncryptLANPayload(data)
+	if err != nil {
+		lanObjectErr(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+func lanObjectErr(c *gin.Context, err error) {
+	if errors.Is(err, cloud.ErrCloudObjectNotFound) {
+		c.String(http.StatusNotFound, err.Error())
+		return
+	}
+	c.String(http.StatusInternalServerError, err.Error())
+}
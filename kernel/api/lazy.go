@@ -85,10 +85,19 @@ func getLazyLoadConfig(c *gin.Context) {
 	defer c.JSON(http.StatusOK, ret)
 
 	ret.Data = map[string]interface{}{
-		"enabled": model.Conf.Repo.LazyLoadEnabled,
+		"enabled":   model.Conf.Repo.LazyLoadEnabled,
+		"cacheSize": model.Conf.Repo.LazyCacheSize,
+		"prefetch":  model.Conf.Repo.LazyPrefetchEnabled,
 	}
 }
 
+func getLazyCacheStats(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetLazyCacheStats()
+}
+
 func setLazyLoadConfig(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -100,11 +109,23 @@ func setLazyLoadConfig(c *gin.Context) {
 
 	enabled := arg["enabled"].(bool)
 	model.Conf.Repo.LazyLoadEnabled = enabled
+	if cacheSizeArg := arg["cacheSize"]; nil != cacheSizeArg {
+		cacheSize := int64(cacheSizeArg.(float64))
+		if 0 > cacheSize {
+			cacheSize = 0
+		}
+		model.Conf.Repo.LazyCacheSize = cacheSize
+	}
+	if prefetchArg := arg["prefetch"]; nil != prefetchArg {
+		model.Conf.Repo.LazyPrefetchEnabled = prefetchArg.(bool)
+	}
 
 	// 保存配置
 	model.Conf.Save()
 
 	ret.Data = map[string]interface{}{
-		"enabled": enabled,
+		"enabled":   enabled,
+		"cacheSize": model.Conf.Repo.LazyCacheSize,
+		"prefetch":  model.Conf.Repo.LazyPrefetchEnabled,
 	}
 }
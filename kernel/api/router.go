@@ -92,12 +92,17 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/notebook/closeNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, closeNotebook)
 	ginServer.Handle("POST", "/api/notebook/getNotebookConf", model.CheckAuth, getNotebookConf)
 	ginServer.Handle("POST", "/api/notebook/setNotebookConf", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNotebookConf)
+	ginServer.Handle("POST", "/api/notebook/setNotebookSyncMode", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNotebookSyncMode)
 	ginServer.Handle("POST", "/api/notebook/createNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createNotebook)
 	ginServer.Handle("POST", "/api/notebook/removeNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeNotebook)
 	ginServer.Handle("POST", "/api/notebook/renameNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, renameNotebook)
 	ginServer.Handle("POST", "/api/notebook/changeSortNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, changeSortNotebook)
 	ginServer.Handle("POST", "/api/notebook/setNotebookIcon", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNotebookIcon)
 	ginServer.Handle("POST", "/api/notebook/getNotebookInfo", model.CheckAuth, model.CheckReadonly, getNotebookInfo)
+	ginServer.Handle("POST", "/api/notebook/encryptNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, encryptNotebook)
+	ginServer.Handle("POST", "/api/notebook/decryptNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, decryptNotebook)
+	ginServer.Handle("POST", "/api/notebook/unlockNotebook", model.CheckAuth, model.CheckAdminRole, unlockNotebook)
+	ginServer.Handle("POST", "/api/notebook/lockNotebook", model.CheckAuth, model.CheckAdminRole, lockNotebook)
 
 	ginServer.Handle("POST", "/api/filetree/searchDocs", model.CheckAuth, searchDocs)
 	ginServer.Handle("POST", "/api/filetree/listDocsByPath", model.CheckAuth, listDocsByPath)
@@ -122,8 +127,12 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/filetree/getPathByID", model.CheckAuth, getPathByID)
 	ginServer.Handle("POST", "/api/filetree/getFullHPathByID", model.CheckAuth, getFullHPathByID)
 	ginServer.Handle("POST", "/api/filetree/getIDsByHPath", model.CheckAuth, getIDsByHPath)
+	ginServer.Handle("POST", "/api/filetree/getDocRedirects", model.CheckAuth, getDocRedirects)
+	ginServer.Handle("POST", "/api/filetree/pruneDocRedirects", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, pruneDocRedirects)
 	ginServer.Handle("POST", "/api/filetree/doc2Heading", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, doc2Heading)
 	ginServer.Handle("POST", "/api/filetree/heading2Doc", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, heading2Doc)
+	ginServer.Handle("POST", "/api/filetree/splitDoc", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, splitDoc)
+	ginServer.Handle("POST", "/api/filetree/mergeDocs", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, mergeDocs)
 	ginServer.Handle("POST", "/api/filetree/li2Doc", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, li2Doc)
 	ginServer.Handle("POST", "/api/filetree/upsertIndexes", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, upsertIndexes)
 	ginServer.Handle("POST", "/api/filetree/removeIndexes", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeIndexes)
@@ -144,6 +153,8 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/history/reindexHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, reindexHistory)
 	ginServer.Handle("POST", "/api/history/searchHistory", model.CheckAuth, model.CheckAdminRole, searchHistory)
 	ginServer.Handle("POST", "/api/history/getHistoryItems", model.CheckAuth, model.CheckAdminRole, getHistoryItems)
+	ginServer.Handle("POST", "/api/history/getBlockHistory", model.CheckAuth, model.CheckAdminRole, getBlockHistory)
+	ginServer.Handle("POST", "/api/history/restoreBlockHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, restoreBlockHistory)
 
 	ginServer.Handle("POST", "/api/outline/getDocOutline", model.CheckAuth, getDocOutline)
 	ginServer.Handle("POST", "/api/bookmark/getBookmark", model.CheckAuth, getBookmark)
@@ -152,6 +163,10 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/tag/getTag", model.CheckAuth, getTag)
 	ginServer.Handle("POST", "/api/tag/renameTag", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, renameTag)
 	ginServer.Handle("POST", "/api/tag/removeTag", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeTag)
+	ginServer.Handle("POST", "/api/tag/getTagMetas", model.CheckAuth, getTagMetas)
+	ginServer.Handle("POST", "/api/tag/setTagMeta", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setTagMeta)
+	ginServer.Handle("POST", "/api/tag/removeTagMeta", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeTagMeta)
+	ginServer.Handle("POST", "/api/tag/mergeTags", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, mergeTags)
 
 	ginServer.Handle("POST", "/api/lute/spinBlockDOM", model.CheckAuth, spinBlockDOM) // 未测试
 	ginServer.Handle("POST", "/api/lute/html2BlockDOM", model.CheckAuth, html2BlockDOM)
@@ -214,6 +229,12 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/block/foldBlock", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, foldBlock)
 	ginServer.Handle("POST", "/api/block/unfoldBlock", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, unfoldBlock)
 	ginServer.Handle("POST", "/api/block/setBlockReminder", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setBlockReminder)
+	ginServer.Handle("POST", "/api/block/createSyncedBlock", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createSyncedBlock)
+	ginServer.Handle("POST", "/api/block/insertSyncedBlock", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, insertSyncedBlock)
+	ginServer.Handle("POST", "/api/block/unsyncBlock", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, unsyncBlock)
+	ginServer.Handle("POST", "/api/block/getSyncedBlocks", model.CheckAuth, getSyncedBlocks)
+	ginServer.Handle("POST", "/api/task/setTask", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setTaskItem)
+	ginServer.Handle("POST", "/api/task/query", model.CheckAuth, queryTaskItems)
 	ginServer.Handle("POST", "/api/block/getHeadingLevelTransaction", model.CheckAuth, getHeadingLevelTransaction)
 	ginServer.Handle("POST", "/api/block/getHeadingDeleteTransaction", model.CheckAuth, getHeadingDeleteTransaction)
 	ginServer.Handle("POST", "/api/block/getHeadingInsertTransaction", model.CheckAuth, getHeadingInsertTransaction)
@@ -227,6 +248,8 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/block/checkBlockRef", model.CheckAuth, checkBlockRef)
 	ginServer.Handle("POST", "/api/block/appendHeadingChildren", model.CheckAuth, appendHeadingChildren)
 
+	ginServer.Handle("POST", "/api/stat/writing", model.CheckAuth, getWritingStat)
+
 	ginServer.Handle("POST", "/api/file/getFile", model.CheckAuth, getFile)
 	ginServer.Handle("POST", "/api/file/putFile", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, putFile)
 	ginServer.Handle("POST", "/api/file/copyFile", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, copyFile)
@@ -260,6 +283,18 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/sync/setSyncProviderS3", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncProviderS3)
 	ginServer.Handle("POST", "/api/sync/setSyncProviderWebDAV", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncProviderWebDAV)
 	ginServer.Handle("POST", "/api/sync/setSyncProviderLocal", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncProviderLocal)
+	ginServer.Handle("POST", "/api/sync/setSyncProviderSFTP", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncProviderSFTP)
+	ginServer.Handle("POST", "/api/sync/getLANPeers", model.CheckAuth, model.CheckAdminRole, getLANPeers)
+	ginServer.Handle("POST", "/api/sync/setSyncLAN", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncLAN)
+	ginServer.Handle("POST", "/api/sync/performLANSync", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, performLANSync)
+
+	ginServer.Handle("POST", "/api/lan/ping", model.CheckLANAuth, lanPing)
+	ginServer.Handle("POST", "/api/lan/sync", model.CheckLANAuth, lanSync)
+	ginServer.Handle("POST", "/api/lan/getObject", model.CheckLANAuth, lanGetObject)
+	ginServer.Handle("POST", "/api/lan/putObject", model.CheckLANAuth, lanPutObject)
+	ginServer.Handle("POST", "/api/lan/removeObject", model.CheckLANAuth, lanRemoveObject)
+	ginServer.Handle("POST", "/api/lan/listObjects", model.CheckLANAuth, lanListObjects)
+	ginServer.Handle("POST", "/api/lan/missingObjects", model.CheckLANAuth, lanMissingObjects)
 	ginServer.Handle("POST", "/api/sync/setCloudSyncDir", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setCloudSyncDir)
 	ginServer.Handle("POST", "/api/sync/createCloudSyncDir", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createCloudSyncDir)
 	ginServer.Handle("POST", "/api/sync/removeCloudSyncDir", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeCloudSyncDir)
@@ -273,6 +308,8 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/sync/importSyncProviderS3", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importSyncProviderS3)
 	ginServer.Handle("POST", "/api/sync/exportSyncProviderWebDAV", model.CheckAuth, model.CheckAdminRole, exportSyncProviderWebDAV)
 	ginServer.Handle("POST", "/api/sync/importSyncProviderWebDAV", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importSyncProviderWebDAV)
+	ginServer.Handle("POST", "/api/sync/exportSyncProviderSFTP", model.CheckAuth, model.CheckAdminRole, exportSyncProviderSFTP)
+	ginServer.Handle("POST", "/api/sync/importSyncProviderSFTP", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importSyncProviderSFTP)
 
 	ginServer.Handle("POST", "/api/inbox/getShorthands", model.CheckAuth, model.CheckAdminRole, getShorthands)
 	ginServer.Handle("POST", "/api/inbox/getShorthand", model.CheckAuth, model.CheckAdminRole, getShorthand)
@@ -294,6 +331,11 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/asset/getFileAnnotation", model.CheckAuth, getFileAnnotation)
 	ginServer.Handle("POST", "/api/asset/getUnusedAssets", model.CheckAuth, getUnusedAssets)
 	ginServer.Handle("POST", "/api/asset/getMissingAssets", model.CheckAuth, getMissingAssets)
+	ginServer.Handle("POST", "/api/asset/getDuplicateAssets", model.CheckAuth, getDuplicateAssets)
+	ginServer.Handle("POST", "/api/asset/dedupeAssets", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, dedupeAssets)
+	ginServer.Handle("POST", "/api/asset/optimizeImages", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, optimizeImages)
+	ginServer.Handle("POST", "/api/asset/getAssetMeta", model.CheckAuth, getAssetMeta)
+	ginServer.Handle("POST", "/api/asset/reindexAssetMeta", model.CheckAuth, model.CheckAdminRole, reindexAssetMeta)
 	ginServer.Handle("POST", "/api/asset/removeUnusedAsset", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeUnusedAsset)
 	ginServer.Handle("POST", "/api/asset/removeUnusedAssets", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeUnusedAssets)
 	ginServer.Handle("POST", "/api/asset/getDocImageAssets", model.CheckAuth, getDocImageAssets)
@@ -333,6 +375,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/export/exportRTF", model.CheckAuth, model.CheckAdminRole, exportRTF)
 	ginServer.Handle("POST", "/api/export/exportEPUB", model.CheckAuth, model.CheckAdminRole, exportEPUB)
 	ginServer.Handle("POST", "/api/export/exportAttributeView", model.CheckAuth, model.CheckAdminRole, exportAttributeView)
+	ginServer.Handle("POST", "/api/export/exportGraph", model.CheckAuth, model.CheckAdminRole, exportGraph)
 
 	ginServer.Handle("POST", "/api/import/importStdMd", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importStdMd)
 	ginServer.Handle("POST", "/api/import/importZipMd", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importZipMd)
@@ -347,6 +390,18 @@ func ServeAPI(ginServer *gin.Engine) {
 
 	ginServer.Handle("POST", "/api/transactions", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, performTransactions)
 
+	ginServer.Handle("POST", "/api/collab/getDocSeq", model.CheckAuth, getCollabDocSeq)
+	ginServer.Handle("POST", "/api/collab/setPresence", model.CheckAuth, model.CheckAdminRole, setCollabPresence)
+	ginServer.Handle("POST", "/api/collab/getPresences", model.CheckAuth, getCollabPresences)
+
+	ginServer.Handle("POST", "/api/comment/addComment", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, addComment)
+	ginServer.Handle("POST", "/api/comment/updateComment", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, updateComment)
+	ginServer.Handle("POST", "/api/comment/resolveComment", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, resolveComment)
+	ginServer.Handle("POST", "/api/comment/removeComment", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeComment)
+	ginServer.Handle("POST", "/api/comment/getBlockComments", model.CheckAuth, getBlockComments)
+	ginServer.Handle("POST", "/api/comment/getDocComments", model.CheckAuth, getDocComments)
+	ginServer.Handle("POST", "/api/comment/getOpenComments", model.CheckAuth, getOpenComments)
+
 	ginServer.Handle("POST", "/api/setting/setAccount", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAccount)
 	ginServer.Handle("POST", "/api/setting/setEditor", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setEditor)
 	ginServer.Handle("POST", "/api/setting/setExport", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setExport)
@@ -359,6 +414,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/setting/login2faCloudUser", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, login2faCloudUser)
 	ginServer.Handle("POST", "/api/setting/setEmoji", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setEmoji)
 	ginServer.Handle("POST", "/api/setting/setFlashcard", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setFlashcard)
+	ginServer.Handle("POST", "/api/setting/setImage", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setImage)
 	ginServer.Handle("POST", "/api/setting/setAI", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAI)
 	ginServer.Handle("POST", "/api/setting/setBazaar", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setBazaar)
 	ginServer.Handle("POST", "/api/setting/setPublish", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setPublish)
@@ -373,6 +429,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/graph/resetLocalGraph", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, resetLocalGraph)
 	ginServer.Handle("POST", "/api/graph/getGraph", model.CheckAuth, getGraph)
 	ginServer.Handle("POST", "/api/graph/getLocalGraph", model.CheckAuth, getLocalGraph)
+	ginServer.Handle("POST", "/api/graph/analyze", model.CheckAuth, analyzeGraph)
 
 	ginServer.Handle("POST", "/api/bazaar/getBazaarPlugin", model.CheckAuth, getBazaarPlugin)
 	ginServer.Handle("POST", "/api/bazaar/getInstalledPlugin", model.CheckAuth, getInstalledPlugin)
@@ -420,11 +477,16 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/repo/getRepoFile", model.CheckAuth, model.CheckAdminRole, getRepoFile)
 	ginServer.Handle("POST", "/api/repo/setRepoIndexRetentionDays", model.CheckAuth, model.CheckAdminRole, setRepoIndexRetentionDays)
 	ginServer.Handle("POST", "/api/repo/setRetentionIndexesDaily", model.CheckAuth, model.CheckAdminRole, setRetentionIndexesDaily)
+	ginServer.Handle("POST", "/api/repo/getBackupTargets", model.CheckAuth, model.CheckAdminRole, getBackupTargets)
+	ginServer.Handle("POST", "/api/repo/setBackupTarget", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setBackupTarget)
+	ginServer.Handle("POST", "/api/repo/removeBackupTarget", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeBackupTarget)
+	ginServer.Handle("POST", "/api/repo/backupToTarget", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, backupToTarget)
 
 	// 懒加载相关API
 	ginServer.Handle("POST", "/api/repo/loadAssetOnDemand", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, loadAssetOnDemand)
 	ginServer.Handle("POST", "/api/repo/getAssetCacheStatus", model.CheckAuth, model.CheckAdminRole, getAssetCacheStatus)
 	ginServer.Handle("POST", "/api/repo/clearLazyCache", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, clearLazyCache)
+	ginServer.Handle("POST", "/api/repo/getLazyCacheStats", model.CheckAuth, model.CheckAdminRole, getLazyCacheStats)
 	ginServer.Handle("POST", "/api/repo/getLazyLoadConfig", model.CheckAuth, model.CheckAdminRole, getLazyLoadConfig)
 	ginServer.Handle("POST", "/api/repo/setLazyLoadConfig", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setLazyLoadConfig)
 
@@ -487,6 +549,16 @@ func ServeAPI(ginServer *gin.Engine) {
 
 	ginServer.Handle("POST", "/api/ai/chatGPT", model.CheckAuth, model.CheckAdminRole, chatGPT)
 	ginServer.Handle("POST", "/api/ai/chatGPTWithAction", model.CheckAuth, model.CheckAdminRole, chatGPTWithAction)
+	ginServer.Handle("POST", "/api/ai/ask", model.CheckAuth, model.CheckAdminRole, askAI)
+
+	ginServer.Handle("GET", "/api/changes/since", model.CheckAuth, model.CheckAdminRole, getChangesSince)
+	ginServer.Handle("POST", "/api/changes/since", model.CheckAuth, model.CheckAdminRole, getChangesSince)
+	ginServer.Handle("POST", "/api/ai/getConversation", model.CheckAuth, model.CheckAdminRole, getAIConversation)
+	ginServer.Handle("POST", "/api/ai/getAIProfiles", model.CheckAuth, model.CheckAdminRole, getAIProfiles)
+	ginServer.Handle("POST", "/api/ai/setAIProfile", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAIProfile)
+	ginServer.Handle("POST", "/api/ai/removeAIProfile", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeAIProfile)
+	ginServer.Handle("POST", "/api/ai/setAIActionProfile", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAIActionProfile)
+	ginServer.Handle("POST", "/es/ai/chatGPTStream", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, chatGPTStream)
 
 	ginServer.Handle("POST", "/api/petal/loadPetals", model.CheckAuth, loadPetals)
 	ginServer.Handle("POST", "/api/petal/setPetalEnabled", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setPetalEnabled)
@@ -508,7 +580,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	// Mux - 通过http协议跳转文档, 发送请求 -> 前端打开文档 -> 前端聚焦block -> 前端获取焦点
 	// 如果思源不是运行在当前电脑上，那么浏览器打开对应的页面？
 	// ginServer.Handle("GET", "/j/:block_id", model.CheckAuth, mux.Jump)
-	ginServer.Handle("GET", "/j/:block_id", mux.Jump)
+	ginServer.Handle("GET", "/j/*block_id", mux.Jump) // 块 ID 跳转不需要鉴权，可读路径跳转在 Jump 中鉴权
 
 	ginServer.Handle("POST", "/api/ui/reloadUI", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, reloadUI)
 	ginServer.Handle("POST", "/api/ui/reloadAttributeView", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, reloadAttributeView)
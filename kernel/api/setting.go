@@ -204,12 +204,73 @@ func setAI(c *gin.Context) {
 		ai.OpenAI.APIMaxContexts = 7
 	}
 
-	model.Conf.AI = ai
+	if 1 > ai.OpenAI.APIContextSize {
+		ai.OpenAI.APIContextSize = 4096
+	}
+
+	// 只修改默认服务配置时保留命名的服务配置和动作映射
+	if nil == ai.Profiles {
+		ai.Profiles = model.Conf.GetAIProfiles()
+	} else {
+		profiles := []*conf.AIProfile{}
+		for _, profile := range ai.Profiles {
+			if nil == profile || "" == profile.ID {
+				continue
+			}
+			model.NormalizeAIProfile(profile)
+			profiles = append(profiles, profile)
+		}
+		ai.Profiles = profiles
+	}
+	if nil == ai.Actions {
+		ai.Actions = model.Conf.GetAIActions()
+	}
+
+	model.Conf.SetAI(ai)
 	model.Conf.Save()
 
 	ret.Data = ai
 }
 
+func setImage(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	image := &conf.Image{}
+	if err = gulu.JSON.UnmarshalJSON(param, image); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if 1 > image.Quality || 100 < image.Quality {
+		image.Quality = conf.NewImage().Quality
+	}
+	if 0 > image.MaxWidth {
+		image.MaxWidth = 0
+	}
+	if 0 > image.MaxHeight {
+		image.MaxHeight = 0
+	}
+
+	model.Conf.Image = image
+	model.Conf.Save()
+
+	ret.Data = image
+}
+
 func setFlashcard(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -0,0 +1,69 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getWritingStat(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var from, to, notebook, id string
+	if nil != arg["from"] {
+		from = arg["from"].(string)
+	}
+	if nil != arg["to"] {
+		to = arg["to"].(string)
+	}
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+	}
+	if nil != arg["id"] {
+		id = arg["id"].(string)
+		if util.InvalidIDPattern(id, ret) {
+			return
+		}
+	}
+	goal := 1
+	if nil != arg["goal"] {
+		goal = int(arg["goal"].(float64))
+	}
+
+	series, total, streak, err := model.GetWritingStat(from, to, notebook, id, goal)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"series": series,
+		"total":  total,
+		"streak": streak,
+	}
+}
@@ -220,6 +220,192 @@ func exportSyncProviderWebDAV(c *gin.Context) {
 	}
 }
 
+func importSyncProviderSFTP(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(200, ret)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		logging.LogErrorf("read upload file failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	files := form.File["file"]
+	if 1 != len(files) {
+		ret.Code = -1
+		ret.Msg = "invalid upload file"
+		return
+	}
+
+	f := files[0]
+	fh, err := f.Open()
+	if err != nil {
+		logging.LogErrorf("read upload file failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	data, err := io.ReadAll(fh)
+	fh.Close()
+	if err != nil {
+		logging.LogErrorf("read upload file failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	importDir := filepath.Join(util.TempDir, "import")
+	if err = os.MkdirAll(importDir, 0755); err != nil {
+		logging.LogErrorf("import SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	tmp := filepath.Join(importDir, f.Filename)
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		logging.LogErrorf("import SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	tmpDir := filepath.Join(importDir, "sftp")
+	os.RemoveAll(tmpDir)
+	if strings.HasSuffix(strings.ToLower(tmp), ".zip") {
+		if err = gulu.Zip.Unzip(tmp, tmpDir); err != nil {
+			logging.LogErrorf("import SFTP provider failed: %s", err)
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	} else if strings.HasSuffix(strings.ToLower(tmp), ".json") {
+		if err = gulu.File.CopyFile(tmp, filepath.Join(tmpDir, f.Filename)); err != nil {
+			logging.LogErrorf("import SFTP provider failed: %s", err)
+			ret.Code = -1
+			ret.Msg = err.Error()
+		}
+	} else {
+		logging.LogErrorf("invalid SFTP provider package")
+		ret.Code = -1
+		ret.Msg = "invalid SFTP provider package"
+		return
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		logging.LogErrorf("import SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if 1 != len(entries) {
+		logging.LogErrorf("invalid SFTP provider package")
+		ret.Code = -1
+		ret.Msg = "invalid SFTP provider package"
+		return
+	}
+
+	tmp = filepath.Join(tmpDir, entries[0].Name())
+	data, err = os.ReadFile(tmp)
+	if err != nil {
+		logging.LogErrorf("import SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	data = util.AESDecrypt(string(data))
+	data, _ = hex.DecodeString(string(data))
+	sftp := &conf.SFTP{}
+	if err = gulu.JSON.UnmarshalJSON(data, sftp); err != nil {
+		logging.LogErrorf("import SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	err = model.SetSyncProviderSFTP(sftp)
+	if err != nil {
+		logging.LogErrorf("import SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"sftp": model.Conf.Sync.SFTP,
+	}
+}
+
+func exportSyncProviderSFTP(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	name := "siyuan-sftp-" + time.Now().Format("20060102150405") + ".json"
+	tmpDir := filepath.Join(util.TempDir, "export")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		logging.LogErrorf("export SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	sftp := model.Conf.Sync.SFTP
+	if nil == sftp {
+		sftp = &conf.SFTP{}
+	}
+
+	data, err := gulu.JSON.MarshalJSON(sftp)
+	if err != nil {
+		logging.LogErrorf("export SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	dataStr := util.AESEncrypt(string(data))
+	tmp := filepath.Join(tmpDir, name)
+	if err = os.WriteFile(tmp, []byte(dataStr), 0644); err != nil {
+		logging.LogErrorf("export SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	zipFile, err := gulu.Zip.Create(tmp + ".zip")
+	if err != nil {
+		logging.LogErrorf("export SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err = zipFile.AddEntry(name, tmp); err != nil {
+		logging.LogErrorf("export SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err = zipFile.Close(); err != nil {
+		logging.LogErrorf("export SFTP provider failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	zipPath := "/export/" + name + ".zip"
+	ret.Data = map[string]interface{}{
+		"name": name,
+		"zip":  zipPath,
+	}
+}
+
 func importSyncProviderS3(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(200, ret)
@@ -696,6 +882,45 @@ func setSyncProviderWebDAV(c *gin.Context) {
 	}
 }
 
+func setSyncProviderSFTP(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	sftpArg := arg["sftp"].(interface{})
+	data, err := gulu.JSON.MarshalJSON(sftpArg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	sftp := &conf.SFTP{}
+	if err = gulu.JSON.UnmarshalJSON(data, sftp); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	err = model.SetSyncProviderSFTP(sftp)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"sftp": sftp,
+	}
+}
+
 func setSyncProviderLocal(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
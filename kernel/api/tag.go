@@ -82,3 +82,91 @@ func removeTag(c *gin.Context) {
 		return
 	}
 }
+
+func getTagMetas(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetTagMetas()
+}
+
+func setTagMeta(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	meta := &model.TagMeta{}
+	if err = gulu.JSON.UnmarshalJSON(param, meta); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err = model.SetTagMeta(meta); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = meta
+}
+
+func removeTagMeta(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	label := arg["label"].(string)
+	if err := model.RemoveTagMeta(label); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func mergeTags(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var sources []string
+	for _, source := range arg["sources"].([]interface{}) {
+		sources = append(sources, source.(string))
+	}
+	target := arg["target"].(string)
+
+	transaction, err := model.MergeTags(sources, target)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	if nil == transaction || 1 > len(transaction.DoOperations) {
+		return
+	}
+
+	transactions := []*model.Transaction{transaction}
+	ret.Data = transactions
+	broadcastTransactions(transactions)
+}
@@ -0,0 +1,97 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func setTaskItem(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	fields := map[string]string{}
+	for _, name := range []string{"due", "scheduled", "priority", "recurrence"} {
+		if value, ok := arg[name].(string); ok {
+			fields[name] = value
+		}
+	}
+
+	if err := model.SetTaskItem(id, fields); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+}
+
+func queryTaskItems(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var filter, tag, notebook string
+	if nil != arg["filter"] {
+		filter = arg["filter"].(string) // overdue/today/upcoming，为空时查询所有任务
+	}
+	if nil != arg["tag"] {
+		tag = arg["tag"].(string)
+	}
+	if nil != arg["notebook"] {
+		notebook = arg["notebook"].(string)
+		if "" != notebook && util.InvalidIDPattern(notebook, ret) {
+			return
+		}
+	}
+	includeDone := false
+	if nil != arg["includeDone"] {
+		includeDone = arg["includeDone"].(bool)
+	}
+	limit := 256
+	if nil != arg["limit"] {
+		limit = int(arg["limit"].(float64))
+	}
+
+	tasks, err := model.QueryTaskItems(filter, tag, notebook, includeDone, limit)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"tasks": tasks,
+	}
+}
@@ -60,16 +60,27 @@ func performTransactions(c *gin.Context) {
 		ret.Msg = "parses request failed"
 		return
 	}
+	app := arg["app"].(string)
+	session := arg["session"].(string)
+	hasPreconditions := false
 	for _, transaction := range transactions {
 		transaction.Timestamp = timestamp
+		transaction.Session = session
+		hasPreconditions = hasPreconditions || 0 < len(transaction.Preconditions)
 	}
 
-	model.PerformTransactions(&transactions)
+	if hasPreconditions {
+		// 带前置条件的事务整批执行，任一前置条件不满足时整批都不执行
+		model.PerformTransactionsIfMatch(transactions)
+		if txConflicted(transactions, ret) {
+			return
+		}
+	} else {
+		model.PerformTransactions(&transactions)
+	}
 
 	ret.Data = transactions
 
-	app := arg["app"].(string)
-	session := arg["session"].(string)
 	pushTransactions(app, session, transactions)
 
 	if model.IsMoveOutlineHeading(&transactions) {
@@ -92,6 +103,10 @@ func pushTransactions(app, session string, transactions []*model.Transaction) {
 		if isAttrViewTx && "setAttrViewName" != action {
 			pushMode = util.PushModeBroadcast
 		}
+		if model.IsMergedTransactions(transactions) {
+			// 合并了并发修改的话发起方也需要更新
+			pushMode = util.PushModeBroadcast
+		}
 	}
 
 	evt := util.NewCmdResult("transactions", 0, pushMode)
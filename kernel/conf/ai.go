@@ -26,6 +26,15 @@ import (
 )
 
 type AI struct {
+	OpenAI   *OpenAI           `json:"openAI"`   // 默认服务配置
+	Profiles []*AIProfile      `json:"profiles"` // 命名的服务配置
+	Actions  map[string]string `json:"actions"`  // 动作到服务配置 ID 的映射，未映射的动作使用默认服务配置
+}
+
+// AIProfile 描述一个命名的 AI 服务配置，比如本地部署的小模型用于翻译，云端的大模型用于推理。
+type AIProfile struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
 	OpenAI *OpenAI `json:"openAI"`
 }
 
@@ -37,9 +46,10 @@ type OpenAI struct {
 	APIMaxTokens   int     `json:"apiMaxTokens"`
 	APITemperature float64 `json:"apiTemperature"`
 	APIMaxContexts int     `json:"apiMaxContexts"`
+	APIContextSize int     `json:"apiContextSize"` // 自动组装上下文时使用的 token 预算
 	APIBaseURL     string  `json:"apiBaseURL"`
 	APIUserAgent   string  `json:"apiUserAgent"`
-	APIProvider    string  `json:"apiProvider"` // OpenAI, Azure
+	APIProvider    string  `json:"apiProvider"` // OpenAI, Azure, OpenAICompatible
 	APIVersion     string  `json:"apiVersion"`  // Azure API version
 }
 
@@ -47,6 +57,7 @@ func NewAI() *AI {
 	openAI := &OpenAI{
 		APITemperature: 1.0,
 		APIMaxContexts: 7,
+		APIContextSize: 4096,
 		APITimeout:     30,
 		APIModel:       openai.GPT3Dot5Turbo,
 		APIBaseURL:     "https://api.openai.com/v1",
@@ -88,6 +99,13 @@ func NewAI() *AI {
 		}
 	}
 
+	if contextSize := os.Getenv("SIYUAN_OPENAI_API_CONTEXT_SIZE"); "" != contextSize {
+		contextSizeInt, err := strconv.Atoi(contextSize)
+		if err == nil {
+			openAI.APIContextSize = contextSizeInt
+		}
+	}
+
 	if baseURL := os.Getenv("SIYUAN_OPENAI_API_BASE_URL"); "" != baseURL {
 		openAI.APIBaseURL = baseURL
 	}
@@ -95,5 +113,21 @@ func NewAI() *AI {
 	if userAgent := os.Getenv("SIYUAN_OPENAI_API_USER_AGENT"); "" != userAgent {
 		openAI.APIUserAgent = userAgent
 	}
-	return &AI{OpenAI: openAI}
+	return &AI{OpenAI: openAI, Profiles: []*AIProfile{}, Actions: map[string]string{}}
+}
+
+// NewAIProfile 使用默认的超时、上下文等参数创建服务配置，不读取环境变量。
+func NewAIProfile() *AIProfile {
+	return &AIProfile{
+		OpenAI: &OpenAI{
+			APITemperature: 1.0,
+			APIMaxContexts: 7,
+			APIContextSize: 4096,
+			APITimeout:     30,
+			APIModel:       openai.GPT3Dot5Turbo,
+			APIBaseURL:     "https://api.openai.com/v1",
+			APIUserAgent:   util.UserAgent,
+			APIProvider:    "OpenAI",
+		},
+	}
 }
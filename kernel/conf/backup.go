@@ -0,0 +1,49 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// BackupTarget 描述一个备份目标，备份目标只接收快照推送，不参与数据同步合并。
+type BackupTarget struct {
+	ID        string  `json:"id"`        // 备份目标 ID
+	Name      string  `json:"name"`      // 备份目标名称
+	Enabled   bool    `json:"enabled"`   // 是否启用定时备份
+	Mode      int     `json:"mode"`      // 备份模式，0：定时创建快照并推送，1：推送标记过的快照
+	Interval  int     `json:"interval"`  // 定时备份间隔，单位：小时
+	Retention int     `json:"retention"` // 备份目标上保留的快照数，0 表示不清理
+	CloudName string  `json:"cloudName"` // 备份目标上的仓库目录名称
+	Provider  int     `json:"provider"`  // 存储服务提供者，支持 S3、WebDAV、本地文件系统和 SFTP
+	S3        *S3     `json:"s3"`        // S3 对象存储服务配置
+	WebDAV    *WebDAV `json:"webdav"`    // WebDAV 服务配置
+	Local     *Local  `json:"local"`     // 本地文件系统 服务配置
+	SFTP      *SFTP   `json:"sftp"`      // SFTP 服务配置
+
+	LastRun     int64  `json:"lastRun"`     // 最近备份时间
+	LastSuccess int64  `json:"lastSuccess"` // 最近备份成功时间
+	LastError   string `json:"lastError"`   // 最近备份错误信息
+	Stat        string `json:"stat"`        // 最近备份统计信息
+}
+
+func (target *BackupTarget) Sync() *Sync {
+	return &Sync{
+		CloudName: target.CloudName,
+		Provider:  target.Provider,
+		S3:        target.S3,
+		WebDAV:    target.WebDAV,
+		Local:     target.Local,
+		SFTP:      target.SFTP,
+	}
+}
@@ -31,8 +31,18 @@ type BoxConf struct {
 	DailyNoteSavePath     string `json:"dailyNoteSavePath"`     // 新建日记存储路径
 	DailyNoteTemplatePath string `json:"dailyNoteTemplatePath"` // 新建日记使用的模板路径
 	SortMode              int    `json:"sortMode"`              // 排序方式
+	Encrypted             bool   `json:"encrypted"`             // 是否加密存储
+	EncryptSalt           string `json:"encryptSalt"`           // 加密密钥派生盐值
+	EncryptKeyHash        string `json:"encryptKeyHash"`        // 加密密钥摘要，用于校验解锁密码
+	SyncMode              int    `json:"syncMode"`              // 云端同步方式，0：同步，1：仅本地，2：只拉取
 }
 
+const (
+	BoxSyncModeSync      = 0 // 正常参与云端同步
+	BoxSyncModeLocalOnly = 1 // 仅保存在本地，不上传也不会被云端合并修改或者删除
+	BoxSyncModePullOnly  = 2 // 只拉取云端修改，笔记本在本地只读
+)
+
 func NewBoxConf() *BoxConf {
 	return &BoxConf{
 		Name:                  "Untitled",
@@ -0,0 +1,37 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+type Image struct {
+	Optimize   bool `json:"optimize"`   // 上传时是否优化图片
+	MaxWidth   int  `json:"maxWidth"`   // 最大宽度，超过时等比缩小，0 表示不限制
+	MaxHeight  int  `json:"maxHeight"`  // 最大高度，超过时等比缩小，0 表示不限制
+	Quality    int  `json:"quality"`    // JPEG 压缩质量，可配置区间 [1, 100]
+	StripGPS   bool `json:"stripGPS"`   // 是否移除 EXIF 中的 GPS 信息
+	ConvertPNG bool `json:"convertPNG"` // 是否将不透明的 PNG 转换为 JPEG
+}
+
+func NewImage() *Image {
+	return &Image{
+		Optimize:   false,
+		MaxWidth:   2560,
+		MaxHeight:  2560,
+		Quality:    85,
+		StripGPS:   true,
+		ConvertPNG: false,
+	}
+}
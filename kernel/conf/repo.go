@@ -35,7 +35,11 @@ type Repo struct {
 	RetentionIndexesDaily int `json:"retentionIndexesDaily"` // 每日保留索引数
 	
 	// 懒加载功能配置
-	LazyLoadEnabled bool `json:"lazyLoadEnabled"` // 是否启用资源文件懒加载
+	LazyLoadEnabled     bool  `json:"lazyLoadEnabled"`     // 是否启用资源文件懒加载
+	LazyCacheSize       int64 `json:"lazyCacheSize"`       // 懒加载资源文件缓存上限，单位：MB，0 表示不限制
+	LazyPrefetchEnabled bool  `json:"lazyPrefetchEnabled"` // 打开文档时是否预取文档引用的资源文件
+
+	BackupTargets []*BackupTarget `json:"backupTargets"` // 备份目标
 }
 
 func NewRepo() *Repo {
@@ -44,6 +48,9 @@ func NewRepo() *Repo {
 		IndexRetentionDays:    180,
 		RetentionIndexesDaily: 2,
 		LazyLoadEnabled:       true, // 默认启用懒加载
+		LazyCacheSize:         1024,
+		LazyPrefetchEnabled:   true,
+		BackupTargets:         []*BackupTarget{},
 	}
 }
 
@@ -24,11 +24,14 @@ type Sync struct {
 	Interval            int     `json:"interval"`            // 自动同步间隔，单位：秒
 	Synced              int64   `json:"synced"`              // 最近同步时间
 	Stat                string  `json:"stat"`                // 最近同步统计信息
+	SyncedIndex         string  `json:"syncedIndex"`         // 最近同步完成时的本地快照 ID
 	GenerateConflictDoc bool    `json:"generateConflictDoc"` // 云端同步冲突时是否生成冲突文档
 	Provider            int     `json:"provider"`            // 云端存储服务提供者
 	S3                  *S3     `json:"s3"`                  // S3 对象存储服务配置
 	WebDAV              *WebDAV `json:"webdav"`              // WebDAV 服务配置
 	Local               *Local  `json:"local"`               // 本地文件系统 服务配置
+	SFTP                *SFTP   `json:"sftp"`                // SFTP 服务配置
+	LAN                 *LAN    `json:"lan"`                 // 局域网同步配置
 }
 
 func NewSync() *Sync {
@@ -70,11 +73,25 @@ type Local struct {
 	ConcurrentReqs int    `json:"concurrentReqs"` // 并发请求数
 }
 
+type SFTP struct {
+	Host           string `json:"host"`           // 主机
+	Port           int    `json:"port"`           // 端口
+	Username       string `json:"username"`       // 用户名
+	Password       string `json:"password"`       // 密码，和私钥二选一
+	PrivateKey     string `json:"privateKey"`     // PEM 格式私钥
+	Passphrase     string `json:"passphrase"`     // 私钥口令
+	HostKey        string `json:"hostKey"`        // 服务端公钥指纹（SHA256），为空时在首次连接时记录
+	Path           string `json:"path"`           // 远端目录，相对路径相对于登录目录，为空时使用登录目录
+	Timeout        int    `json:"timeout"`        // 超时时间，单位：秒
+	ConcurrentReqs int    `json:"concurrentReqs"` // 并发请求数
+}
+
 const (
 	ProviderSiYuan = 0 // ProviderSiYuan 为思源官方提供的云端存储服务
 	ProviderS3     = 2 // ProviderS3 为 S3 协议对象存储提供的云端存储服务
 	ProviderWebDAV = 3 // ProviderWebDAV 为 WebDAV 协议提供的云端存储服务
 	ProviderLocal  = 4 // ProviderLocal 为本地文件系统提供的存储服务
+	ProviderSFTP   = 5 // ProviderSFTP 为 SFTP 协议提供的存储服务
 )
 
 func ProviderToStr(provider int) string {
@@ -87,6 +104,18 @@ func ProviderToStr(provider int) string {
 		return "WebDAV"
 	case ProviderLocal:
 		return "Local File System"
+	case ProviderSFTP:
+		return "SFTP"
 	}
 	return "Unknown"
 }
+
+// LAN 描述局域网内核之间的点对点同步配置，和云端存储服务提供者相互独立。
+type LAN struct {
+	Enabled   bool     `json:"enabled"`   // 是否开启局域网同步
+	Discovery bool     `json:"discovery"` // 是否通过局域网组播自动发现其他内核
+	Peers     []string `json:"peers"`     // 手动配置的其他内核地址，格式为 host:port
+	Interval  int      `json:"interval"`  // 自动同步间隔，单位：秒
+	Synced    int64    `json:"synced"`    // 最近同步时间
+	Stat      string   `json:"stat"`      // 最近同步统计信息
+}
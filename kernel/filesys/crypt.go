@@ -0,0 +1,170 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package filesys
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/siyuan-note/encryption"
+)
+
+// 加密笔记本的 .sy 文件格式为：encryptedTreeMagic + 笔记本 ID + "\n" + AES-GCM 密文。
+// 笔记本加密只覆盖文档 .sy 文件，资源文件（assets 等）仍以明文存储。
+
+var (
+	ErrBoxLocked     = errors.New("notebook is locked")
+	ErrInvalidCipher = errors.New("invalid encrypted tree data")
+
+	encryptedTreeMagic = []byte("siyuan-enc:v1:")
+
+	encryptedBoxes     = map[string][]byte{} // 加密笔记本 ID -> 会话密钥，未解锁时密钥为 nil
+	encryptedBoxesLock = sync.RWMutex{}
+)
+
+// SetBoxEncrypted 登记笔记本是否启用了加密，不会清除已经解锁的会话密钥。
+func SetBoxEncrypted(boxID string, encrypted bool) {
+	encryptedBoxesLock.Lock()
+	defer encryptedBoxesLock.Unlock()
+
+	if !encrypted {
+		delete(encryptedBoxes, boxID)
+		return
+	}
+
+	if _, ok := encryptedBoxes[boxID]; !ok {
+		encryptedBoxes[boxID] = nil
+	}
+}
+
+// UnlockBox 为加密笔记本设置本次会话使用的密钥。
+func UnlockBox(boxID string, key []byte) {
+	encryptedBoxesLock.Lock()
+	defer encryptedBoxesLock.Unlock()
+	encryptedBoxes[boxID] = key
+}
+
+// LockBox 清除加密笔记本的会话密钥。
+func LockBox(boxID string) {
+	encryptedBoxesLock.Lock()
+	defer encryptedBoxesLock.Unlock()
+	if _, ok := encryptedBoxes[boxID]; ok {
+		encryptedBoxes[boxID] = nil
+	}
+}
+
+func IsBoxEncrypted(boxID string) bool {
+	encryptedBoxesLock.RLock()
+	defer encryptedBoxesLock.RUnlock()
+	_, ok := encryptedBoxes[boxID]
+	return ok
+}
+
+func IsBoxLocked(boxID string) bool {
+	encryptedBoxesLock.RLock()
+	defer encryptedBoxesLock.RUnlock()
+	key, ok := encryptedBoxes[boxID]
+	return ok && nil == key
+}
+
+func getBoxKey(boxID string) (ret []byte, encrypted bool) {
+	encryptedBoxesLock.RLock()
+	defer encryptedBoxesLock.RUnlock()
+	ret, encrypted = encryptedBoxes[boxID]
+	return
+}
+
+func IsEncryptedTreeData(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedTreeMagic)
+}
+
+// EncryptTreeData 使用笔记本的会话密钥加密文档数据，未启用加密的笔记本原样返回。
+func EncryptTreeData(boxID string, data []byte) (ret []byte, err error) {
+	key, encrypted := getBoxKey(boxID)
+	if !encrypted {
+		return data, nil
+	}
+	if nil == key {
+		return nil, ErrBoxLocked
+	}
+	return EncryptTreeDataWithKey(boxID, data, key)
+}
+
+func EncryptTreeDataWithKey(boxID string, data, key []byte) (ret []byte, err error) {
+	if IsEncryptedTreeData(data) {
+		return data, nil
+	}
+
+	cipherData, err := encryption.AesEncrypt(data, key)
+	if err != nil {
+		return
+	}
+
+	buf := bytes.Buffer{}
+	buf.Grow(len(encryptedTreeMagic) + len(boxID) + 1 + len(cipherData))
+	buf.Write(encryptedTreeMagic)
+	buf.WriteString(boxID)
+	buf.WriteByte('\n')
+	buf.Write(cipherData)
+	ret = buf.Bytes()
+	return
+}
+
+// DecryptTreeData 解密文档数据，未加密的数据原样返回。
+func DecryptTreeData(data []byte) (ret []byte, err error) {
+	if !IsEncryptedTreeData(data) {
+		return data, nil
+	}
+
+	header := data[len(encryptedTreeMagic):]
+	idx := bytes.IndexByte(header, '\n')
+	if 0 > idx {
+		return nil, ErrInvalidCipher
+	}
+
+	boxID := string(header[:idx])
+	key, _ := getBoxKey(boxID)
+	if nil == key {
+		return nil, ErrBoxLocked
+	}
+	return DecryptTreeDataWithKey(data, key)
+}
+
+func DecryptTreeDataWithKey(data, key []byte) (ret []byte, err error) {
+	if !IsEncryptedTreeData(data) {
+		return data, nil
+	}
+
+	header := data[len(encryptedTreeMagic):]
+	idx := bytes.IndexByte(header, '\n')
+	if 0 > idx {
+		return nil, ErrInvalidCipher
+	}
+
+	ret, err = encryption.AesDecrypt(header[idx+1:], key)
+	return
+}
+
+// TreeFileBoxID 返回数据目录下 .sy 文件所属的笔记本 ID，不是文档文件时返回空。
+func TreeFileBoxID(absPath string) string {
+	if !strings.HasSuffix(absPath, ".sy") {
+		return ""
+	}
+	return boxIDFromAbsPath(absPath)
+}
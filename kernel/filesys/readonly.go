@@ -0,0 +1,46 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package filesys
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrBoxReadonly = errors.New("notebook is read-only")
+
+	readonlyBoxes     = map[string]bool{} // 只读笔记本 ID，例如只拉取云端修改的笔记本
+	readonlyBoxesLock = sync.RWMutex{}
+)
+
+// SetBoxReadonly 登记笔记本是否只读，只读笔记本中的文档不能在本地写入。
+func SetBoxReadonly(boxID string, readonly bool) {
+	readonlyBoxesLock.Lock()
+	defer readonlyBoxesLock.Unlock()
+	if readonly {
+		readonlyBoxes[boxID] = true
+	} else {
+		delete(readonlyBoxes, boxID)
+	}
+}
+
+func IsBoxReadonly(boxID string) bool {
+	readonlyBoxesLock.RLock()
+	defer readonlyBoxesLock.RUnlock()
+	return readonlyBoxes[boxID]
+}
@@ -125,6 +125,11 @@ func LoadTree(boxID, p string, luteEngine *lute.Lute) (ret *parse.Tree, err erro
 }
 
 func LoadTreeByData(data []byte, boxID, p string, luteEngine *lute.Lute) (ret *parse.Tree, err error) {
+	if data, err = DecryptTreeData(data); err != nil {
+		logging.LogErrorf("decrypt tree [%s] failed: %s", p, err)
+		return
+	}
+
 	ret = parseJSON2Tree(boxID, p, data, luteEngine)
 	if nil == ret {
 		logging.LogErrorf("parse tree [%s] failed", p)
@@ -184,6 +189,10 @@ func LoadTreeByData(data []byte, boxID, p string, luteEngine *lute.Lute) (ret *p
 }
 
 func DocIAL(absPath string) (ret map[string]string) {
+	if boxID := boxIDFromAbsPath(absPath); "" != boxID && IsBoxEncrypted(boxID) {
+		return encryptedDocIAL(absPath)
+	}
+
 	filelock.Lock(absPath)
 	file, err := os.Open(absPath)
 	if err != nil {
@@ -206,6 +215,42 @@ func DocIAL(absPath string) (ret map[string]string) {
 	return
 }
 
+func encryptedDocIAL(absPath string) (ret map[string]string) {
+	data, err := filelock.ReadFile(absPath)
+	if err != nil {
+		logging.LogErrorf("read file [%s] failed: %s", absPath, err)
+		return nil
+	}
+
+	if data, err = DecryptTreeData(data); err != nil {
+		logging.LogErrorf("decrypt file [%s] failed: %s", absPath, err)
+		return nil
+	}
+
+	iter := jsoniter.ParseBytes(jsoniter.ConfigCompatibleWithStandardLibrary, data)
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		if field == "Properties" {
+			iter.ReadVal(&ret)
+			break
+		} else {
+			iter.Skip()
+		}
+	}
+	return
+}
+
+func boxIDFromAbsPath(absPath string) string {
+	rel, err := filepath.Rel(util.DataDir, absPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	if idx := strings.Index(rel, "/"); 0 < idx {
+		return rel[:idx]
+	}
+	return ""
+}
+
 func TreeSize(tree *parse.Tree) (size uint64) {
 	luteEngine := util.NewLute() // 不关注用户的自定义解析渲染选项
 	renderer := render.NewJSONRenderer(tree, luteEngine.RenderOptions)
@@ -213,6 +258,11 @@ func TreeSize(tree *parse.Tree) (size uint64) {
 }
 
 func WriteTree(tree *parse.Tree) (size uint64, err error) {
+	if IsBoxReadonly(tree.Box) {
+		logging.LogWarnf("write tree [%s] in read-only box [%s] is not allowed", tree.ID, tree.Box)
+		return 0, ErrBoxReadonly
+	}
+
 	data, filePath, err := prepareWriteTree(tree)
 	if err != nil {
 		return
@@ -266,6 +316,11 @@ func prepareWriteTree(tree *parse.Tree) (data []byte, filePath string, err error
 		data = buf.Bytes()
 	}
 
+	if data, err = EncryptTreeData(tree.Box, data); err != nil {
+		logging.LogErrorf("encrypt tree [%s] failed: %s", filePath, err)
+		return
+	}
+
 	if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
 		return
 	}
@@ -318,6 +373,10 @@ func parseJSON2Tree(boxID, p string, jsonData []byte, luteEngine *lute.Lute) (re
 			data = buf.Bytes()
 		}
 
+		if data, err = EncryptTreeData(boxID, data); err != nil {
+			return
+		}
+
 		if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
 			return
 		}
@@ -31,6 +31,7 @@ func StartCron() {
 	go every(100*time.Millisecond, task.ExecAsyncTaskJob)
 	go every(7*time.Second, task.StatusJob)
 	go every(5*time.Second, model.SyncDataJob)
+	go every(30*time.Second, model.LANSyncJob)
 	go every(2*time.Hour, model.StatJob)
 	go every(6*time.Hour, util.RefreshRhyResultJob, "RefreshRhyResultJob")
 	go every(2*time.Hour, model.RefreshCheckJob)
@@ -41,9 +42,13 @@ func StartCron() {
 	go every(10*time.Minute, model.IndexEmbedBlockJob)
 	go every(10*time.Minute, model.CacheVirtualBlockRefJob)
 	go every(30*time.Second, model.OCRAssetsJob)
+	go every(30*time.Minute, model.IndexAssetMetaJob)
+	go every(30*time.Minute, model.IndexCommentsJob)
 	go every(30*time.Second, model.FlushAssetsTextsJob)
+	go every(30*time.Second, model.FlushWritingStatJob)
 	go every(30*time.Second, model.HookDesktopUIProcJob)
 	go every(24*time.Hour, model.AutoPurgeRepoJob)
+	go every(10*time.Minute, model.BackupTargetsJob)
 	go every(30*time.Minute, model.AutoCheckMicrosoftDefenderJob)
 
 	// TODO: 移除旧方案 https://github.com/siyuan-note/siyuan/issues/14414 实现新的刷新机制
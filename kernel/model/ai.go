@@ -23,12 +23,13 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
 	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
 func ChatGPT(msg string) (ret string) {
-	if !isOpenAIAPIEnabled() {
+	if !isOpenAIAPIEnabled(Conf.AI.OpenAI) {
 		return
 	}
 
@@ -36,7 +37,7 @@ func ChatGPT(msg string) (ret string) {
 }
 
 func ChatGPTWithAction(ids []string, action string) (ret string) {
-	if !isOpenAIAPIEnabled() {
+	if !isOpenAIAPIEnabled(getAIActionOpenAI(action)) {
 		return
 	}
 
@@ -60,7 +61,7 @@ func chatGPT(msg string, cloud bool) (ret string) {
 		return
 	}
 
-	ret, retCtxMsgs, err := chatGPTContinueWrite(msg, cachedContextMsg, cloud)
+	ret, retCtxMsgs, err := chatGPTContinueWrite(msg, cachedContextMsg, cloud, Conf.AI.OpenAI)
 	if err != nil {
 		return
 	}
@@ -70,33 +71,34 @@ func chatGPT(msg string, cloud bool) (ret string) {
 
 func chatGPTWithAction(msg string, action string, cloud bool) (ret string) {
 	action = strings.TrimSpace(action)
+	openAI := getAIActionOpenAI(action)
 	if "" != action {
 		msg = action + ":\n\n" + msg
 	}
-	ret, _, err := chatGPTContinueWrite(msg, nil, cloud)
+	ret, _, err := chatGPTContinueWrite(msg, nil, cloud, openAI)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func chatGPTContinueWrite(msg string, contextMsgs []string, cloud bool) (ret string, retContextMsgs []string, err error) {
+func chatGPTContinueWrite(msg string, contextMsgs []string, cloud bool, openAI *conf.OpenAI) (ret string, retContextMsgs []string, err error) {
 	util.PushEndlessProgress("Requesting...")
 	defer util.ClearPushProgress(100)
 
-	if Conf.AI.OpenAI.APIMaxContexts < len(contextMsgs) {
-		contextMsgs = contextMsgs[len(contextMsgs)-Conf.AI.OpenAI.APIMaxContexts:]
+	if openAI.APIMaxContexts < len(contextMsgs) {
+		contextMsgs = contextMsgs[len(contextMsgs)-openAI.APIMaxContexts:]
 	}
 
 	var gpt GPT
 	if cloud {
 		gpt = &CloudGPT{}
 	} else {
-		gpt = &OpenAIGPT{c: util.NewOpenAIClient(Conf.AI.OpenAI.APIKey, Conf.AI.OpenAI.APIProxy, Conf.AI.OpenAI.APIBaseURL, Conf.AI.OpenAI.APIUserAgent, Conf.AI.OpenAI.APIVersion, Conf.AI.OpenAI.APIProvider)}
+		gpt = &OpenAIGPT{c: newOpenAIClient(openAI), conf: openAI}
 	}
 
 	buf := &bytes.Buffer{}
-	for i := 0; i < Conf.AI.OpenAI.APIMaxContexts; i++ {
+	for i := 0; i < openAI.APIMaxContexts; i++ {
 		part, stop, chatErr := gpt.chat(msg, contextMsgs)
 		buf.WriteString(part)
 
@@ -115,14 +117,19 @@ func chatGPTContinueWrite(msg string, contextMsgs []string, cloud bool) (ret str
 	return
 }
 
-func isOpenAIAPIEnabled() bool {
-	if "" == Conf.AI.OpenAI.APIKey {
+func isOpenAIAPIEnabled(openAI *conf.OpenAI) bool {
+	// 本地部署的兼容服务通常不需要 API Key
+	if "" == openAI.APIKey && "OpenAICompatible" != openAI.APIProvider {
 		util.PushMsg(Conf.Language(193), 5000)
 		return false
 	}
 	return true
 }
 
+func newOpenAIClient(openAI *conf.OpenAI) *openai.Client {
+	return util.NewOpenAIClient(openAI.APIKey, openAI.APIProxy, openAI.APIBaseURL, openAI.APIUserAgent, openAI.APIVersion, openAI.APIProvider)
+}
+
 func getBlocksContent(ids []string) string {
 	var nodes []*ast.Node
 	trees := map[string]*parse.Tree{}
@@ -168,11 +175,12 @@ type GPT interface {
 }
 
 type OpenAIGPT struct {
-	c *openai.Client
+	c    *openai.Client
+	conf *conf.OpenAI
 }
 
 func (gpt *OpenAIGPT) chat(msg string, contextMsgs []string) (partRet string, stop bool, err error) {
-	return util.ChatGPT(msg, contextMsgs, gpt.c, Conf.AI.OpenAI.APIModel, Conf.AI.OpenAI.APIMaxTokens, Conf.AI.OpenAI.APITemperature, Conf.AI.OpenAI.APITimeout)
+	return util.ChatGPT(msg, contextMsgs, gpt.c, gpt.conf.APIModel, gpt.conf.APIMaxTokens, gpt.conf.APITemperature, gpt.conf.APITimeout)
 }
 
 type CloudGPT struct {
@@ -0,0 +1,273 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/88250/gulu"
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	aiAskAction      = "Ask"
+	aiAskSearchLimit = 24 // 全文检索召回的块数
+	aiAskExpandLimit = 8  // 召回结果中用于引用扩展的块数
+)
+
+type AIAskCitation struct {
+	ID      string `json:"id"`
+	RootID  string `json:"rootID"`
+	Box     string `json:"box"`
+	HPath   string `json:"hPath"`
+	Content string `json:"content"`
+}
+
+type AIAskResult struct {
+	Answer     string           `json:"answer"`     // 回答中的引用已经转换为块引用 ((id 'anchor'))
+	Citations  []*AIAskCitation `json:"citations"`  // 回答中实际引用的块，按首次出现的顺序排列
+	Incomplete bool             `json:"incomplete"` // 回答因请求失败而中断
+}
+
+var aiAskCitationRegexp = regexp.MustCompile(`\(\(\s*(\d{14}-[0-9a-z]{7})(?:\s+['"][^)]*['"])?\s*\)\)|\[(\d{14}-[0-9a-z]{7})\]`)
+
+// AskAI 检索与问题相关的块作为资料回答问题，回答中使用块 ID 标注引用。
+//
+// 通过全文检索召回块，再沿着引用和反链扩展，只使用已打开、未锁定并且没有被搜索忽略的笔记本中的块。
+// boxes 不为空时只在这些笔记本中检索。动作 Ask 可以在服务配置中映射到其他模型。
+func AskAI(question string, boxes []string) (ret *AIAskResult, err error) {
+	question = strings.TrimSpace(question)
+	if "" == question {
+		err = errors.New("question is empty")
+		return
+	}
+
+	openAI := getAIActionOpenAI(aiAskAction)
+	if "" == openAI.APIKey && "OpenAICompatible" != openAI.APIProvider {
+		err = errors.New(Conf.Language(193))
+		return
+	}
+
+	FlushTxQueue()
+	sources := retrieveAIAskSources(question, boxes)
+
+	budget := openAI.APIContextSize
+	buf := bytes.Buffer{}
+	used := 0
+	sourceIDs := map[string]*sql.Block{}
+	for _, b := range sources {
+		source := fmt.Sprintf("[%s] %s\n%s", b.ID, b.HPath, strings.TrimSpace(b.Content))
+		tokens := estimateTokens(source)
+		if used+tokens > budget {
+			if 0 < used {
+				break
+			}
+			source = truncateByTokens(source, budget)
+			tokens = budget
+		}
+		used += tokens
+		sourceIDs[b.ID] = b
+		buf.WriteString(source)
+		buf.WriteString("\n\n")
+	}
+
+	systemPrompt := "You answer questions using only the numbered notes provided by the user. " +
+		"Each note starts with its block ID in square brackets. " +
+		"Cite every note you use by writing its block ID in double parentheses right after the statement, for example ((20200812220555-lj3enxa)). " +
+		"If the notes do not contain the answer, say that you don't know. Answer in the language of the question."
+	prompt := "Notes:\n\n" + strings.TrimSpace(buf.String()) + "\n\n---\n\nQuestion: " + question
+	if 1 > len(sourceIDs) {
+		prompt = "Notes: (none)\n\n---\n\nQuestion: " + question
+	}
+	reqMsgs := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: prompt},
+	}
+
+	util.PushEndlessProgress("Requesting...")
+	defer util.ClearPushProgress(100)
+	answer, err := util.ChatGPTStream(reqMsgs, newOpenAIClient(openAI), openAI.APIModel, openAI.APIMaxTokens, openAI.APITemperature, openAI.APITimeout, func(string) bool { return true })
+	if err != nil && "" == answer {
+		return
+	}
+
+	// 请求中途失败时保留错误，同时返回已经收到的不完整回答
+	ret = &AIAskResult{Citations: []*AIAskCitation{}, Incomplete: nil != err}
+	cited := map[string]bool{}
+	ret.Answer = aiAskCitationRegexp.ReplaceAllStringFunc(answer, func(m string) string {
+		sub := aiAskCitationRegexp.FindStringSubmatch(m)
+		id := sub[1]
+		if "" == id {
+			id = sub[2]
+		}
+
+		b := sourceIDs[id]
+		if nil == b {
+			// 模型编造的块 ID 无法解析为块引用，直接移除
+			return ""
+		}
+		if !cited[id] {
+			cited[id] = true
+			ret.Citations = append(ret.Citations, &AIAskCitation{ID: b.ID, RootID: b.RootID, Box: b.Box, HPath: b.HPath, Content: b.Content})
+		}
+		return "((" + id + " '" + aiAskRefAnchor(b) + "'))"
+	})
+	ret.Answer = strings.TrimSpace(ret.Answer)
+	return
+}
+
+// retrieveAIAskSources 召回与问题相关的块，全文检索命中的块在前，引用扩展的块在后。
+func retrieveAIAskSources(question string, boxes []string) (ret []*sql.Block) {
+	var allowedBoxes []string
+	for _, box := range Conf.GetOpenedBoxes() {
+		if filesys.IsBoxLocked(box.ID) {
+			continue
+		}
+		if 0 < len(boxes) && !gulu.Str.Contains(box.ID, boxes) {
+			continue
+		}
+		allowedBoxes = append(allowedBoxes, box.ID)
+	}
+	if 1 > len(allowedBoxes) {
+		return
+	}
+
+	boxFilter := buildBoxesFilter(allowedBoxes)
+	var ignoreFilter string
+	for _, line := range getSearchIgnoreLines() {
+		ignoreFilter += " AND " + line
+	}
+
+	terms := aiAskTerms(question)
+	if 1 > len(terms) {
+		return
+	}
+
+	table := "blocks_fts" // 大小写敏感
+	if !Conf.Search.CaseSensitive {
+		table = "blocks_fts_case_insensitive"
+	}
+	query := strings.Join(terms, " OR ")
+	stmt := "SELECT * FROM " + table + " WHERE (`" + table + "` MATCH '" + columnFilter() + ":(" + query + ")')" +
+		" AND type IN " + buildTypeFilter(nil) + " AND type != 'd'" + boxFilter + ignoreFilter +
+		" ORDER BY rank LIMIT " + strconv.Itoa(aiAskSearchLimit)
+	hits := sql.SelectBlocksRawStmt(stmt, 1, aiAskSearchLimit)
+
+	visited := map[string]bool{}
+	var expandIDs []string
+	for i, hit := range hits {
+		visited[hit.ID] = true
+		ret = append(ret, hit)
+		if i < aiAskExpandLimit {
+			expandIDs = append(expandIDs, hit.ID)
+		}
+	}
+
+	var relatedIDs []string
+	for _, id := range getBlocksRefDefIDs(expandIDs) {
+		if !visited[id] {
+			visited[id] = true
+			relatedIDs = append(relatedIDs, id)
+		}
+	}
+	for _, id := range expandIDs {
+		for _, refID := range sql.QueryRefIDsByDefID(id, false) {
+			if !visited[refID] {
+				visited[refID] = true
+				relatedIDs = append(relatedIDs, refID)
+			}
+		}
+	}
+	if 1 > len(relatedIDs) {
+		return
+	}
+
+	// 扩展的块同样需要经过笔记本和搜索忽略过滤
+	stmt = "SELECT * FROM blocks WHERE id IN ('" + strings.Join(relatedIDs, "','") + "')" + boxFilter + ignoreFilter
+	related := sql.SelectBlocksRawStmt(stmt, 1, len(relatedIDs))
+	ret = append(ret, related...)
+	return
+}
+
+var aiAskStopWords = []string{"the", "and", "for", "are", "was", "were", "what", "when", "where", "which", "who", "whom", "why", "how", "does", "did", "can", "could", "should", "would", "with", "about", "from", "into", "that", "this", "these", "those", "have", "has", "had", "you", "your", "our", "any", "all", "there", "their", "them", "then", "than", "not", "but", "is", "do", "of", "to", "in", "on", "at", "by", "an", "or", "my", "me", "we", "it", "be", "as", "if", "so"}
+
+// aiAskTerms 将问题拆分为全文检索词，CJK 文本按照二元组拆分。
+func aiAskTerms(question string) (ret []string) {
+	var words []string
+	var cur []rune
+	curCJK := false
+	flush := func() {
+		if 1 > len(cur) {
+			return
+		}
+		if curCJK {
+			if 1 == len(cur) {
+				words = append(words, string(cur))
+			}
+			for i := 0; i+1 < len(cur); i++ {
+				words = append(words, string(cur[i:i+2]))
+			}
+		} else if word := strings.ToLower(string(cur)); 1 < len(cur) && !gulu.Str.Contains(word, aiAskStopWords) {
+			words = append(words, word)
+		}
+		cur = nil
+	}
+	for _, r := range question {
+		isCJK := unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+		if !isCJK && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if 0 < len(cur) && isCJK != curCJK {
+			flush()
+		}
+		curCJK = isCJK
+		cur = append(cur, r)
+	}
+	flush()
+
+	words = gulu.Str.RemoveDuplicatedElem(words)
+	if 32 < len(words) {
+		words = words[:32]
+	}
+	for _, word := range words {
+		ret = append(ret, "\""+word+"\"")
+	}
+	return
+}
+
+func aiAskRefAnchor(b *sql.Block) string {
+	anchor := b.Content
+	if "" != b.Name {
+		anchor = b.Name
+	}
+	anchor = strings.NewReplacer("'", "", "\"", "", "(", "", ")", "", "\n", " ").Replace(anchor)
+	anchor = strings.TrimSpace(gulu.Str.SubStr(anchor, Conf.Editor.BlockRefDynamicAnchorTextMaxLen))
+	if "" == anchor {
+		anchor = b.ID
+	}
+	return anchor
+}
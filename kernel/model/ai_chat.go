@@ -0,0 +1,309 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	aiConversationAttr = "custom-ai-conversation" // 标识 AI 对话文档
+	aiRoleAttr         = "custom-ai-role"         // 标识对话消息的角色，消息从带有该属性的标题开始
+	aiIncompleteAttr   = "custom-ai-incomplete"   // 标识因请求失败而不完整的回答
+
+	aiConversationHPath = "/AI Conversations/"
+)
+
+var ErrAIConversationNotFound = errors.New("AI conversation not found")
+
+type AIChatMessage struct {
+	Role       string `json:"role"`
+	Content    string `json:"content"`
+	Incomplete bool   `json:"incomplete,omitempty"` // 回答因请求失败而中断
+}
+
+// ChatGPTStream 以流式方式进行 AI 对话。
+//
+// conversationID 为空时在 boxID 指定的笔记本下新建对话文档，否则在该对话文档中继续对话。
+// ids 为选中的块，会和这些块的引用、反链一起在 token 预算内组装为上下文。
+// 每收到一段增量内容就调用 onDelta，onDelta 返回 false 时中止请求，已经收到的内容仍然会保存到对话文档中。
+// 请求中途失败时已经收到的内容会标记为不完整并保存，同时返回请求的错误。
+func ChatGPTStream(conversationID, boxID, msg string, ids []string, onDelta func(delta string) bool) (retConversationID, ret string, err error) {
+	msg = strings.TrimSpace(msg)
+	if "" == msg {
+		return
+	}
+
+	openAI := Conf.AI.OpenAI
+	if "" == openAI.APIKey && "OpenAICompatible" != openAI.APIProvider {
+		err = errors.New(Conf.Language(193))
+		return
+	}
+
+	var history []*AIChatMessage
+	if "" != conversationID {
+		if history, err = GetAIConversation(conversationID); err != nil {
+			return
+		}
+	}
+
+	if openAI.APIMaxContexts < len(history) {
+		history = history[len(history)-openAI.APIMaxContexts:]
+	}
+
+	var reqMsgs []openai.ChatCompletionMessage
+	for _, m := range history {
+		reqMsgs = append(reqMsgs, openai.ChatCompletionMessage{Role: m.Role, Content: m.Content})
+	}
+	prompt := msg
+	if blocksContext := buildAIChatContext(ids, openAI.APIContextSize); "" != blocksContext {
+		prompt = blocksContext + "\n\n---\n\n" + msg
+	}
+	reqMsgs = append(reqMsgs, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: prompt})
+
+	ret, err = util.ChatGPTStream(reqMsgs, newOpenAIClient(openAI), openAI.APIModel, openAI.APIMaxTokens, openAI.APITemperature, openAI.APITimeout, onDelta)
+	if err != nil && "" == ret {
+		return
+	}
+
+	retConversationID, saveErr := saveAIConversationMessages(conversationID, boxID, msg, ret, nil != err)
+	if nil == err {
+		err = saveErr
+	}
+	return
+}
+
+// GetAIConversation 从对话文档中解析对话消息。
+func GetAIConversation(conversationID string) (ret []*AIChatMessage, err error) {
+	tree, err := LoadTreeByBlockID(conversationID)
+	if err != nil {
+		return
+	}
+	if "true" != tree.Root.IALAttr(aiConversationAttr) {
+		err = ErrAIConversationNotFound
+		return
+	}
+
+	luteEngine := util.NewLute()
+	var cur *AIChatMessage
+	buf := bytes.Buffer{}
+	flush := func() {
+		if nil != cur {
+			cur.Content = strings.TrimSpace(buf.String())
+			ret = append(ret, cur)
+		}
+		buf.Reset()
+	}
+	for child := tree.Root.FirstChild; nil != child; child = child.Next {
+		if role := child.IALAttr(aiRoleAttr); ast.NodeHeading == child.Type && "" != role {
+			flush()
+			cur = &AIChatMessage{Role: role, Incomplete: "true" == child.IALAttr(aiIncompleteAttr)}
+			continue
+		}
+
+		if nil == cur || !child.IsBlock() {
+			continue
+		}
+		buf.WriteString(treenode.ExportNodeStdMd(child, luteEngine))
+		buf.WriteString("\n\n")
+	}
+	flush()
+	return
+}
+
+func saveAIConversationMessages(conversationID, boxID, question, answer string, incomplete bool) (retID string, err error) {
+	assistantIAL := aiRoleAttr + "=\"" + openai.ChatMessageRoleAssistant + "\""
+	if incomplete {
+		assistantIAL += " " + aiIncompleteAttr + "=\"true\""
+	}
+
+	md := bytes.Buffer{}
+	md.WriteString("### User\n{: " + aiRoleAttr + "=\"" + openai.ChatMessageRoleUser + "\"}\n\n")
+	md.WriteString(question)
+	md.WriteString("\n\n### Assistant\n{: " + assistantIAL + "}\n\n")
+	md.WriteString(answer)
+	md.WriteString("\n")
+
+	if "" == conversationID {
+		if "" == boxID {
+			if boxes := Conf.GetOpenedBoxes(); 0 < len(boxes) {
+				boxID = boxes[0].ID
+			}
+		}
+
+		title := strings.TrimSpace(gulu.Str.SubStr(strings.Split(question, "\n")[0], 32))
+		title = strings.ReplaceAll(title, "/", " ")
+		if "" == title {
+			title = time.Now().Format("2006-01-02 15:04:05")
+		}
+		if retID, err = CreateWithMarkdown("", boxID, aiConversationHPath+title, md.String(), "", "", false, ""); err != nil {
+			return
+		}
+		err = SetBlockAttrs(retID, map[string]string{aiConversationAttr: "true"})
+		return
+	}
+
+	retID = conversationID
+	luteEngine := util.NewLute()
+	transactions := []*Transaction{{
+		DoOperations: []*Operation{{
+			Action:   "appendInsert",
+			Data:     luteEngine.Md2BlockDOM(md.String(), false),
+			ParentID: conversationID,
+		}},
+	}}
+	PerformTransactions(&transactions)
+	FlushTxQueue()
+	ReloadProtyle(conversationID)
+	return
+}
+
+// buildAIChatContext 组装选中块的内容作为上下文，预算有剩余时依次加入选中块引用的块和引用了选中块的块。
+func buildAIChatContext(ids []string, budget int) string {
+	if 1 > len(ids) {
+		return ""
+	}
+
+	buf := bytes.Buffer{}
+	used := 0
+	add := func(content string) bool {
+		content = strings.TrimSpace(content)
+		if "" == content {
+			return true
+		}
+
+		tokens := estimateTokens(content)
+		if used+tokens > budget {
+			return false
+		}
+		used += tokens
+		buf.WriteString(content)
+		buf.WriteString("\n\n")
+		return true
+	}
+
+	// 选中块的内容总是保留，超出预算时截断
+	selected := strings.TrimSpace(getBlocksContent(ids))
+	if tokens := estimateTokens(selected); tokens > budget {
+		selected = truncateByTokens(selected, budget)
+	}
+	add(selected)
+
+	visited := map[string]bool{}
+	for _, id := range ids {
+		visited[id] = true
+	}
+
+	var relatedIDs []string
+	for _, id := range getBlocksRefDefIDs(ids) {
+		if !visited[id] {
+			visited[id] = true
+			relatedIDs = append(relatedIDs, id)
+		}
+	}
+	for _, id := range ids {
+		for _, refID := range sql.QueryRefIDsByDefID(id, false) {
+			if !visited[refID] {
+				visited[refID] = true
+				relatedIDs = append(relatedIDs, refID)
+			}
+		}
+	}
+
+	for _, id := range relatedIDs {
+		if !add(getBlocksContent([]string{id})) {
+			break
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// getBlocksRefDefIDs 获取块中引用的定义块 ID。
+func getBlocksRefDefIDs(ids []string) (ret []string) {
+	trees := map[string]*parse.Tree{}
+	for _, id := range ids {
+		bt := treenode.GetBlockTree(id)
+		if nil == bt {
+			continue
+		}
+
+		tree := trees[bt.RootID]
+		if nil == tree {
+			tree, _ = LoadTreeByBlockID(bt.RootID)
+			if nil == tree {
+				continue
+			}
+			trees[bt.RootID] = tree
+		}
+
+		node := treenode.GetNodeInTree(tree, id)
+		if nil == node {
+			continue
+		}
+
+		ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || !treenode.IsBlockRef(n) {
+				return ast.WalkContinue
+			}
+
+			if defID, _, _ := treenode.GetBlockRef(n); "" != defID {
+				ret = append(ret, defID)
+			}
+			return ast.WalkContinue
+		})
+	}
+	ret = gulu.Str.RemoveDuplicatedElem(ret)
+	return
+}
+
+// estimateTokens 粗略估算文本的 token 数：CJK 字符按每个字符一个 token 计算，其他字符按每四个字符一个 token 计算。
+func estimateTokens(content string) (ret int) {
+	others := 0
+	for _, r := range content {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			ret++
+		} else {
+			others++
+		}
+	}
+	ret += (others + 3) / 4
+	return
+}
+
+func truncateByTokens(content string, budget int) string {
+	if 1 > budget {
+		return ""
+	}
+
+	tokens := estimateTokens(content)
+	runes := utf8.RuneCountInString(content)
+	keep := runes * budget / tokens
+	return gulu.Str.SubStr(content, keep)
+}
@@ -0,0 +1,187 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var ErrAIProfileNotFound = errors.New("AI profile not found")
+
+func GetAIProfiles() (profiles []*conf.AIProfile, actions map[string]string) {
+	return Conf.GetAIProfiles(), Conf.GetAIActions()
+}
+
+// SetAIProfile 新建或者更新服务配置，ID 为空时新建。
+func SetAIProfile(profile *conf.AIProfile) (ret *conf.AIProfile, err error) {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if "" == profile.Name {
+		err = errors.New("AI profile name is empty")
+		return
+	}
+	NormalizeAIProfile(profile)
+
+	err = Conf.UpdateAIProfiles(func(profiles []*conf.AIProfile, actions map[string]string) ([]*conf.AIProfile, error) {
+		if "" == profile.ID {
+			profile.ID = ast.NewNodeID()
+			return append(profiles, profile), nil
+		}
+		for i, old := range profiles {
+			if old.ID == profile.ID {
+				profiles[i] = profile
+				return profiles, nil
+			}
+		}
+		return nil, ErrAIProfileNotFound
+	})
+	if err != nil {
+		return
+	}
+	Conf.Save()
+
+	ret = profile
+	return
+}
+
+// RemoveAIProfile 删除服务配置，映射到该配置的动作回退到默认服务配置。
+func RemoveAIProfile(id string) {
+	Conf.UpdateAIProfiles(func(profiles []*conf.AIProfile, actions map[string]string) ([]*conf.AIProfile, error) {
+		for i, profile := range profiles {
+			if profile.ID == id {
+				profiles = append(profiles[:i], profiles[i+1:]...)
+				break
+			}
+		}
+		for action, profileID := range actions {
+			if profileID == id {
+				delete(actions, action)
+			}
+		}
+		return profiles, nil
+	})
+	Conf.Save()
+}
+
+// SetAIActionProfile 设置动作使用的服务配置，profileID 为空时使用默认服务配置。
+func SetAIActionProfile(action, profileID string) (err error) {
+	action = strings.TrimSpace(action)
+	if "" == action {
+		err = errors.New("AI action is empty")
+		return
+	}
+
+	// 在同一个锁内检查服务配置是否存在，避免映射到同时被删除的服务配置
+	err = Conf.UpdateAIProfiles(func(profiles []*conf.AIProfile, actions map[string]string) ([]*conf.AIProfile, error) {
+		if "" == profileID {
+			delete(actions, action)
+			return profiles, nil
+		}
+		for _, profile := range profiles {
+			if profile.ID == profileID {
+				actions[action] = profileID
+				return profiles, nil
+			}
+		}
+		return nil, ErrAIProfileNotFound
+	})
+	if err != nil {
+		return
+	}
+	Conf.Save()
+	return
+}
+
+// getAIActionOpenAI 返回动作使用的服务配置。
+//
+// 动作文本完全匹配优先，其次使用最长的前缀匹配（忽略大小写），比如 "Translate" 可以匹配所有翻译动作。
+func getAIActionOpenAI(action string) *conf.OpenAI {
+	action = strings.TrimSpace(action)
+	actions := Conf.GetAIActions()
+	profileID := actions[action]
+	if "" == profileID {
+		var keys []string
+		for key := range actions {
+			if "" != key && strings.HasPrefix(strings.ToLower(action), strings.ToLower(key)) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+		if 0 < len(keys) {
+			profileID = actions[keys[0]]
+		}
+	}
+
+	if profile := getAIProfile(profileID); nil != profile {
+		return profile.OpenAI
+	}
+	return Conf.AI.OpenAI
+}
+
+func getAIProfile(id string) *conf.AIProfile {
+	if "" == id {
+		return nil
+	}
+	for _, profile := range Conf.GetAIProfiles() {
+		if profile.ID == id {
+			return profile
+		}
+	}
+	return nil
+}
+
+// NormalizeAIProfile 修正服务配置中缺失或者超出范围的参数。
+func NormalizeAIProfile(profile *conf.AIProfile) {
+	openAI := profile.OpenAI
+	if nil == openAI {
+		profile.OpenAI = conf.NewAIProfile().OpenAI
+		return
+	}
+
+	if "" == openAI.APIModel {
+		openAI.APIModel = conf.NewAIProfile().OpenAI.APIModel
+	}
+	if "" == openAI.APIUserAgent || strings.HasPrefix(openAI.APIUserAgent, "SiYuan/") {
+		openAI.APIUserAgent = util.UserAgent
+	}
+	if "" == openAI.APIProvider {
+		openAI.APIProvider = "OpenAI"
+	}
+	if 5 > openAI.APITimeout {
+		openAI.APITimeout = 5
+	}
+	if 600 < openAI.APITimeout {
+		openAI.APITimeout = 600
+	}
+	if 0 > openAI.APIMaxTokens {
+		openAI.APIMaxTokens = 0
+	}
+	if 0 >= openAI.APITemperature || 2 < openAI.APITemperature {
+		openAI.APITemperature = 1.0
+	}
+	if 1 > openAI.APIMaxContexts || 64 < openAI.APIMaxContexts {
+		openAI.APIMaxContexts = 7
+	}
+	if 1 > openAI.APIContextSize {
+		openAI.APIContextSize = 4096
+	}
+}
@@ -0,0 +1,95 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestGetAIActionOpenAI(t *testing.T) {
+	oldConf := Conf
+	defer func() { Conf = oldConf }()
+
+	Conf = NewAppConf()
+	Conf.AI = conf.NewAI()
+	translate := &conf.AIProfile{ID: "translate", OpenAI: &conf.OpenAI{APIModel: "translate-model"}}
+	english := &conf.AIProfile{ID: "english", OpenAI: &conf.OpenAI{APIModel: "english-model"}}
+	Conf.AI.Profiles = []*conf.AIProfile{translate, english}
+	Conf.AI.Actions = map[string]string{
+		"Translate":            translate.ID,
+		"Translate to English": english.ID,
+		"Summarize":            "removed-profile",
+	}
+
+	cases := []struct {
+		action   string
+		expected *conf.OpenAI
+	}{
+		{"Translate", translate.OpenAI},
+		{" Translate to English ", english.OpenAI},
+		{"translate to english, keep the formatting", english.OpenAI},
+		{"TRANSLATE to Chinese", translate.OpenAI},
+		{"Summarize", Conf.AI.OpenAI},
+		{"Polish", Conf.AI.OpenAI},
+		{"", Conf.AI.OpenAI},
+	}
+	for _, c := range cases {
+		if got := getAIActionOpenAI(c.action); got != c.expected {
+			t.Errorf("action [%s] expected model [%s], got [%s]", c.action, c.expected.APIModel, got.APIModel)
+		}
+	}
+}
+
+func TestUpdateAIActionsConcurrently(t *testing.T) {
+	oldConf := Conf
+	defer func() { Conf = oldConf }()
+
+	Conf = NewAppConf()
+	Conf.AI = conf.NewAI()
+	profile := &conf.AIProfile{ID: "translate", OpenAI: &conf.OpenAI{APIModel: "translate-model"}}
+	Conf.AI.Profiles = []*conf.AIProfile{profile}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Conf.UpdateAIActions(func(actions map[string]string) {
+					actions["Translate"] = profile.ID
+				})
+				Conf.UpdateAIActions(func(actions map[string]string) {
+					delete(actions, "Translate")
+				})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				getAIActionOpenAI("Translate to English")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if actions := Conf.GetAIActions(); 0 != len(actions) {
+		t.Fatalf("expected no action mapping, got [%v]", actions)
+	}
+}
@@ -0,0 +1,345 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// DuplicateAssetGroup 描述一组内容完全相同的资源文件。
+type DuplicateAssetGroup struct {
+	Hash   string   `json:"hash"`
+	Size   int64    `json:"size"`   // 单个文件大小
+	Wasted int64    `json:"wasted"` // 去重后可以节省的空间
+	Keep   string   `json:"keep"`   // 去重时默认保留的资源文件
+	Paths  []string `json:"paths"`
+}
+
+type duplicateAssetFile struct {
+	path    string
+	absPath string
+	size    int64
+	modTime int64
+}
+
+// GetDuplicateAssets 按照内容摘要对 data/assets 下的资源文件分组，返回包含多个文件的分组。
+//
+// 只有大小相同的文件才需要计算摘要。
+func GetDuplicateAssets() (ret []*DuplicateAssetGroup) {
+	ret = []*DuplicateAssetGroup{}
+
+	bySize := map[int64][]*duplicateAssetFile{}
+	assetsDir := util.GetDataAssetsAbsPath()
+	filelock.Walk(assetsDir, func(absPath string, d fs.DirEntry, err error) error {
+		if nil != err || nil == d || assetsDir == absPath {
+			return nil
+		}
+		if isSkipFile(d.Name()) || filelock.IsHidden(absPath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".sya") {
+			return nil
+		}
+
+		info, infoErr := d.Info()
+		if nil != infoErr || 1 > info.Size() {
+			return nil
+		}
+
+		p := filepath.ToSlash(absPath)
+		p = p[strings.Index(p, "assets/"):]
+		bySize[info.Size()] = append(bySize[info.Size()], &duplicateAssetFile{path: p, absPath: absPath, size: info.Size(), modTime: info.ModTime().UnixNano()})
+		return nil
+	})
+
+	for _, files := range bySize {
+		if 2 > len(files) {
+			continue
+		}
+
+		byHash := map[string][]*duplicateAssetFile{}
+		for _, file := range files {
+			hash, err := util.GetEtag(file.absPath)
+			if err != nil {
+				logging.LogErrorf("calc asset [%s] hash failed: %s", file.absPath, err)
+				continue
+			}
+			byHash[hash] = append(byHash[hash], file)
+		}
+
+		for hash, group := range byHash {
+			if 2 > len(group) {
+				continue
+			}
+
+			sort.Slice(group, func(i, j int) bool {
+				// 优先保留带有 PDF 标注的文件，其次保留最早的文件
+				iSya, jSya := filelock.IsExist(group[i].absPath+".sya"), filelock.IsExist(group[j].absPath+".sya")
+				if iSya != jSya {
+					return iSya
+				}
+				if group[i].modTime != group[j].modTime {
+					return group[i].modTime < group[j].modTime
+				}
+				return group[i].path < group[j].path
+			})
+
+			g := &DuplicateAssetGroup{Hash: hash, Size: group[0].size, Wasted: group[0].size * int64(len(group)-1), Keep: group[0].path}
+			for _, file := range group {
+				g.Paths = append(g.Paths, file.path)
+			}
+			ret = append(ret, g)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Wasted > ret[j].Wasted })
+	return
+}
+
+// DedupeAssets 对指定内容摘要的重复资源文件去重，keep 为空时使用默认保留的资源文件。
+//
+// 先在内存中改写所有文档和属性视图中的引用，全部成功后再统一写入，改写前的文档和属性视图可以从数据历史中恢复，
+// 最后将重复的资源文件移入数据历史。
+// 带有 PDF 标注的重复文件不会被移除。
+func DedupeAssets(hash, keep string) (removed []string, err error) {
+	var group *DuplicateAssetGroup
+	for _, g := range GetDuplicateAssets() {
+		if g.Hash == hash {
+			group = g
+			break
+		}
+	}
+	if nil == group {
+		err = errors.New("duplicate assets not found")
+		return
+	}
+	if "" == keep {
+		keep = group.Keep
+	}
+	if !gulu.Str.Contains(keep, group.Paths) {
+		err = fmt.Errorf("asset [%s] is not in the duplicate group", keep)
+		return
+	}
+
+	var oldNew []string
+	for _, p := range group.Paths {
+		if p == keep || filelock.IsExist(filepath.Join(util.DataDir, p+".sya")) {
+			continue
+		}
+		removed = append(removed, p)
+		oldNew = append(oldNew, p, keep)
+	}
+	if 1 > len(removed) {
+		return
+	}
+
+	util.PushEndlessProgress(Conf.Language(110))
+	defer util.PushClearProgress()
+
+	if err = rewriteAssetsRefs(oldNew); err != nil {
+		return
+	}
+
+	historyDir, err := GetHistoryDir(HistoryOpClean)
+	if err != nil {
+		return
+	}
+	for _, p := range removed {
+		absPath := filepath.Join(util.DataDir, p)
+		if err = filelock.Copy(absPath, filepath.Join(historyDir, p)); err != nil {
+			logging.LogErrorf("backup duplicate asset [%s] failed: %s", absPath, err)
+			return
+		}
+		if err = filelock.Remove(absPath); err != nil {
+			logging.LogErrorf("remove duplicate asset [%s] failed: %s", absPath, err)
+			return
+		}
+		removeAssetThumbnail(absPath)
+		util.RemoveAssetText(p)
+		cache.RemoveAsset(p)
+	}
+	indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+
+	logging.LogInfof("deduplicated assets [%s], kept [%s], removed [%d]", hash, keep, len(removed))
+	IncSync()
+	return
+}
+
+// rewriteAssetsRefs 将所有文档和属性视图中的资源文件引用按照 oldNew 成对替换。
+//
+// 先在内存中完成全部改写，任一文档无法改写时不写入任何数据。写入前将原文件存入数据历史，写入失败时回滚已经写入的文件。
+func rewriteAssetsRefs(oldNew []string) (err error) {
+	FlushTxQueue()
+	replacer := strings.NewReplacer(oldNew...)
+	containsOld := func(data []byte) bool {
+		for i := 0; i < len(oldNew); i += 2 {
+			if strings.Contains(string(data), oldNew[i]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	type pendingWrite struct {
+		absPath string
+		orig    []byte // 改写前的文件内容，用于生成历史和失败时回滚
+		data    []byte
+		tree    *parse.Tree
+		avID    string
+	}
+	var writes []*pendingWrite
+
+	notebooks, err := ListNotebooks()
+	if err != nil {
+		return
+	}
+
+	luteEngine := util.NewLute()
+	for _, notebook := range notebooks {
+		for _, paths := range pagedPaths(filepath.Join(util.DataDir, notebook.ID), 32) {
+			for _, treeAbsPath := range paths {
+				orig, readErr := filelock.ReadFile(treeAbsPath)
+				if nil != readErr {
+					err = readErr
+					return
+				}
+
+				data, readErr := filesys.DecryptTreeData(orig)
+				if nil != readErr {
+					// 锁定的加密笔记本无法更新资源引用，中止改写避免引用失效
+					err = fmt.Errorf("decrypt data [path=%s] failed: %s", treeAbsPath, readErr)
+					return
+				}
+				if !containsOld(data) {
+					continue
+				}
+
+				data = []byte(replacer.Replace(string(data)))
+				p := filepath.ToSlash(strings.TrimPrefix(treeAbsPath, filepath.Join(util.DataDir, notebook.ID)))
+				tree, parseErr := filesys.LoadTreeByData(data, notebook.ID, p, luteEngine)
+				if nil != parseErr {
+					err = fmt.Errorf("parse json to tree [%s] failed: %s", treeAbsPath, parseErr)
+					return
+				}
+
+				writeData, encryptErr := filesys.EncryptTreeData(notebook.ID, data)
+				if nil != encryptErr {
+					err = encryptErr
+					return
+				}
+				writes = append(writes, &pendingWrite{absPath: treeAbsPath, orig: orig, data: writeData, tree: tree})
+			}
+		}
+	}
+
+	storageAvDir := filepath.Join(util.DataDir, "storage", "av")
+	if gulu.File.IsDir(storageAvDir) {
+		entries, readErr := os.ReadDir(storageAvDir)
+		if nil != readErr {
+			err = readErr
+			return
+		}
+
+		for _, entry := range entries {
+			avID := strings.TrimSuffix(entry.Name(), ".json")
+			if !strings.HasSuffix(entry.Name(), ".json") || !ast.IsNodeIDPattern(avID) {
+				continue
+			}
+
+			avAbsPath := filepath.Join(storageAvDir, entry.Name())
+			data, readDataErr := filelock.ReadFile(avAbsPath)
+			if nil != readDataErr {
+				err = readDataErr
+				return
+			}
+			if !containsOld(data) {
+				continue
+			}
+			writes = append(writes, &pendingWrite{absPath: avAbsPath, orig: data, data: []byte(replacer.Replace(string(data))), avID: avID})
+		}
+	}
+	if 1 > len(writes) {
+		return
+	}
+
+	// 改写前先将原文件存入数据历史，改写后可以从历史中恢复
+	historyDir, err := GetHistoryDir(HistoryOpReplace)
+	if err != nil {
+		return
+	}
+	for _, w := range writes {
+		relPath, relErr := filepath.Rel(util.DataDir, w.absPath)
+		if nil != relErr {
+			err = relErr
+			return
+		}
+		historyPath := filepath.Join(historyDir, relPath)
+		if err = os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+			return
+		}
+		if err = gulu.File.WriteFileSafer(historyPath, w.orig, 0644); err != nil {
+			logging.LogErrorf("generate history [path=%s] failed: %s", historyPath, err)
+			return
+		}
+	}
+	indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+
+	for i, w := range writes {
+		if err = filelock.WriteFile(w.absPath, w.data); err != nil {
+			logging.LogErrorf("write data [path=%s] failed: %s", w.absPath, err)
+			// 写入失败时回滚已经写入的文件，保证引用要么全部改写，要么全部保持不变
+			for _, written := range writes[:i] {
+				if rollbackErr := filelock.WriteFile(written.absPath, written.orig); nil != rollbackErr {
+					logging.LogErrorf("rollback data [path=%s] failed: %s", written.absPath, rollbackErr)
+				}
+			}
+			return
+		}
+	}
+
+	for _, w := range writes {
+		if nil != w.tree {
+			treenode.UpsertBlockTree(w.tree)
+			sql.UpsertTreeQueue(w.tree)
+			ReloadProtyle(w.tree.ID)
+			util.PushEndlessProgress(fmt.Sprintf(Conf.Language(111), util.EscapeHTML(w.tree.Root.IALAttr("title"))))
+		} else {
+			ReloadAttrView(w.avID)
+		}
+	}
+	return
+}
@@ -0,0 +1,261 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/klippa-app/go-pdfium/webassembly"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	_ "golang.org/x/image/webp"
+)
+
+func GetAssetMeta(p string) (ret *sql.AssetMeta) {
+	return sql.GetAssetMeta(p)
+}
+
+// IndexAssetMetaJob 增量索引资源文件元数据，补齐新增和变更的资源文件，移除已经删除的资源文件。
+func IndexAssetMetaJob() {
+	task.AppendTask(task.AssetMetaDatabaseIndex, indexAssetMetas)
+}
+
+func indexAssetMetas() {
+	defer logging.Recover()
+
+	assetsDir := util.GetDataAssetsAbsPath()
+	if !gulu.File.IsDir(assetsDir) {
+		return
+	}
+
+	indexed := sql.GetAssetMetaUpdated()
+	indexer := &assetMetaIndexer{}
+	defer indexer.close()
+
+	var assetMetas []*sql.AssetMeta
+	filelock.Walk(assetsDir, func(absPath string, d fs.DirEntry, err error) error {
+		if nil != err || nil == d || assetsDir == absPath {
+			return nil
+		}
+		if isSkipFile(d.Name()) || filelock.IsHidden(absPath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || "" == assetMetaType(absPath) {
+			return nil
+		}
+
+		info, infoErr := d.Info()
+		if nil != infoErr {
+			return nil
+		}
+
+		p := "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir))
+		updated, ok := indexed[p]
+		delete(indexed, p)
+		if ok && updated == info.ModTime().Unix() {
+			return nil
+		}
+
+		if assetMeta := indexer.parse(absPath, info); nil != assetMeta {
+			assetMetas = append(assetMetas, assetMeta)
+		}
+		return nil
+	})
+
+	sql.IndexAssetMetaQueue(assetMetas)
+	for p := range indexed {
+		sql.DeleteAssetMetaQueue(p)
+	}
+	if 0 < len(assetMetas) || 0 < len(indexed) {
+		logging.LogInfof("indexed asset meta [%d], removed [%d]", len(assetMetas), len(indexed))
+	}
+}
+
+func indexAssetMeta(absPath string) {
+	defer logging.Recover()
+
+	if "" == assetMetaType(absPath) {
+		return
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return
+	}
+
+	indexer := &assetMetaIndexer{}
+	defer indexer.close()
+	if assetMeta := indexer.parse(absPath, info); nil != assetMeta {
+		sql.IndexAssetMetaQueue([]*sql.AssetMeta{assetMeta})
+	}
+}
+
+func removeIndexAssetMeta(absPath string) {
+	defer logging.Recover()
+
+	assetsDir := util.GetDataAssetsAbsPath()
+	p := "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir))
+	sql.DeleteAssetMetaQueue(p)
+}
+
+func assetMetaType(absPath string) string {
+	ext := strings.ToLower(filepath.Ext(absPath))
+	switch {
+	case ".pdf" == ext:
+		return "pdf"
+	case ".svg" != ext && gulu.Str.Contains(ext, util.SiYuanAssetsImage):
+		return "image"
+	case gulu.Str.Contains(ext, util.SiYuanAssetsAudio):
+		return "audio"
+	case gulu.Str.Contains(ext, util.SiYuanAssetsVideo):
+		return "video"
+	}
+	return ""
+}
+
+// assetMetaIndexer 提取资源文件元数据，PDF 解析器在第一次使用时初始化并在批量索引过程中复用。
+type assetMetaIndexer struct {
+	pdfPool pdfium.Pool
+}
+
+func (indexer *assetMetaIndexer) close() {
+	if nil != indexer.pdfPool {
+		indexer.pdfPool.Close()
+	}
+}
+
+func (indexer *assetMetaIndexer) parse(absPath string, info fs.FileInfo) (ret *sql.AssetMeta) {
+	assetsDir := util.GetDataAssetsAbsPath()
+	p := "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir))
+	ret = &sql.AssetMeta{
+		ID:      ast.NewNodeID(),
+		Path:    p,
+		Name:    util.RemoveID(filepath.Base(p)),
+		Ext:     strings.ToLower(filepath.Ext(p)),
+		Type:    assetMetaType(absPath),
+		Size:    info.Size(),
+		Updated: info.ModTime().Unix(),
+	}
+
+	switch ret.Type {
+	case "image":
+		indexer.parseImage(absPath, ret)
+	case "audio", "video":
+		ret.Duration = util.MediaDuration(absPath)
+	case "pdf":
+		indexer.parsePDF(absPath, ret)
+	}
+	return
+}
+
+func (indexer *assetMetaIndexer) parseImage(absPath string, assetMeta *sql.AssetMeta) {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	// EXIF 和图片尺寸都位于文件头部，TIFF 的 IFD 可能位于任意位置，需要读取整个文件
+	var data []byte
+	if ".tif" == assetMeta.Ext || ".tiff" == assetMeta.Ext {
+		data, err = io.ReadAll(io.LimitReader(f, 64*1024*1024))
+	} else {
+		data, err = io.ReadAll(io.LimitReader(f, 512*1024))
+	}
+	if err != nil {
+		return
+	}
+
+	if config, _, decodeErr := image.DecodeConfig(bytes.NewReader(data)); nil == decodeErr {
+		assetMeta.Width, assetMeta.Height = config.Width, config.Height
+	}
+
+	exif := util.ParseExif(util.ImageExif(data))
+	if nil == exif {
+		return
+	}
+	if !exif.Taken.IsZero() {
+		assetMeta.Taken = exif.Taken.Format("20060102150405")
+	}
+	assetMeta.Camera = strings.TrimSpace(exif.Make + " " + strings.TrimPrefix(exif.Model, exif.Make))
+	if exif.HasGPS {
+		assetMeta.Lat, assetMeta.Lng = exif.Lat, exif.Lng
+	}
+}
+
+func (indexer *assetMetaIndexer) parsePDF(absPath string, assetMeta *sql.AssetMeta) {
+	if util.ContainerIOS == util.Container || util.ContainerAndroid == util.Container || util.ContainerHarmony == util.Container {
+		// 移动端不解析 PDF，和资源文件内容搜索保持一致
+		return
+	}
+	if PDFAssetContentMaxSize < uint64(assetMeta.Size) {
+		return
+	}
+
+	pdfData, err := os.ReadFile(absPath)
+	if err != nil {
+		return
+	}
+
+	if nil == indexer.pdfPool {
+		if indexer.pdfPool, err = webassembly.Init(webassembly.Config{MinIdle: 1, MaxIdle: 1, MaxTotal: 1}); err != nil {
+			logging.LogErrorf("init pdfium failed: %s", err)
+			return
+		}
+	}
+
+	instance, err := indexer.pdfPool.GetInstance(30 * time.Second)
+	if err != nil {
+		logging.LogErrorf("get pdfium instance failed: %s", err)
+		return
+	}
+	defer instance.Close()
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{File: &pdfData})
+	if err != nil {
+		logging.LogWarnf("open PDF [%s] failed: %s", absPath, err)
+		return
+	}
+	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: doc.Document})
+
+	if pc, pcErr := instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{Document: doc.Document}); nil == pcErr {
+		assetMeta.Pages = pc.PageCount
+	}
+	if author, metaErr := instance.FPDF_GetMetaText(&requests.FPDF_GetMetaText{Document: doc.Document, Tag: "Author"}); nil == metaErr {
+		assetMeta.Author = strings.TrimSpace(author.Value)
+	}
+	if title, metaErr := instance.FPDF_GetMetaText(&requests.FPDF_GetMetaText{Document: doc.Document, Tag: "Title"}); nil == metaErr {
+		assetMeta.Title = strings.TrimSpace(title.Value)
+	}
+}
@@ -146,11 +146,13 @@ const (
 
 func HandleAssetsRemoveEvent(assetAbsPath string) {
 	removeIndexAssetContent(assetAbsPath)
+	removeIndexAssetMeta(assetAbsPath)
 	removeAssetThumbnail(assetAbsPath)
 }
 
 func HandleAssetsChangeEvent(assetAbsPath string) {
 	indexAssetContent(assetAbsPath)
+	indexAssetMeta(assetAbsPath)
 	removeAssetThumbnail(assetAbsPath)
 }
 
@@ -871,20 +873,7 @@ func TryLazyLoadAsset(relativePath string) (success bool, err error) {
 	}
 
 	logging.LogInfof("attempting lazy load for asset [%s]", relativePath)
-	repo, repoErr := newRepository()
-	if repoErr != nil {
-		logging.LogErrorf("get repository for lazy load failed: %s", repoErr)
-		return false, repoErr
-	}
-
-	if repo == nil {
-		logging.LogWarnf("repository is nil, cannot perform lazy load for [%s]", relativePath)
-		return false, nil
-	}
-
-	// 触发懒加载
-	if loadErr := repo.LoadAssetOnDemand(relativePath); loadErr != nil {
-		logging.LogErrorf("lazy load asset [%s] failed: %s", relativePath, loadErr)
+	if loadErr := lazyCache.load(relativePath); loadErr != nil {
 		return false, loadErr
 	}
 
@@ -906,6 +895,9 @@ func GetAssetAbsPath(relativePath string) (ret string, err error) {
 			err = fmt.Errorf("[%s] is not sub path of workspace", ret)
 			return
 		}
+		if Conf.Repo.LazyLoadEnabled && strings.HasPrefix(relativePath, "assets/") {
+			lazyCache.touch(relativePath)
+		}
 		return
 	}
 
@@ -1270,12 +1262,24 @@ func RenameAsset(oldPath, newName string) (newPath string, err error) {
 					return
 				}
 
+				if data, readErr = filesys.DecryptTreeData(data); nil != readErr {
+					// 锁定的加密笔记本无法更新资源引用
+					logging.LogWarnf("decrypt data [path=%s] failed: %s", treeAbsPath, readErr)
+					continue
+				}
+
 				if !bytes.Contains(data, []byte(oldName)) {
 					continue
 				}
 
 				data = bytes.Replace(data, []byte(oldName), []byte(newName), -1)
-				if writeErr := filelock.WriteFile(treeAbsPath, data); nil != writeErr {
+				writeData, encryptErr := filesys.EncryptTreeData(notebook.ID, data)
+				if nil != encryptErr {
+					logging.LogErrorf("encrypt data [path=%s] failed: %s", treeAbsPath, encryptErr)
+					err = encryptErr
+					return
+				}
+				if writeErr := filelock.WriteFile(treeAbsPath, writeData); nil != writeErr {
 					logging.LogErrorf("write data [path=%s] failed: %s", treeAbsPath, writeErr)
 					err = writeErr
 					return
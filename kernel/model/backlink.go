@@ -147,6 +147,7 @@ func GetBacklinkDoc(defID, refTreeID, keyword string, containChildren, highlight
 	rootID := sqlBlock.RootID
 
 	tmpRefs := sql.QueryRefsByDefID(defID, containChildren)
+	tmpRefs = append(tmpRefs, querySyncedBlockRefs(defID, containChildren)...)
 	var refs []*sql.Ref
 	for _, ref := range tmpRefs {
 		if ref.RootID == refTreeID {
@@ -324,6 +325,7 @@ func GetBacklink2(id, keyword, mentionKeyword string, sortMode, mentionSortMode
 	boxID = sqlBlock.Box
 
 	refs := sql.QueryRefsByDefID(id, containChildren)
+	refs = append(refs, querySyncedBlockRefs(id, containChildren)...)
 	refs = removeDuplicatedRefs(refs)
 
 	linkRefs, linkRefsCount, excludeBacklinkIDs, _ := buildLinkRefs(rootID, refs, keywords)
@@ -422,6 +424,7 @@ func GetBacklink(id, keyword, mentionKeyword string, beforeLen int, containChild
 
 	var links []*Block
 	refs := sql.QueryRefsByDefID(id, containChildren)
+	refs = append(refs, querySyncedBlockRefs(id, containChildren)...)
 	refs = removeDuplicatedRefs(refs)
 
 	// 为了减少查询，组装好 IDs 后一次查出
@@ -0,0 +1,391 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/go-humanize"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	BackupTargetModePeriodic = 0 // 定时创建快照并推送
+	BackupTargetModeTagged   = 1 // 推送标记过的快照
+
+	backupTargetTagPrefix = "backup-" // 定时备份推送的快照标记前缀
+
+	backupTargetRetryInterval = 30 * time.Minute // 定时备份失败后的重试间隔
+)
+
+var (
+	ErrBackupTargetNotFound = errors.New("backup target not found")
+
+	backupTargetLock = sync.Mutex{}
+)
+
+// GetBackupTargets 获取备份目标列表。
+func GetBackupTargets() (ret []*conf.BackupTarget) {
+	ret = Conf.Repo.BackupTargets
+	if nil == ret {
+		ret = []*conf.BackupTarget{}
+	}
+	return
+}
+
+// SetBackupTarget 新建或者更新备份目标，target.ID 为空时新建。
+func SetBackupTarget(target *conf.BackupTarget) (err error) {
+	if err = normalizeBackupTarget(target); err != nil {
+		return
+	}
+
+	backupTargetLock.Lock()
+	defer backupTargetLock.Unlock()
+
+	if "" == target.ID {
+		target.ID = ast.NewNodeID()
+		if "" == target.Name {
+			target.Name = conf.ProviderToStr(target.Provider)
+		}
+		Conf.Repo.BackupTargets = append(Conf.Repo.BackupTargets, target)
+		Conf.Save()
+		return
+	}
+
+	existing := getBackupTarget(target.ID)
+	if nil == existing {
+		err = ErrBackupTargetNotFound
+		return
+	}
+
+	// 状态信息由内核维护，不使用前端传入的值
+	target.LastRun, target.LastSuccess, target.LastError, target.Stat = existing.LastRun, existing.LastSuccess, existing.LastError, existing.Stat
+	for i, t := range Conf.Repo.BackupTargets {
+		if t.ID == target.ID {
+			Conf.Repo.BackupTargets[i] = target
+			break
+		}
+	}
+	Conf.Save()
+	return
+}
+
+// RemoveBackupTarget 移除备份目标，已经推送到备份目标上的快照不会被删除。
+func RemoveBackupTarget(id string) (err error) {
+	backupTargetLock.Lock()
+	defer backupTargetLock.Unlock()
+
+	var targets []*conf.BackupTarget
+	for _, t := range Conf.Repo.BackupTargets {
+		if t.ID != id {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == len(Conf.Repo.BackupTargets) {
+		err = ErrBackupTargetNotFound
+		return
+	}
+	if nil == targets {
+		targets = []*conf.BackupTarget{}
+	}
+	Conf.Repo.BackupTargets = targets
+	Conf.Save()
+	return
+}
+
+// BackupToTarget 立即推送快照到备份目标。
+func BackupToTarget(id string) (err error) {
+	backupTargetLock.Lock()
+	defer backupTargetLock.Unlock()
+
+	target := getBackupTarget(id)
+	if nil == target {
+		err = ErrBackupTargetNotFound
+		return
+	}
+
+	util.PushEndlessProgress(Conf.Language(116))
+	defer util.PushClearProgress()
+	if err = backupToTarget(target); err != nil {
+		return
+	}
+	util.PushMsg(target.Stat, 5000)
+	return
+}
+
+func BackupTargetsJob() {
+	task.AppendTaskWithTimeout(task.RepoBackupTarget, 12*time.Hour, backupTargets)
+}
+
+func backupTargets() {
+	if 1 > len(Conf.Repo.Key) {
+		return
+	}
+
+	if !backupTargetLock.TryLock() {
+		return
+	}
+	defer backupTargetLock.Unlock()
+
+	now := time.Now()
+	for _, target := range Conf.Repo.BackupTargets {
+		if !isBackupTargetDue(target, now) {
+			continue
+		}
+
+		if err := backupToTarget(target); err != nil {
+			util.PushErrMsg(fmt.Sprintf(Conf.Language(84), target.Name+": "+target.LastError), 7000)
+		}
+	}
+}
+
+// isBackupTargetDue 判断备份目标是否需要定时备份，距离上次成功备份超过备份间隔时备份，上次备份失败时按照重试间隔重试。
+func isBackupTargetDue(target *conf.BackupTarget, now time.Time) bool {
+	if !target.Enabled {
+		return false
+	}
+
+	interval := time.Duration(target.Interval) * time.Hour
+	if now.Sub(time.UnixMilli(target.LastSuccess)) < interval {
+		return false
+	}
+	if "" != target.LastError && now.Sub(time.UnixMilli(target.LastRun)) < min(interval, backupTargetRetryInterval) {
+		return false
+	}
+	return true
+}
+
+// backupToTarget 推送快照到备份目标并按照保留数清理备份目标上的旧快照，调用方需要持有 backupTargetLock。
+func backupToTarget(target *conf.BackupTarget) (err error) {
+	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
+		return
+	}
+	if !IsPaidUser() {
+		err = errors.New(Conf.Language(214))
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		target.LastRun = start.UnixMilli()
+		if err != nil {
+			target.LastError = formatRepoErrorMsg(err)
+			logging.LogErrorf("backup to target [%s] failed: %s", target.Name, err)
+		} else {
+			target.LastSuccess = target.LastRun
+			target.LastError = ""
+		}
+		Conf.Save()
+	}()
+
+	repo, err := newBackupTargetRepository(target)
+	if err != nil {
+		return
+	}
+
+	context := map[string]interface{}{eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar}
+	var uploadFileCount, uploadChunkCount int
+	var uploadBytes int64
+	switch target.Mode {
+	case BackupTargetModeTagged:
+		uploadFileCount, uploadChunkCount, uploadBytes, err = uploadTagsToBackupTarget(repo, target, context)
+	default:
+		FlushTxQueue()
+		var index *entity.Index
+		index, err = repo.Index("[Backup] "+target.Name, true, context)
+		if err != nil {
+			return
+		}
+
+		tag := backupTargetTagPrefix + start.Format("20060102150405")
+		uploadFileCount, uploadChunkCount, uploadBytes, err = repo.UploadTagIndex(tag, index.ID, context)
+	}
+	if err != nil {
+		return
+	}
+
+	if err = purgeBackupTarget(repo, target, context); err != nil {
+		return
+	}
+
+	target.Stat = fmt.Sprintf(Conf.Language(152), uploadFileCount, uploadChunkCount, humanize.BytesCustomCeil(uint64(uploadBytes), 2))
+	logging.LogInfof("backed up to target [%s] in [%.2fs]: %s", target.Name, time.Since(start).Seconds(), target.Stat)
+	return
+}
+
+// uploadTagsToBackupTarget 推送备份目标上还没有的本地标记快照，设置了保留数时只推送最新的几个。
+func uploadTagsToBackupTarget(repo *dejavu.Repo, target *conf.BackupTarget, context map[string]interface{}) (uploadFileCount, uploadChunkCount int, uploadBytes int64, err error) {
+	localTags, err := repo.GetTagLogs()
+	if err != nil {
+		return
+	}
+	cloudTags, err := repo.GetCloudRepoTagLogs(context)
+	if err != nil {
+		return
+	}
+
+	uploaded := map[string]bool{}
+	for _, tag := range cloudTags {
+		uploaded[tag.Tag+"/"+tag.ID] = true
+	}
+
+	sort.Slice(localTags, func(i, j int) bool { return localTags[i].Created > localTags[j].Created })
+	if 0 < target.Retention && target.Retention < len(localTags) {
+		localTags = localTags[:target.Retention]
+	}
+
+	for _, tag := range localTags {
+		if uploaded[tag.Tag+"/"+tag.ID] {
+			continue
+		}
+
+		fileCount, chunkCount, bytes, uploadErr := repo.UploadTagIndex(tag.Tag, tag.ID, context)
+		if nil != uploadErr {
+			err = uploadErr
+			return
+		}
+		uploadFileCount += fileCount
+		uploadChunkCount += chunkCount
+		uploadBytes += bytes
+	}
+	return
+}
+
+// purgeBackupTarget 按照保留数移除备份目标上较旧的快照标记。
+func purgeBackupTarget(repo *dejavu.Repo, target *conf.BackupTarget, context map[string]interface{}) (err error) {
+	if 1 > target.Retention {
+		return
+	}
+
+	tags, err := repo.GetCloudRepoTagLogs(context)
+	if err != nil {
+		return
+	}
+	if BackupTargetModePeriodic == target.Mode {
+		var backupTags []*dejavu.Log
+		for _, tag := range tags {
+			if strings.HasPrefix(tag.Tag, backupTargetTagPrefix) {
+				backupTags = append(backupTags, tag)
+			}
+		}
+		tags = backupTags
+	}
+	if len(tags) <= target.Retention {
+		return
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Created > tags[j].Created })
+	for _, tag := range tags[target.Retention:] {
+		if err = repo.RemoveCloudRepoTag(tag.Tag); err != nil {
+			return
+		}
+		logging.LogInfof("removed snapshot tag [%s] from backup target [%s]", tag.Tag, target.Name)
+	}
+	return
+}
+
+func newBackupTargetRepository(target *conf.BackupTarget) (ret *dejavu.Repo, err error) {
+	syncConf := target.Sync()
+	cloudConf, err := buildCloudConfBySync(syncConf)
+	if err != nil {
+		return
+	}
+
+	cloudRepo, err := newCloudRepo(syncConf, cloudConf)
+	if err != nil {
+		return
+	}
+	return newRepositoryWithCloud(cloudRepo)
+}
+
+func getBackupTarget(id string) *conf.BackupTarget {
+	for _, t := range Conf.Repo.BackupTargets {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func normalizeBackupTarget(target *conf.BackupTarget) (err error) {
+	target.Name = strings.TrimSpace(target.Name)
+	target.CloudName = strings.TrimSpace(target.CloudName)
+	if "" == target.CloudName {
+		target.CloudName = "main"
+	}
+	if !cloud.IsValidCloudDirName(target.CloudName) {
+		err = errors.New(Conf.Language(37))
+		return
+	}
+	if 1 > target.Interval {
+		target.Interval = 24
+	}
+	if 0 > target.Retention {
+		target.Retention = 0
+	}
+	if BackupTargetModePeriodic != target.Mode && BackupTargetModeTagged != target.Mode {
+		target.Mode = BackupTargetModePeriodic
+	}
+
+	switch target.Provider {
+	case conf.ProviderS3:
+		if nil == target.S3 {
+			err = errors.New("S3 config is required")
+			return
+		}
+		normalizeSyncProviderS3(target.S3)
+		if !cloud.IsValidCloudDirName(target.S3.Bucket) {
+			err = errors.New(Conf.Language(37))
+			return
+		}
+	case conf.ProviderWebDAV:
+		if nil == target.WebDAV {
+			err = errors.New("WebDAV config is required")
+			return
+		}
+		err = normalizeSyncProviderWebDAV(target.WebDAV)
+	case conf.ProviderLocal:
+		if nil == target.Local {
+			err = errors.New("local config is required")
+			return
+		}
+		err = normalizeSyncProviderLocal(target.Local)
+	case conf.ProviderSFTP:
+		if nil == target.SFTP {
+			err = errors.New("SFTP config is required")
+			return
+		}
+		err = normalizeSyncProviderSFTP(target.SFTP)
+	default:
+		err = fmt.Errorf("unsupported backup target provider [%d]", target.Provider)
+	}
+	return
+}
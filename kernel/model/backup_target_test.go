@@ -0,0 +1,74 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestNormalizeBackupTarget(t *testing.T) {
+	target := &conf.BackupTarget{
+		Name:      " Offsite ",
+		Mode:      5,
+		Retention: -1,
+		Provider:  conf.ProviderS3,
+		S3:        &conf.S3{Endpoint: " https://s3.example.com ", Bucket: " siyuan "},
+	}
+	if err := normalizeBackupTarget(target); err != nil {
+		t.Fatalf("normalize backup target failed: %s", err)
+	}
+	if "Offsite" != target.Name || "main" != target.CloudName || 24 != target.Interval || 0 != target.Retention || BackupTargetModePeriodic != target.Mode {
+		t.Fatalf("unexpected backup target [name=%s, cloudName=%s, interval=%d, retention=%d, mode=%d]", target.Name, target.CloudName, target.Interval, target.Retention, target.Mode)
+	}
+	if "siyuan" != target.S3.Bucket {
+		t.Fatalf("unexpected bucket [%s]", target.S3.Bucket)
+	}
+
+	if err := normalizeBackupTarget(&conf.BackupTarget{Provider: conf.ProviderS3}); nil == err {
+		t.Fatalf("expected error for missing S3 config")
+	}
+	if err := normalizeBackupTarget(&conf.BackupTarget{Provider: conf.ProviderSiYuan}); nil == err {
+		t.Fatalf("expected error for unsupported provider")
+	}
+}
+
+func TestIsBackupTargetDue(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) int64 { return now.Add(-d).UnixMilli() }
+
+	tests := []struct {
+		name   string
+		target *conf.BackupTarget
+		due    bool
+	}{
+		{"disabled", &conf.BackupTarget{Interval: 1}, false},
+		{"never run", &conf.BackupTarget{Enabled: true, Interval: 1}, true},
+		{"within interval", &conf.BackupTarget{Enabled: true, Interval: 24, LastRun: ago(time.Hour), LastSuccess: ago(time.Hour)}, false},
+		{"interval elapsed", &conf.BackupTarget{Enabled: true, Interval: 24, LastRun: ago(25 * time.Hour), LastSuccess: ago(25 * time.Hour)}, true},
+		{"failed recently", &conf.BackupTarget{Enabled: true, Interval: 24, LastRun: ago(10 * time.Minute), LastSuccess: ago(48 * time.Hour), LastError: "timeout"}, false},
+		{"retry after failure", &conf.BackupTarget{Enabled: true, Interval: 24, LastRun: ago(time.Hour), LastSuccess: ago(48 * time.Hour), LastError: "timeout"}, true},
+		{"first run failed", &conf.BackupTarget{Enabled: true, Interval: 24, LastRun: ago(time.Hour), LastError: "timeout"}, true},
+	}
+	for _, test := range tests {
+		if due := isBackupTargetDue(test.target, now); due != test.due {
+			t.Errorf("%s: expected due [%t], got [%t]", test.name, test.due, due)
+		}
+	}
+}
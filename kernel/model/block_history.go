@@ -0,0 +1,332 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	BlockHistorySourceCurrent  = "current"  // 当前版本
+	BlockHistorySourceHistory  = "history"  // 文件历史
+	BlockHistorySourceSnapshot = "snapshot" // 数据快照
+)
+
+const blockHistorySnapshotPages = 8 // 最多扫描的数据快照页数，每页 32 个快照
+
+// BlockVersion 描述块的一个历史版本，Diff 是相对于上一个（更早的）版本的文本差异。
+type BlockVersion struct {
+	Source   string      `json:"source"`
+	Ref      string      `json:"ref"` // 文件历史路径或者数据快照文件 ID
+	Snapshot string      `json:"snapshot,omitempty"`
+	Op       string      `json:"op"`
+	Created  int64       `json:"created"`
+	Markdown string      `json:"markdown"`
+	Diff     []*TextDiff `json:"diff"`
+}
+
+// TextDiff 描述一段文本差异，Op 为 "=" 表示未变化，"-" 表示删除，"+" 表示新增。
+type TextDiff struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// GetBlockHistory 从文件历史和数据快照中重建块的各个版本，按时间倒序返回，内容未变化的版本会被合并。
+func GetBlockHistory(id, rootID string) (ret []*BlockVersion, err error) {
+	ret = []*BlockVersion{}
+	if bt := treenode.GetBlockTree(id); nil != bt {
+		if filesys.IsBoxLocked(bt.BoxID) {
+			// 加密笔记本未解锁时无法解密历史和快照中的文档
+			err = filesys.ErrBoxLocked
+			return
+		}
+		rootID = bt.RootID
+	}
+	if !ast.IsNodeIDPattern(rootID) {
+		err = ErrBlockNotFound
+		return
+	}
+	if id == rootID {
+		err = errors.New("document block does not support block history, please use doc history")
+		return
+	}
+
+	luteEngine := NewLute()
+	var versions []*BlockVersion
+	versions = append(versions, blockVersionsInHistory(id, rootID, luteEngine)...)
+	versions = append(versions, blockVersionsInSnapshots(id, rootID, luteEngine)...)
+	if tree, _ := LoadTreeByBlockID(id); nil != tree {
+		if node := treenode.GetNodeInTree(tree, id); nil != node {
+			versions = append(versions, &BlockVersion{
+				Source:   BlockHistorySourceCurrent,
+				Op:       BlockHistorySourceCurrent,
+				Created:  blockVersionUpdated(node),
+				Markdown: treenode.ExportNodeStdMd(node, luteEngine),
+			})
+		}
+	}
+
+	ret = mergeBlockVersions(versions)
+	return
+}
+
+// mergeBlockVersions 按时间排序并合并内容未变化的相邻版本，计算每个版本相对于上一个版本的差异，按时间倒序返回。
+func mergeBlockVersions(versions []*BlockVersion) (ret []*BlockVersion) {
+	ret = []*BlockVersion{}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Created < versions[j].Created })
+	var prev *BlockVersion
+	for _, v := range versions {
+		if nil != prev && prev.Markdown == v.Markdown {
+			if BlockHistorySourceCurrent == v.Source {
+				ret[len(ret)-1] = v // 当前版本优先
+				v.Diff = prev.Diff
+			}
+			continue
+		}
+
+		if nil == prev {
+			v.Diff = []*TextDiff{{Op: "+", Text: v.Markdown}}
+		} else {
+			v.Diff = textDiff(prev.Markdown, v.Markdown)
+		}
+		ret = append(ret, v)
+		prev = v
+	}
+
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return
+}
+
+// RestoreBlockHistory 使用块的历史版本原地替换当前块，通过事务实现，不会回滚文档中的其他块。
+func RestoreBlockHistory(id, source, ref string) (err error) {
+	FlushTxQueue()
+
+	tree, err := LoadTreeByBlockID(id)
+	if err != nil {
+		return
+	}
+	if tree.ID == id {
+		return errors.New("document block does not support block history, please use doc history")
+	}
+	current := treenode.GetNodeInTree(tree, id)
+	if nil == current {
+		return ErrBlockNotFound
+	}
+
+	luteEngine := NewLute()
+	var versionTree *parse.Tree
+	switch source {
+	case BlockHistorySourceHistory:
+		// 只允许恢复文件历史目录下的文档
+		if !filepath.IsAbs(ref) || !util.IsSubPath(util.HistoryDir, filepath.Clean(ref)) || ".sy" != filepath.Ext(ref) {
+			return errors.New("Path [" + ref + "] is not in history")
+		}
+		versionTree, err = loadTree(ref, luteEngine)
+	case BlockHistorySourceSnapshot:
+		var data []byte
+		if data, _, err = GetRepoFile(ref); nil == err {
+			_, versionTree, err = parseTreeInSnapshot(data, luteEngine)
+		}
+	default:
+		return errors.New("invalid block history source [" + source + "]")
+	}
+	if err != nil {
+		return
+	}
+
+	node := treenode.GetNodeInTree(versionTree, id)
+	if nil == node {
+		return ErrBlockNotFound
+	}
+
+	// 子块已经移动到其他位置时使用新的 ID，避免块 ID 重复
+	currentIDs := map[string]bool{}
+	ast.Walk(current, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() {
+			currentIDs[n.ID] = true
+		}
+		return ast.WalkContinue
+	})
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || n == node || "" == n.ID || currentIDs[n.ID] {
+			return ast.WalkContinue
+		}
+
+		if nil != treenode.GetBlockTree(n.ID) {
+			n.ID = ast.NewNodeID()
+			n.SetIALAttr("id", n.ID)
+		}
+		return ast.WalkContinue
+	})
+	node.SetIALAttr("updated", util.CurrentTimeSecondsStr())
+
+	transactions := []*Transaction{{
+		DoOperations:   []*Operation{{Action: "update", ID: id, Data: luteEngine.RenderNodeBlockDOM(node)}},
+		UndoOperations: []*Operation{{Action: "update", ID: id, Data: luteEngine.RenderNodeBlockDOM(current)}},
+	}}
+	PerformTransactions(&transactions)
+	FlushTxQueue()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = transactions
+	util.PushEvent(evt)
+	return
+}
+
+func blockVersionsInHistory(id, rootID string, luteEngine *lute.Lute) (ret []*BlockVersion) {
+	stmt := "SELECT * FROM histories_fts_case_insensitive WHERE id = '" + rootID + "' AND type = " + strconv.Itoa(HistoryTypeDoc) + " ORDER BY created DESC"
+	for _, history := range sql.SelectHistoriesRawStmt(stmt) {
+		historyPath := filepath.Join(util.HistoryDir, history.Path)
+		if !gulu.File.IsExist(historyPath) {
+			continue
+		}
+
+		tree, err := loadTree(historyPath, luteEngine)
+		if err != nil {
+			continue
+		}
+		node := treenode.GetNodeInTree(tree, id)
+		if nil == node {
+			continue
+		}
+
+		created, _ := strconv.ParseInt(history.Created, 10, 64)
+		ret = append(ret, &BlockVersion{
+			Source:   BlockHistorySourceHistory,
+			Ref:      historyPath,
+			Op:       history.Op,
+			Created:  created * 1000,
+			Markdown: treenode.ExportNodeStdMd(node, luteEngine),
+		})
+	}
+	return
+}
+
+func blockVersionsInSnapshots(id, rootID string, luteEngine *lute.Lute) (ret []*BlockVersion) {
+	if 1 > len(Conf.Repo.Key) {
+		return
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	visited := map[string]bool{}
+	for page := 1; page <= blockHistorySnapshotPages; page++ {
+		logs, pageCount, _, getErr := repo.GetIndexLogs(page, 32)
+		if nil != getErr {
+			if dejavu.ErrNotFoundIndex != getErr {
+				logging.LogErrorf("get data repo index logs failed: %s", getErr)
+			}
+			return
+		}
+
+		for _, l := range logs {
+			for _, file := range l.Files {
+				if !strings.HasSuffix(file.Path, "/"+rootID+".sy") {
+					continue
+				}
+				if visited[file.ID] { // 多个快照中未变化的文件只处理一次
+					break
+				}
+				visited[file.ID] = true
+
+				data, openErr := repo.OpenFile(file)
+				if nil != openErr {
+					logging.LogErrorf("open snapshot file [%s] failed: %s", file.ID, openErr)
+					break
+				}
+				_, tree, parseErr := parseTreeInSnapshot(data, luteEngine)
+				if nil != parseErr {
+					logging.LogErrorf("parse tree from snapshot file [%s] failed: %s", file.ID, parseErr)
+					break
+				}
+				if node := treenode.GetNodeInTree(tree, id); nil != node {
+					ret = append(ret, &BlockVersion{
+						Source:   BlockHistorySourceSnapshot,
+						Ref:      file.ID,
+						Snapshot: l.ID,
+						Op:       l.Memo,
+						Created:  l.Created,
+						Markdown: treenode.ExportNodeStdMd(node, luteEngine),
+					})
+				}
+				break
+			}
+		}
+		if page >= pageCount {
+			return
+		}
+	}
+	return
+}
+
+func blockVersionUpdated(node *ast.Node) int64 {
+	updated := node.IALAttr("updated")
+	if "" == updated {
+		updated = node.ID[:14]
+	}
+	t, err := time.ParseInLocation("20060102150405", updated, time.Local)
+	if err != nil {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// textDiff 计算从 a 到 b 的文本差异。
+func textDiff(a, b string) (ret []*TextDiff) {
+	ret = []*TextDiff{}
+	aTokens := textTokens(a)
+	pos := 0
+	appendDiff := func(op, text string) {
+		if "" == text {
+			return
+		}
+		if last := len(ret) - 1; 0 <= last && op == ret[last].Op {
+			ret[last].Text += text
+			return
+		}
+		ret = append(ret, &TextDiff{Op: op, Text: text})
+	}
+	for _, h := range diffHunks(aTokens, textTokens(b)) {
+		appendDiff("=", strings.Join(aTokens[pos:h.start], ""))
+		appendDiff("-", strings.Join(aTokens[h.start:h.end], ""))
+		appendDiff("+", strings.Join(h.text, ""))
+		pos = h.end
+	}
+	appendDiff("=", strings.Join(aTokens[pos:], ""))
+	return
+}
@@ -0,0 +1,72 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+)
+
+func TestTextDiff(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected []TextDiff
+	}{
+		{"same", "same", []TextDiff{{"=", "same"}}},
+		{"", "new", []TextDiff{{"+", "new"}}},
+		{"old", "", []TextDiff{{"-", "old"}}},
+		{"a b c", "a x c", []TextDiff{{"=", "a "}, {"-", "b"}, {"+", "x"}, {"=", " c"}}},
+		{"a b c", "a b c d", []TextDiff{{"=", "a b c"}, {"+", " d"}}},
+		{"a b c d", "x b c y", []TextDiff{{"-", "a"}, {"+", "x"}, {"=", " b c "}, {"-", "d"}, {"+", "y"}}},
+	}
+	for _, c := range cases {
+		got := textDiff(c.a, c.b)
+		if len(c.expected) != len(got) {
+			t.Fatalf("diff [%s] -> [%s] expected [%d] parts, got [%d]", c.a, c.b, len(c.expected), len(got))
+		}
+		for i, part := range got {
+			if c.expected[i] != *part {
+				t.Fatalf("diff [%s] -> [%s] expected part [%d] to be [%v], got [%v]", c.a, c.b, i, c.expected[i], *part)
+			}
+		}
+	}
+}
+
+func TestMergeBlockVersions(t *testing.T) {
+	versions := []*BlockVersion{
+		{Source: BlockHistorySourceCurrent, Created: 4, Markdown: "a b"},
+		{Source: BlockHistorySourceHistory, Ref: "h3", Created: 3, Markdown: "a b"},
+		{Source: BlockHistorySourceSnapshot, Ref: "s2", Created: 2, Markdown: "a"},
+		{Source: BlockHistorySourceHistory, Ref: "h1", Created: 1, Markdown: "a"},
+	}
+	ret := mergeBlockVersions(versions)
+	if 2 != len(ret) {
+		t.Fatalf("expected 2 versions, got [%d]", len(ret))
+	}
+
+	// 和当前版本内容相同的历史版本被合并到当前版本
+	if BlockHistorySourceCurrent != ret[0].Source || 2 != len(ret[0].Diff) || "+" != ret[0].Diff[1].Op || " b" != ret[0].Diff[1].Text {
+		t.Fatalf("unexpected current version [%+v]", ret[0])
+	}
+	// 内容相同的版本只保留最早的
+	if "h1" != ret[1].Ref || 1 != len(ret[1].Diff) || "+" != ret[1].Diff[0].Op || "a" != ret[1].Diff[0].Text {
+		t.Fatalf("unexpected first version [%+v]", ret[1])
+	}
+
+	if ret = mergeBlockVersions(nil); nil == ret || 0 != len(ret) {
+		t.Fatalf("expected empty versions, got [%v]", ret)
+	}
+}
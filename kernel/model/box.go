@@ -56,6 +56,10 @@ type Box struct {
 	SortMode int    `json:"sortMode"`
 	Closed   bool   `json:"closed"`
 
+	Encrypted bool `json:"encrypted"` // 是否加密存储
+	Locked    bool `json:"locked"`    // 加密笔记本是否处于锁定状态，锁定时视为关闭
+	SyncMode  int  `json:"syncMode"`  // 云端同步方式
+
 	NewFlashcardCount int `json:"newFlashcardCount"`
 	DueFlashcardCount int `json:"dueFlashcardCount"`
 	FlashcardCount    int `json:"flashcardCount"`
@@ -134,12 +138,21 @@ func ListNotebooks() (ret []*Box, err error) {
 		}
 
 		box := &Box{
-			ID:       id,
-			Name:     boxConf.Name,
-			Icon:     icon,
-			Sort:     boxConf.Sort,
-			SortMode: boxConf.SortMode,
-			Closed:   boxConf.Closed,
+			ID:        id,
+			Name:      boxConf.Name,
+			Icon:      icon,
+			Sort:      boxConf.Sort,
+			SortMode:  boxConf.SortMode,
+			Closed:    boxConf.Closed,
+			Encrypted: boxConf.Encrypted,
+			SyncMode:  boxConf.SyncMode,
+		}
+
+		filesys.SetBoxEncrypted(id, boxConf.Encrypted)
+		filesys.SetBoxReadonly(id, conf.BoxSyncModePullOnly == boxConf.SyncMode)
+		if box.Encrypted && filesys.IsBoxLocked(id) {
+			box.Locked = true
+			box.Closed = true
 		}
 
 		if !isExistConf {
@@ -711,6 +724,9 @@ func VacuumDataIndex() {
 func FullReindex() {
 	task.AppendTask(task.DatabaseIndexFull, fullReindex)
 	task.AppendTask(task.DatabaseIndexRef, IndexRefs)
+	IndexAssetMetaJob()
+	IndexCommentsJob()
+	resetChangeFeed()
 	go func() {
 		sql.FlushQueue()
 		ResetVirtualBlockRefCache()
@@ -0,0 +1,292 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/encryption"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var (
+	ErrBoxEncrypted       = errors.New("notebook is already encrypted")
+	ErrBoxNotEncrypted    = errors.New("notebook is not encrypted")
+	ErrInvalidPassphrase  = errors.New("invalid passphrase")
+	ErrBoxEncryptBusy     = errors.New("notebook is busy")
+	ErrEmptyBoxPassphrase = errors.New("passphrase is empty")
+)
+
+// EncryptBox 为笔记本启用加密，使用口令派生的密钥重写笔记本下的所有文档，资源文件不会被加密。
+func EncryptBox(boxID, passphrase string) (err error) {
+	passphrase = strings.TrimSpace(gulu.Str.RemoveInvisible(passphrase))
+	if "" == passphrase {
+		return ErrEmptyBoxPassphrase
+	}
+
+	box := Conf.Box(boxID)
+	if nil == box {
+		return ErrBoxNotFound
+	}
+
+	boxConf := box.GetConf()
+	if boxConf.Encrypted {
+		return ErrBoxEncrypted
+	}
+
+	if _, ok := boxLock.Load(boxID); ok {
+		return ErrBoxEncryptBusy
+	}
+	boxLock.Store(boxID, true)
+	defer boxLock.Delete(boxID)
+
+	FlushTxQueue()
+
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return
+	}
+	boxConf.EncryptSalt = fmt.Sprintf("%x", salt)
+	key, err := encryption.KDF(passphrase, boxConf.EncryptSalt)
+	if err != nil {
+		logging.LogErrorf("derive notebook [%s] key failed: %s", boxID, err)
+		return
+	}
+
+	// 先登记加密状态再重写文档，中途失败时未重写的明文文档仍然可以正常读取，后续保存时会被加密
+	boxConf.Encrypted = true
+	boxConf.EncryptKeyHash = boxKeyHash(key)
+	box.SaveConf(boxConf)
+	filesys.SetBoxEncrypted(boxID, true)
+	filesys.UnlockBox(boxID, key)
+
+	util.PushEndlessProgress(Conf.Language(116))
+	defer util.PushClearProgress()
+
+	if err = rewriteBoxTrees(box, func(data []byte) ([]byte, error) {
+		return filesys.EncryptTreeDataWithKey(boxID, data, key)
+	}); err != nil {
+		return
+	}
+	if err = rewriteBoxComments(box, func(data []byte) ([]byte, error) {
+		return filesys.EncryptTreeDataWithKey(boxID, data, key)
+	}); err != nil {
+		return
+	}
+	logging.LogInfof("encrypted notebook [%s]", boxID)
+	return
+}
+
+// DecryptBox 关闭笔记本加密，使用口令派生的密钥将笔记本下的所有文档重写为明文。
+func DecryptBox(boxID, passphrase string) (err error) {
+	box := Conf.GetBox(boxID)
+	if nil == box {
+		return ErrBoxNotFound
+	}
+
+	boxConf := box.GetConf()
+	if !boxConf.Encrypted {
+		return ErrBoxNotEncrypted
+	}
+
+	key, err := verifyBoxPassphrase(boxID, boxConf.EncryptSalt, boxConf.EncryptKeyHash, passphrase)
+	if err != nil {
+		return
+	}
+
+	if _, ok := boxLock.Load(boxID); ok {
+		return ErrBoxEncryptBusy
+	}
+	boxLock.Store(boxID, true)
+	defer boxLock.Delete(boxID)
+
+	FlushTxQueue()
+
+	wasLocked := filesys.IsBoxLocked(boxID)
+	filesys.UnlockBox(boxID, key)
+
+	util.PushEndlessProgress(Conf.Language(116))
+	defer util.PushClearProgress()
+
+	if err = rewriteBoxTrees(box, func(data []byte) ([]byte, error) {
+		return filesys.DecryptTreeDataWithKey(data, key)
+	}); err != nil {
+		return
+	}
+	if err = rewriteBoxComments(box, func(data []byte) ([]byte, error) {
+		return filesys.DecryptTreeDataWithKey(data, key)
+	}); err != nil {
+		return
+	}
+
+	boxConf.Encrypted = false
+	boxConf.EncryptSalt = ""
+	boxConf.EncryptKeyHash = ""
+	box.SaveConf(boxConf)
+	filesys.SetBoxEncrypted(boxID, false)
+	logging.LogInfof("decrypted notebook [%s]", boxID)
+
+	if wasLocked && !boxConf.Closed {
+		box.Index()
+	}
+	return
+}
+
+// UnlockBox 使用口令解锁加密笔记本，解锁状态持续到调用 LockBox 或者内核退出。
+func UnlockBox(boxID, passphrase string) (err error) {
+	box := Conf.GetBox(boxID)
+	if nil == box {
+		return ErrBoxNotFound
+	}
+
+	boxConf := box.GetConf()
+	if !boxConf.Encrypted {
+		return ErrBoxNotEncrypted
+	}
+
+	if !filesys.IsBoxLocked(boxID) {
+		return
+	}
+
+	key, err := verifyBoxPassphrase(boxID, boxConf.EncryptSalt, boxConf.EncryptKeyHash, passphrase)
+	if err != nil {
+		return
+	}
+
+	filesys.UnlockBox(boxID, key)
+	logging.LogInfof("unlocked notebook [%s]", boxID)
+
+	if boxConf.Closed {
+		return
+	}
+
+	box = Conf.Box(boxID)
+	if nil == box {
+		return
+	}
+	box.Index()
+	task.AppendTask(task.CommentDatabaseIndex, indexBoxComments, box.ID)
+	ListDocTree(box.ID, "/", util.SortModeUnassigned, false, false, Conf.FileTree.MaxListCount)
+
+	evt := util.NewCmdResult("mount", 0, util.PushModeBroadcast)
+	evt.Data = map[string]interface{}{
+		"box":     box,
+		"existed": false,
+	}
+	util.PushEvent(evt)
+	return
+}
+
+// LockBox 锁定加密笔记本，清除会话密钥并移除该笔记本的数据库索引。
+func LockBox(boxID string) (err error) {
+	box := Conf.GetBox(boxID)
+	if nil == box {
+		return ErrBoxNotFound
+	}
+
+	boxConf := box.GetConf()
+	if !boxConf.Encrypted {
+		return ErrBoxNotEncrypted
+	}
+
+	if filesys.IsBoxLocked(boxID) {
+		return
+	}
+
+	FlushTxQueue()
+	box.Unindex()
+	unindexBoxComments(box)
+	filesys.LockBox(boxID)
+	logging.LogInfof("locked notebook [%s]", boxID)
+
+	evt := util.NewCmdResult("unmount", 0, util.PushModeBroadcast)
+	evt.Data = map[string]interface{}{
+		"box": boxID,
+	}
+	util.PushEvent(evt)
+	return
+}
+
+// purgeEncryptedBoxIndexes 在启动时移除加密笔记本可能残留的索引，加密笔记本启动后总是处于锁定状态。
+func purgeEncryptedBoxIndexes() {
+	boxes, _ := ListNotebooks()
+	for _, box := range boxes {
+		if box.Encrypted {
+			unindex(box.ID)
+		}
+	}
+}
+
+func verifyBoxPassphrase(boxID, salt, keyHash, passphrase string) (ret []byte, err error) {
+	passphrase = strings.TrimSpace(gulu.Str.RemoveInvisible(passphrase))
+	if "" == passphrase {
+		return nil, ErrEmptyBoxPassphrase
+	}
+
+	ret, err = encryption.KDF(passphrase, salt)
+	if err != nil {
+		logging.LogErrorf("derive notebook [%s] key failed: %s", boxID, err)
+		return
+	}
+
+	if boxKeyHash(ret) != keyHash {
+		return nil, ErrInvalidPassphrase
+	}
+	return
+}
+
+func boxKeyHash(key []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(key))
+}
+
+func rewriteBoxTrees(box *Box, transform func(data []byte) ([]byte, error)) (err error) {
+	boxLocalPath := filepath.Join(util.DataDir, box.ID)
+	for _, file := range box.ListFiles("/") {
+		if file.isdir || !strings.HasSuffix(file.name, ".sy") {
+			continue
+		}
+
+		absPath := filepath.Join(boxLocalPath, file.path)
+		data, readErr := filelock.ReadFile(absPath)
+		if nil != readErr {
+			logging.LogErrorf("read tree [%s] failed: %s", absPath, readErr)
+			return readErr
+		}
+
+		if data, err = transform(data); err != nil {
+			logging.LogErrorf("transform tree [%s] failed: %s", absPath, err)
+			return
+		}
+
+		if err = filelock.WriteFile(absPath, data); err != nil {
+			logging.LogErrorf("write tree [%s] failed: %s", absPath, err)
+			return
+		}
+	}
+	IncSync()
+	return
+}
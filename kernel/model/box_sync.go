@@ -0,0 +1,166 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// SetBoxSyncMode 设置笔记本的云端同步方式，关闭的笔记本也可以设置。
+//
+// 只拉取的笔记本在本地只读，所以只有已经同步过并且同步后没有本地修改的笔记本才能设置为只拉取，避免本地修改无法上传也无法保留。
+func SetBoxSyncMode(boxID string, mode int) (err error) {
+	if conf.BoxSyncModeSync != mode && conf.BoxSyncModeLocalOnly != mode && conf.BoxSyncModePullOnly != mode {
+		err = errors.New("invalid sync mode")
+		return
+	}
+
+	box := Conf.GetBox(boxID)
+	if nil == box {
+		err = errors.New(Conf.Language(0))
+		return
+	}
+
+	boxConf := box.GetConf()
+	if mode == boxConf.SyncMode {
+		return
+	}
+
+	if conf.BoxSyncModePullOnly == mode {
+		FlushTxQueue()
+		if err = checkBoxSynced(boxID); err != nil {
+			return
+		}
+	}
+
+	boxConf.SyncMode = mode
+	box.SaveConf(boxConf)
+	box.SyncMode = mode
+	filesys.SetBoxReadonly(boxID, conf.BoxSyncModePullOnly == mode)
+	logging.LogInfof("set box [%s] sync mode to [%d]", boxID, mode)
+	return
+}
+
+// getBoxSyncIgnoreLines 返回根据笔记本同步方式生成的同步忽略规则。
+//
+// 仅本地的笔记本整个忽略，这样既不会上传，也不会被云端合并修改或者删除；
+// 只拉取的笔记本忽略其配置文件，避免同步方式被云端的配置覆盖。
+func getBoxSyncIgnoreLines() (ret []string) {
+	for _, box := range getBoxesBySyncMode(conf.BoxSyncModeLocalOnly) {
+		ret = append(ret, box.ID+"/**/*")
+	}
+	for _, box := range getBoxesBySyncMode(conf.BoxSyncModePullOnly) {
+		ret = append(ret, box.ID+"/.siyuan/conf.json")
+	}
+	return
+}
+
+func getBoxesBySyncMode(mode int) (ret []*Box) {
+	boxes, err := ListNotebooks()
+	if err != nil {
+		return
+	}
+
+	for _, box := range boxes {
+		if mode == box.SyncMode {
+			ret = append(ret, box)
+		}
+	}
+	return
+}
+
+// recordSyncedIndex 记录同步完成时的本地快照，设置只拉取时以此判断笔记本是否已经同步过。
+func recordSyncedIndex(repo *dejavu.Repo) {
+	latest, err := repo.Latest()
+	if err != nil {
+		logging.LogErrorf("get latest index failed: %s", err)
+		return
+	}
+	Conf.Sync.SyncedIndex = latest.ID
+}
+
+// checkBoxSynced 检查笔记本是否在最近一次同步完成时的本地快照中，并且之后没有本地修改。
+func checkBoxSynced(boxID string) (err error) {
+	if "" == Conf.Sync.SyncedIndex {
+		return fmt.Errorf("notebook [%s] has not been synced yet, please sync it before setting it to pull-only", boxID)
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+	index, err := repo.GetIndex(Conf.Sync.SyncedIndex)
+	if err != nil {
+		logging.LogErrorf("get synced index [%s] failed: %s", Conf.Sync.SyncedIndex, err)
+		return
+	}
+	files, err := repo.GetFiles(index)
+	if err != nil {
+		logging.LogErrorf("get synced index [%s] files failed: %s", Conf.Sync.SyncedIndex, err)
+		return
+	}
+
+	confPath := "/" + boxID + "/.siyuan/conf.json"
+	syncedFiles := map[string]*entity.File{}
+	for _, file := range files {
+		if strings.HasPrefix(file.Path, "/"+boxID+"/") && file.Path != confPath {
+			syncedFiles[file.Path] = file
+		}
+	}
+	if 1 > len(syncedFiles) {
+		return fmt.Errorf("notebook [%s] has not been synced yet, please sync it before setting it to pull-only", boxID)
+	}
+
+	var changed []string
+	filelock.Walk(filepath.Join(util.DataDir, boxID), func(absPath string, info os.FileInfo, err error) error {
+		if nil != err || nil == info || info.IsDir() {
+			return nil
+		}
+
+		p := "/" + filepath.ToSlash(strings.TrimPrefix(absPath, util.DataDir+string(os.PathSeparator)))
+		if p == confPath {
+			return nil
+		}
+
+		file := syncedFiles[p]
+		delete(syncedFiles, p)
+		if nil == file || info.Size() != file.Size || info.ModTime().UnixMilli() != file.Updated {
+			changed = append(changed, p)
+		}
+		return nil
+	})
+	for p := range syncedFiles {
+		changed = append(changed, p)
+	}
+	if 0 < len(changed) {
+		logging.LogInfof("box [%s] has [%d] unsynced changes, such as [%s]", boxID, len(changed), changed[0])
+		return fmt.Errorf("notebook [%s] has local changes that have not been synced, please sync it before setting it to pull-only", boxID)
+	}
+	return
+}
@@ -0,0 +1,377 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Change 描述了一次已提交事务的变更记录。
+type Change struct {
+	Seq       int64    `json:"seq"`       // 序号
+	Cursor    string   `json:"cursor"`    // 游标，读取时填充
+	Timestamp int64    `json:"timestamp"` // 提交时间
+	Boxes     []string `json:"boxes"`     // 变更的笔记本 ID
+	Docs      []string `json:"docs"`      // 变更的文档 ID
+	BlockIDs  []string `json:"blockIDs"`  // 变更的块 ID
+	AvIDs     []string `json:"avIDs"`     // 变更的属性视图 ID
+	Actions   []string `json:"actions"`   // 操作类型
+}
+
+// ChangesResult 描述了变更日志的读取结果。
+type ChangesResult struct {
+	Changes        []*Change `json:"changes"`
+	Cursor         string    `json:"cursor"`         // 下一次读取使用的游标
+	HasMore        bool      `json:"hasMore"`        // 是否还有未读取的变更
+	ResyncRequired bool      `json:"resyncRequired"` // 游标已失效（被压缩或日志重建），需要全量同步
+}
+
+var (
+	changeSegmentSize = 2048 // 每个分段文件保存的变更数
+	changeSegmentMax  = 32   // 最多保留的分段文件数，超出后压缩最早的分段
+)
+
+// changeFeed 是按分段文件持久化的有界变更日志，目录结构为 changes/epoch 和 changes/{firstSeq}.jsonl。
+// epoch 在日志重建时重新生成，用于识别来自旧日志的游标。
+type changeFeed struct {
+	lock     sync.Mutex
+	inited   bool
+	dir      string
+	epoch    string
+	head     int64   // 最后一条变更的序号
+	segments []int64 // 各分段的起始序号，升序
+	count    int     // 最后一个分段中的变更数
+}
+
+var changes = &changeFeed{}
+
+func appendTxChange(tx *Transaction) {
+	change := &Change{Timestamp: time.Now().UnixMilli()}
+	for _, tree := range tx.trees {
+		change.Boxes = append(change.Boxes, tree.Box)
+		change.Docs = append(change.Docs, tree.ID)
+	}
+	for _, op := range tx.DoOperations {
+		change.Actions = append(change.Actions, op.Action)
+		if ast.IsNodeIDPattern(op.ID) {
+			change.BlockIDs = append(change.BlockIDs, op.ID)
+		}
+		if ast.IsNodeIDPattern(op.BlockID) {
+			change.BlockIDs = append(change.BlockIDs, op.BlockID)
+		}
+		for _, id := range op.BlockIDs {
+			if ast.IsNodeIDPattern(id) {
+				change.BlockIDs = append(change.BlockIDs, id)
+			}
+		}
+		if "" != op.AvID {
+			change.AvIDs = append(change.AvIDs, op.AvID)
+		}
+	}
+	appendChange(change)
+}
+
+// appendDocChange 记录不经过事务的文档级变更，比如文档树上的重命名、移动、删除以及标题和文档互转。
+func appendDocChange(action string, boxes, docIDs []string) {
+	change := &Change{Timestamp: time.Now().UnixMilli(), Boxes: boxes, Docs: docIDs, Actions: []string{action}}
+	appendChange(change)
+}
+
+// appendSyncChange 记录同步合并时更新和删除的数据文件，文件路径为 /{boxID}/.../{docID}.sy 和 /storage/av/{avID}.json。
+func appendSyncChange(upserts, removes []string) {
+	change := &Change{Timestamp: time.Now().UnixMilli(), Actions: []string{"sync"}}
+	for _, p := range append(upserts, removes...) {
+		name := path.Base(p)
+		if strings.HasPrefix(p, "/storage/av/") && strings.HasSuffix(name, ".json") {
+			if avID := strings.TrimSuffix(name, ".json"); ast.IsNodeIDPattern(avID) {
+				change.AvIDs = append(change.AvIDs, avID)
+			}
+			continue
+		}
+
+		if !strings.HasSuffix(name, ".sy") {
+			continue
+		}
+		docID := strings.TrimSuffix(name, ".sy")
+		boxID := strings.Split(strings.TrimPrefix(p, "/"), "/")[0]
+		if !ast.IsNodeIDPattern(docID) || !ast.IsNodeIDPattern(boxID) {
+			continue
+		}
+		change.Boxes = append(change.Boxes, boxID)
+		change.Docs = append(change.Docs, docID)
+	}
+	appendChange(change)
+}
+
+func appendChange(change *Change) {
+	if 1 > len(change.Docs) && 1 > len(change.AvIDs) {
+		return
+	}
+
+	change.Boxes = gulu.Str.RemoveDuplicatedElem(change.Boxes)
+	change.Docs = gulu.Str.RemoveDuplicatedElem(change.Docs)
+	change.BlockIDs = gulu.Str.RemoveDuplicatedElem(change.BlockIDs)
+	change.AvIDs = gulu.Str.RemoveDuplicatedElem(change.AvIDs)
+	change.Actions = gulu.Str.RemoveDuplicatedElem(change.Actions)
+	sort.Strings(change.Boxes)
+	sort.Strings(change.Docs)
+	sort.Strings(change.BlockIDs)
+	sort.Strings(change.AvIDs)
+	changes.append(change)
+}
+
+// GetChangesSince 返回游标之后的变更。游标为空时仅返回当前游标，调用方应先做一次全量同步。
+func GetChangesSince(cursor string, limit int) (ret *ChangesResult) {
+	if 1 > limit || 1024 < limit {
+		limit = 256
+	}
+	return changes.since(cursor, limit)
+}
+
+// resetChangeFeed 重建变更日志，已有的游标都会失效，客户端需要全量同步。
+//
+// 恢复快照和重建索引会直接修改数据文件，无法逐条记录变更。
+func resetChangeFeed() {
+	changes.reset()
+}
+
+func (feed *changeFeed) reset() {
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+
+	if !feed.init() {
+		return
+	}
+
+	epoch := newChangeFeedEpoch()
+	if epoch == feed.epoch {
+		epoch += "0"
+	}
+	if err := os.WriteFile(filepath.Join(feed.dir, "epoch"), []byte(epoch), 0644); err != nil {
+		logging.LogErrorf("write change feed epoch failed: %s", err)
+		return
+	}
+	feed.epoch = epoch
+
+	for _, first := range feed.segments {
+		if err := os.Remove(feed.segmentPath(first)); err != nil && !os.IsNotExist(err) {
+			logging.LogErrorf("remove change segment failed: %s", err)
+		}
+	}
+	feed.segments = nil
+	feed.count = 0
+}
+
+func (feed *changeFeed) append(change *Change) {
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+
+	if !feed.init() {
+		return
+	}
+
+	if 1 > len(feed.segments) || changeSegmentSize <= feed.count {
+		feed.segments = append(feed.segments, feed.head+1)
+		feed.count = 0
+		feed.compact()
+	}
+
+	change.Seq = feed.head + 1
+	data, err := gulu.JSON.MarshalJSON(change)
+	if err != nil {
+		logging.LogErrorf("marshal change failed: %s", err)
+		return
+	}
+	data = append(data, '\n')
+
+	segPath := feed.segmentPath(feed.segments[len(feed.segments)-1])
+	f, err := os.OpenFile(segPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logging.LogErrorf("open change segment [%s] failed: %s", segPath, err)
+		return
+	}
+	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		logging.LogErrorf("write change segment [%s] failed: %s", segPath, err)
+		return
+	}
+	feed.head = change.Seq
+	feed.count++
+}
+
+func (feed *changeFeed) since(cursor string, limit int) (ret *ChangesResult) {
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+
+	ret = &ChangesResult{Changes: []*Change{}}
+	if !feed.init() {
+		ret.ResyncRequired = true
+		return
+	}
+	ret.Cursor = feed.cursor(feed.head)
+
+	epoch, seq, ok := parseChangeCursor(cursor)
+	if !ok || epoch != feed.epoch || seq > feed.head {
+		ret.ResyncRequired = true
+		return
+	}
+	if seq == feed.head {
+		return
+	}
+	if 0 < len(feed.segments) && seq+1 < feed.segments[0] {
+		// 游标之后的变更已被压缩
+		ret.ResyncRequired = true
+		return
+	}
+
+	for i, first := range feed.segments {
+		if i+1 < len(feed.segments) && feed.segments[i+1] <= seq+1 {
+			continue
+		}
+
+		data, err := os.ReadFile(feed.segmentPath(first))
+		if err != nil {
+			logging.LogErrorf("read change segment failed: %s", err)
+			ret.ResyncRequired = true
+			ret.Changes = []*Change{}
+			return
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			change := &Change{}
+			if err = gulu.JSON.UnmarshalJSON(scanner.Bytes(), change); err != nil {
+				continue
+			}
+			if change.Seq <= seq {
+				continue
+			}
+			if len(ret.Changes) >= limit {
+				ret.HasMore = true
+				return
+			}
+			change.Cursor = feed.cursor(change.Seq)
+			ret.Changes = append(ret.Changes, change)
+			ret.Cursor = change.Cursor
+		}
+	}
+	return
+}
+
+func (feed *changeFeed) init() bool {
+	if feed.inited {
+		return true
+	}
+
+	feed.dir = filepath.Join(util.WorkspaceDir, "changes")
+	if err := os.MkdirAll(feed.dir, 0755); err != nil {
+		logging.LogErrorf("create change feed dir failed: %s", err)
+		return false
+	}
+
+	epochPath := filepath.Join(feed.dir, "epoch")
+	data, err := os.ReadFile(epochPath)
+	feed.epoch = strings.TrimSpace(string(data))
+	if err != nil || "" == feed.epoch {
+		feed.epoch = newChangeFeedEpoch()
+		if err = os.WriteFile(epochPath, []byte(feed.epoch), 0644); err != nil {
+			logging.LogErrorf("write change feed epoch failed: %s", err)
+			return false
+		}
+	}
+
+	entries, err := os.ReadDir(feed.dir)
+	if err != nil {
+		logging.LogErrorf("read change feed dir failed: %s", err)
+		return false
+	}
+	for _, entry := range entries {
+		first, parseErr := strconv.ParseInt(strings.TrimSuffix(entry.Name(), ".jsonl"), 10, 64)
+		if !strings.HasSuffix(entry.Name(), ".jsonl") || nil != parseErr {
+			continue
+		}
+		feed.segments = append(feed.segments, first)
+	}
+	sort.Slice(feed.segments, func(i, j int) bool { return feed.segments[i] < feed.segments[j] })
+
+	if 0 < len(feed.segments) {
+		last := feed.segments[len(feed.segments)-1]
+		feed.head = last - 1
+		if data, err = os.ReadFile(feed.segmentPath(last)); err == nil {
+			for _, line := range bytes.Split(data, []byte("\n")) {
+				change := &Change{}
+				if err = gulu.JSON.UnmarshalJSON(line, change); err != nil {
+					continue
+				}
+				if change.Seq > feed.head {
+					feed.head = change.Seq
+				}
+				feed.count++
+			}
+		}
+	}
+	feed.inited = true
+	return true
+}
+
+func (feed *changeFeed) compact() {
+	for changeSegmentMax < len(feed.segments) {
+		if err := os.Remove(feed.segmentPath(feed.segments[0])); err != nil && !os.IsNotExist(err) {
+			logging.LogErrorf("remove change segment failed: %s", err)
+		}
+		feed.segments = feed.segments[1:]
+	}
+}
+
+func newChangeFeedEpoch() string {
+	return strconv.FormatInt(time.Now().UnixMilli(), 36)
+}
+
+func (feed *changeFeed) segmentPath(first int64) string {
+	return filepath.Join(feed.dir, strconv.FormatInt(first, 10)+".jsonl")
+}
+
+func (feed *changeFeed) cursor(seq int64) string {
+	return feed.epoch + "-" + strconv.FormatInt(seq, 10)
+}
+
+func parseChangeCursor(cursor string) (epoch string, seq int64, ok bool) {
+	epoch, seqStr, found := strings.Cut(cursor, "-")
+	if !found || "" == epoch {
+		return
+	}
+	seq, err := strconv.ParseInt(seqStr, 10, 64)
+	if err != nil || 0 > seq {
+		return
+	}
+	ok = true
+	return
+}
@@ -0,0 +1,161 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func setupTestChangeFeed(t *testing.T, segmentSize, segmentMax int) {
+	oldWorkspaceDir, oldChanges := util.WorkspaceDir, changes
+	oldSegmentSize, oldSegmentMax := changeSegmentSize, changeSegmentMax
+	t.Cleanup(func() {
+		util.WorkspaceDir, changes = oldWorkspaceDir, oldChanges
+		changeSegmentSize, changeSegmentMax = oldSegmentSize, oldSegmentMax
+	})
+
+	util.WorkspaceDir = t.TempDir()
+	changes = &changeFeed{}
+	changeSegmentSize, changeSegmentMax = segmentSize, segmentMax
+}
+
+func appendTestDocChanges(from, to int) {
+	for i := from; i <= to; i++ {
+		appendDocChange("renameDoc", []string{"box"}, []string{fmt.Sprintf("20240101000000-%07d", i)})
+	}
+}
+
+func TestChangeFeedCursor(t *testing.T) {
+	setupTestChangeFeed(t, 4, 8)
+
+	result := GetChangesSince("", 10)
+	if !result.ResyncRequired || "" == result.Cursor {
+		t.Fatalf("expected resync with a cursor for empty cursor, got [%+v]", result)
+	}
+	cursor := result.Cursor
+
+	appendTestDocChanges(1, 10)
+	result = GetChangesSince(cursor, 4)
+	if result.ResyncRequired || !result.HasMore || 4 != len(result.Changes) {
+		t.Fatalf("expected 4 changes with more, got [%+v]", result)
+	}
+	for i, change := range result.Changes {
+		if int64(i+1) != change.Seq || "" == change.Cursor {
+			t.Fatalf("unexpected change [%+v] at [%d]", change, i)
+		}
+	}
+	if result.Cursor != result.Changes[3].Cursor {
+		t.Fatalf("expected cursor [%s], got [%s]", result.Changes[3].Cursor, result.Cursor)
+	}
+
+	result = GetChangesSince(result.Cursor, 100)
+	if result.ResyncRequired || result.HasMore || 6 != len(result.Changes) || 5 != result.Changes[0].Seq || 10 != result.Changes[5].Seq {
+		t.Fatalf("expected changes 5-10, got [%+v]", result)
+	}
+	head := result.Cursor
+
+	result = GetChangesSince(head, 100)
+	if result.ResyncRequired || 0 != len(result.Changes) || head != result.Cursor {
+		t.Fatalf("expected no changes at head, got [%+v]", result)
+	}
+
+	epoch, _, _ := parseChangeCursor(head)
+	for _, invalid := range []string{"invalid", epoch + "-11", epoch + "-x", "other-1"} {
+		if result = GetChangesSince(invalid, 100); !result.ResyncRequired {
+			t.Fatalf("expected resync for cursor [%s]", invalid)
+		}
+	}
+
+	// 重新加载后游标仍然有效
+	changes = &changeFeed{}
+	appendTestDocChanges(11, 11)
+	result = GetChangesSince(head, 100)
+	if result.ResyncRequired || 1 != len(result.Changes) || 11 != result.Changes[0].Seq {
+		t.Fatalf("expected change 11 after reload, got [%+v]", result)
+	}
+
+	// 重建后旧游标失效
+	resetChangeFeed()
+	if result = GetChangesSince(head, 100); !result.ResyncRequired {
+		t.Fatalf("expected resync after reset, got [%+v]", result)
+	}
+	appendTestDocChanges(12, 12)
+	result = GetChangesSince(result.Cursor, 100)
+	if result.ResyncRequired || 1 != len(result.Changes) || 12 != result.Changes[0].Seq {
+		t.Fatalf("expected change 12 after reset, got [%+v]", result)
+	}
+}
+
+func TestChangeFeedCompaction(t *testing.T) {
+	setupTestChangeFeed(t, 4, 3)
+
+	start := GetChangesSince("", 10).Cursor
+	appendTestDocChanges(1, 6)
+	middle := GetChangesSince(start, 6).Cursor
+
+	// 第 4 个分段创建时压缩第 1 个分段，剩余分段的起始序号为 5、9、13
+	appendTestDocChanges(7, 14)
+	if 3 != len(changes.segments) || 5 != changes.segments[0] {
+		t.Fatalf("expected 3 segments starting at 5, got [%v]", changes.segments)
+	}
+
+	if result := GetChangesSince(start, 100); !result.ResyncRequired {
+		t.Fatalf("expected resync for compacted cursor, got [%+v]", result)
+	}
+
+	result := GetChangesSince(middle, 100)
+	if result.ResyncRequired || 8 != len(result.Changes) || 7 != result.Changes[0].Seq || 14 != result.Changes[7].Seq {
+		t.Fatalf("expected changes 7-14, got [%+v]", result)
+	}
+
+	epoch, _, _ := parseChangeCursor(middle)
+	result = GetChangesSince(epoch+"-4", 100)
+	if result.ResyncRequired || 10 != len(result.Changes) || 5 != result.Changes[0].Seq {
+		t.Fatalf("expected changes 5-14 from the compaction boundary, got [%+v]", result)
+	}
+	if result = GetChangesSince(epoch+"-3", 100); !result.ResyncRequired {
+		t.Fatalf("expected resync before the compaction boundary, got [%+v]", result)
+	}
+}
+
+func TestAppendSyncChange(t *testing.T) {
+	setupTestChangeFeed(t, 4, 8)
+
+	cursor := GetChangesSince("", 10).Cursor
+	appendSyncChange([]string{
+		"/20240101000000-aaaaaaa/20240101000000-bbbbbbb.sy",
+		"/20240101000000-aaaaaaa/20240101000000-bbbbbbb/20240101000000-ccccccc.sy",
+		"/storage/av/20240101000000-ddddddd.json",
+		"/assets/image.png",
+	}, []string{"/20240101000000-eeeeeee/20240101000000-fffffff.sy"})
+	appendSyncChange([]string{"/storage/riff/cards.json"}, nil)
+
+	result := GetChangesSince(cursor, 10)
+	if result.ResyncRequired || 1 != len(result.Changes) {
+		t.Fatalf("expected one sync change, got [%+v]", result)
+	}
+	change := result.Changes[0]
+	if "20240101000000-aaaaaaa,20240101000000-eeeeeee" != strings.Join(change.Boxes, ",") ||
+		"20240101000000-bbbbbbb,20240101000000-ccccccc,20240101000000-fffffff" != strings.Join(change.Docs, ",") ||
+		"20240101000000-ddddddd" != strings.Join(change.AvIDs, ",") || "sync" != strings.Join(change.Actions, ",") {
+		t.Fatalf("unexpected sync change [%+v]", change)
+	}
+}
@@ -0,0 +1,311 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// LANCloud 描述了局域网同步使用的仓库，仓库数据由某个内核托管，其他内核通过 HTTP 直接读写。
+type LANCloud struct {
+	*cloud.BaseCloud
+	store lanObjectStore
+}
+
+// lanObjectStore 是局域网托管仓库的对象读写接口，托管仓库的内核直接读写本地文件，其他内核通过 HTTP 读写。
+type lanObjectStore interface {
+	repoObjectStore
+	put(key string, data []byte, overwrite bool) (length int64, err error)
+	remove(key string) (err error)
+}
+
+func NewLANCloud(baseCloud *cloud.BaseCloud, store lanObjectStore) *LANCloud {
+	return &LANCloud{BaseCloud: baseCloud, store: store}
+}
+
+func newLANCloudConf() *cloud.Conf {
+	return &cloud.Conf{
+		Dir:           "main",
+		UserID:        "0",
+		AvailableSize: int64(1024 * 1024 * 1024 * 1024 * 2),
+		Server:        util.GetCloudServer(),
+	}
+}
+
+func (s *LANCloud) CreateRepo(name string) (err error) {
+	return
+}
+
+func (s *LANCloud) RemoveRepo(name string) (err error) {
+	return errors.New("removing LAN repo is not supported")
+}
+
+func (s *LANCloud) GetRepos() (repos []*cloud.Repo, size int64, err error) {
+	repos = []*cloud.Repo{{Name: s.Conf.Dir, Updated: time.Now().Format("2006-01-02 15:04:05")}}
+	return
+}
+
+func (s *LANCloud) UploadObject(filePath string, overwrite bool) (length int64, err error) {
+	absFilePath := filepath.Join(s.Conf.RepoPath, filePath)
+	data, err := os.ReadFile(absFilePath)
+	if err != nil {
+		return
+	}
+	return s.UploadBytes(filePath, data, overwrite)
+}
+
+func (s *LANCloud) UploadBytes(filePath string, data []byte, overwrite bool) (length int64, err error) {
+	return s.store.put(filePath, data, overwrite)
+}
+
+func (s *LANCloud) DownloadObject(filePath string) (data []byte, err error) {
+	return s.store.get(filePath)
+}
+
+func (s *LANCloud) RemoveObject(filePath string) (err error) {
+	return s.store.remove(filePath)
+}
+
+func (s *LANCloud) ListObjects(pathPrefix string) (ret map[string]*entity.ObjectInfo, err error) {
+	return listRepoObjects(s.store, pathPrefix)
+}
+
+func (s *LANCloud) GetTags() (tags []*cloud.Ref, err error) {
+	tags, err = listRepoRefs(s.store, "tags")
+	if nil == tags {
+		tags = []*cloud.Ref{}
+	}
+	return
+}
+
+func (s *LANCloud) GetIndex(id string) (index *entity.Index, err error) {
+	return getRepoIndex(s.store, id)
+}
+
+func (s *LANCloud) GetRefsFiles() (fileIDs []string, refs []*cloud.Ref, err error) {
+	return getRepoRefsFiles(s.store)
+}
+
+func (s *LANCloud) GetChunks(checkChunkIDs []string) (chunkIDs []string, err error) {
+	return getRepoChunks(s.store, checkChunkIDs)
+}
+
+func (s *LANCloud) GetConcurrentReqs() int {
+	return 8
+}
+
+// localLANStore 读写本内核托管的局域网仓库。
+type localLANStore struct {
+	dir string
+}
+
+func newLocalLANStore() *localLANStore {
+	return &localLANStore{dir: filepath.Join(util.WorkspaceDir, "lan", "repo")}
+}
+
+func (store *localLANStore) absPath(key string) (ret string, err error) {
+	key = path.Clean("/" + key)
+	if "/" == key {
+		err = fmt.Errorf("invalid object key [%s]", key)
+		return
+	}
+	ret = filepath.Join(store.dir, filepath.FromSlash(key))
+	return
+}
+
+func (store *localLANStore) put(key string, data []byte, overwrite bool) (length int64, err error) {
+	absPath, err := store.absPath(key)
+	if err != nil {
+		return
+	}
+	if !overwrite && gulu.File.IsExist(absPath) {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return
+	}
+	if err = gulu.File.WriteFileSafer(absPath, data, 0644); err != nil {
+		return
+	}
+	length = int64(len(data))
+	return
+}
+
+func (store *localLANStore) get(key string) (data []byte, err error) {
+	absPath, err := store.absPath(key)
+	if err != nil {
+		return
+	}
+
+	if data, err = os.ReadFile(absPath); nil != err && errors.Is(err, os.ErrNotExist) {
+		err = cloud.ErrCloudObjectNotFound
+	}
+	return
+}
+
+func (store *localLANStore) remove(key string) (err error) {
+	absPath, err := store.absPath(key)
+	if err != nil {
+		return
+	}
+
+	if err = os.Remove(absPath); nil != err && errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+func (store *localLANStore) list(prefix string) (ret []*RepoObject, err error) {
+	ret = []*RepoObject{}
+	absPath, err := store.absPath(prefix)
+	if err != nil {
+		return
+	}
+
+	entries, err := os.ReadDir(absPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, infoErr := entry.Info()
+		if nil != infoErr {
+			continue
+		}
+		ret = append(ret, &RepoObject{Name: entry.Name(), Size: info.Size(), Updated: info.ModTime().UnixMilli()})
+	}
+	return
+}
+
+func (store *localLANStore) missing(keys []string) (ret []string, err error) {
+	ret = []string{}
+	for _, key := range keys {
+		absPath, pathErr := store.absPath(key)
+		if nil != pathErr {
+			err = pathErr
+			return
+		}
+		if !gulu.File.IsExist(absPath) {
+			ret = append(ret, key)
+		}
+	}
+	return
+}
+
+// httpLANStore 通过 HTTP 读写其他内核托管的局域网仓库。
+type httpLANStore struct {
+	addr   string
+	client *http.Client
+}
+
+func newHTTPLANStore(addr string) *httpLANStore {
+	return &httpLANStore{addr: addr, client: &http.Client{Timeout: 2 * time.Minute}}
+}
+
+// request 请求其他内核的局域网接口，请求和响应数据使用局域网传输密钥加密，见 lanPayloadKey。
+func (store *httpLANStore) request(action string, query url.Values, body []byte) (ret []byte, err error) {
+	if body, err = EncryptLANPayload(body); err != nil {
+		return
+	}
+
+	u := &url.URL{Scheme: "http", Host: store.addr, Path: "/api/lan/" + action, RawQuery: query.Encode()}
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	signLANRequest(req, body)
+
+	resp, err := store.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if ret, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		ret, err = decryptLANPayload(ret)
+	case http.StatusNotFound:
+		err = cloud.ErrCloudObjectNotFound
+	default:
+		err = fmt.Errorf("request LAN peer [%s] failed [%d]: %s", u.String(), resp.StatusCode, bytes.TrimSpace(ret))
+	}
+	return
+}
+
+func (store *httpLANStore) put(key string, data []byte, overwrite bool) (length int64, err error) {
+	if _, err = store.request("putObject", url.Values{"key": {key}, "overwrite": {strconv.FormatBool(overwrite)}}, data); err != nil {
+		return
+	}
+	length = int64(len(data))
+	return
+}
+
+func (store *httpLANStore) get(key string) (data []byte, err error) {
+	return store.request("getObject", url.Values{"key": {key}}, nil)
+}
+
+func (store *httpLANStore) remove(key string) (err error) {
+	_, err = store.request("removeObject", url.Values{"key": {key}}, nil)
+	return
+}
+
+func (store *httpLANStore) list(prefix string) (ret []*RepoObject, err error) {
+	data, err := store.request("listObjects", url.Values{"prefix": {prefix}}, nil)
+	if err != nil {
+		return
+	}
+	err = gulu.JSON.UnmarshalJSON(data, &ret)
+	return
+}
+
+func (store *httpLANStore) missing(keys []string) (ret []string, err error) {
+	body, err := gulu.JSON.MarshalJSON(keys)
+	if err != nil {
+		return
+	}
+	data, err := store.request("missingObjects", nil, body)
+	if err != nil {
+		return
+	}
+	err = gulu.JSON.UnmarshalJSON(data, &ret)
+	return
+}
@@ -0,0 +1,145 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"path"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/klauspost/compress/zstd"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/dejavu/entity"
+)
+
+// RepoObject 描述了远端仓库中的一个对象。
+type RepoObject struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	Updated int64  `json:"updated"`
+}
+
+// repoObjectStore 是按对象键直接读取远端仓库的接口，对象键相对于仓库目录，例如 indexes/{id}。
+//
+// SFTP 和局域网仓库没有服务端逻辑，索引、引用和分块的查询都通过读取仓库对象实现，由该接口上的函数共用。
+type repoObjectStore interface {
+	// get 读取对象，对象不存在时返回 cloud.ErrCloudObjectNotFound。
+	get(key string) (data []byte, err error)
+	// list 列出目录下的对象，不包含子目录，目录不存在时返回空。
+	list(prefix string) (ret []*RepoObject, err error)
+	// missing 返回不存在的对象键。
+	missing(keys []string) (ret []string, err error)
+}
+
+// repoCompressDecoder 解压仓库中使用 zstd 压缩的索引。
+var repoCompressDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+
+func listRepoObjects(store repoObjectStore, pathPrefix string) (ret map[string]*entity.ObjectInfo, err error) {
+	ret = map[string]*entity.ObjectInfo{}
+	objects, err := store.list(pathPrefix)
+	if err != nil {
+		return
+	}
+
+	for _, object := range objects {
+		ret[object.Name] = &entity.ObjectInfo{Path: object.Name, Size: object.Size}
+	}
+	return
+}
+
+func getRepoIndex(store repoObjectStore, id string) (index *entity.Index, err error) {
+	data, err := store.get(path.Join("indexes", id))
+	if err != nil {
+		return
+	}
+
+	if data, err = repoCompressDecoder.DecodeAll(data, nil); err != nil {
+		return
+	}
+
+	index = &entity.Index{}
+	err = gulu.JSON.UnmarshalJSON(data, index)
+	return
+}
+
+// getRepoRefsFiles 返回所有引用（包括标记）指向的索引中的文件 ID，索引已经不存在的引用会被跳过。
+func getRepoRefsFiles(store repoObjectStore) (fileIDs []string, refs []*cloud.Ref, err error) {
+	if refs, err = listRepoRefs(store, ""); err != nil {
+		return
+	}
+	tags, err := listRepoRefs(store, "tags")
+	if err != nil {
+		return
+	}
+	refs = append(refs, tags...)
+
+	var files []string
+	for _, ref := range refs {
+		index, getErr := getRepoIndex(store, ref.ID)
+		if nil != getErr {
+			if errors.Is(getErr, cloud.ErrCloudObjectNotFound) {
+				continue
+			}
+			err = getErr
+			return
+		}
+		files = append(files, index.Files...)
+	}
+	fileIDs = gulu.Str.RemoveDuplicatedElem(files)
+	return
+}
+
+// getRepoChunks 返回仓库中不存在的分块 ID。
+func getRepoChunks(store repoObjectStore, checkChunkIDs []string) (chunkIDs []string, err error) {
+	var keys []string
+	for _, chunk := range checkChunkIDs {
+		keys = append(keys, path.Join("objects", chunk[:2], chunk[2:]))
+	}
+	missingKeys, err := store.missing(keys)
+	if err != nil {
+		return
+	}
+
+	for _, key := range missingKeys {
+		chunkIDs = append(chunkIDs, path.Base(path.Dir(key))+path.Base(key))
+	}
+	return
+}
+
+func listRepoRefs(store repoObjectStore, refPrefix string) (ret []*cloud.Ref, err error) {
+	keyPath := path.Join("refs", refPrefix)
+	objects, err := store.list(keyPath)
+	if err != nil {
+		return
+	}
+
+	for _, object := range objects {
+		data, getErr := store.get(path.Join(keyPath, object.Name))
+		if nil != getErr {
+			err = getErr
+			return
+		}
+
+		ret = append(ret, &cloud.Ref{
+			Name:    object.Name,
+			ID:      string(data),
+			Updated: time.UnixMilli(object.Updated).Format("2006-01-02 15:04:05"),
+		})
+	}
+	return
+}
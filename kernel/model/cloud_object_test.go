@@ -0,0 +1,81 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/dejavu/entity"
+)
+
+// testRepoCloud 是基于 repoObjectStore 实现的云端存储，SFTP 和局域网同步共用同一组测试数据。
+type testRepoCloud interface {
+	UploadBytes(filePath string, data []byte, overwrite bool) (length int64, err error)
+	GetIndex(id string) (index *entity.Index, err error)
+	GetRefsFiles() (fileIDs []string, refs []*cloud.Ref, err error)
+	GetTags() (tags []*cloud.Ref, err error)
+}
+
+// uploadTestRepoRefs 上传两个索引以及分别指向它们的 latest 和 tags/v1 引用。
+func uploadTestRepoRefs(t *testing.T, s testRepoCloud) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("create zstd encoder failed: %s", err)
+	}
+	uploadIndex := func(id string, files ...string) {
+		data := `{"id":"` + id + `","files":["` + strings.Join(files, `","`) + `"]}`
+		if _, uploadErr := s.UploadBytes("indexes/"+id, encoder.EncodeAll([]byte(data), nil), true); nil != uploadErr {
+			t.Fatalf("upload index failed: %s", uploadErr)
+		}
+	}
+	uploadIndex("latest-index", "f1", "f2")
+	uploadIndex("tag-index", "f2", "f3")
+	if _, err = s.UploadBytes("refs/latest", []byte("latest-index"), true); err != nil {
+		t.Fatalf("upload latest ref failed: %s", err)
+	}
+	if _, err = s.UploadBytes("refs/tags/v1", []byte("tag-index"), true); err != nil {
+		t.Fatalf("upload tag failed: %s", err)
+	}
+}
+
+func checkTestRepoRefs(t *testing.T, s testRepoCloud) {
+	index, err := s.GetIndex("latest-index")
+	if err != nil || "latest-index" != index.ID || 2 != len(index.Files) {
+		t.Fatalf("unexpected index [index=%v, err=%v]", index, err)
+	}
+	if _, err = s.GetIndex("missing"); err != cloud.ErrCloudObjectNotFound {
+		t.Fatalf("expected index not found, got [%v]", err)
+	}
+
+	fileIDs, refs, err := s.GetRefsFiles()
+	if err != nil {
+		t.Fatalf("get refs files failed: %s", err)
+	}
+	sort.Strings(fileIDs)
+	if "f1,f2,f3" != strings.Join(fileIDs, ",") || 2 != len(refs) {
+		t.Fatalf("unexpected refs files [files=%v, refs=%v]", fileIDs, refs)
+	}
+
+	tags, err := s.GetTags()
+	if err != nil || 1 != len(tags) || "v1" != tags[0].Name || "tag-index" != tags[0].ID {
+		t.Fatalf("unexpected tags [tags=%v, err=%v]", tags, err)
+	}
+}
@@ -0,0 +1,374 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPCloud 描述了 SFTP 云端存储服务，仓库布局和 WebDAV 一致：{path}/siyuan/repo/{cloudName}/。
+type SFTPCloud struct {
+	*cloud.BaseCloud
+	SFTP *conf.SFTP
+
+	sshClient  *ssh.Client
+	sftpClient *sftp.Client
+	lock       sync.Mutex
+}
+
+func NewSFTPCloud(baseCloud *cloud.BaseCloud, sftpConf *conf.SFTP) *SFTPCloud {
+	return &SFTPCloud{BaseCloud: baseCloud, SFTP: sftpConf}
+}
+
+// client 返回可用的 SFTP 客户端，连接断开后会重新建立连接。
+func (s *SFTPCloud) client() (ret *sftp.Client, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if nil != s.sftpClient {
+		if _, err = s.sftpClient.Getwd(); nil == err {
+			return s.sftpClient, nil
+		}
+		s.closeClient()
+	}
+
+	sshConf, pinned, err := newSFTPClientConfig(s.SFTP)
+	if err != nil {
+		return
+	}
+
+	addr := net.JoinHostPort(s.SFTP.Host, strconv.Itoa(s.SFTP.Port))
+	if s.sshClient, err = ssh.Dial("tcp", addr, sshConf); err != nil {
+		logging.LogErrorf("dial SFTP server [%s] failed: %s", addr, err)
+		return
+	}
+	if s.sftpClient, err = sftp.NewClient(s.sshClient, sftp.UseConcurrentWrites(true)); err != nil {
+		logging.LogErrorf("create SFTP client [%s] failed: %s", addr, err)
+		s.sshClient.Close()
+		s.sshClient = nil
+		return
+	}
+
+	if "" != *pinned {
+		// 连接成功后再保存首次记录的公钥指纹，避免在握手过程中写入配置
+		s.SFTP.HostKey = *pinned
+		Conf.Save()
+		logging.LogInfof("pinned SFTP host key [%s] for [%s]", *pinned, addr)
+	}
+	ret = s.sftpClient
+	return
+}
+
+func (s *SFTPCloud) closeClient() {
+	if nil != s.sftpClient {
+		s.sftpClient.Close()
+		s.sftpClient = nil
+	}
+	if nil != s.sshClient {
+		s.sshClient.Close()
+		s.sshClient = nil
+	}
+}
+
+// newSFTPClientConfig 构造 SSH 客户端配置，没有配置服务端公钥指纹时 pinned 会在握手时被设置为服务端公钥指纹，由调用方在连接成功后保存。
+func newSFTPClientConfig(sftpConf *conf.SFTP) (ret *ssh.ClientConfig, pinned *string, err error) {
+	var auths []ssh.AuthMethod
+	if "" != sftpConf.PrivateKey {
+		var signer ssh.Signer
+		if "" != sftpConf.Passphrase {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(sftpConf.PrivateKey), []byte(sftpConf.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(sftpConf.PrivateKey))
+		}
+		if err != nil {
+			logging.LogErrorf("parse SFTP private key failed: %s", err)
+			return
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if "" != sftpConf.Password {
+		auths = append(auths, ssh.Password(sftpConf.Password))
+	}
+	if 1 > len(auths) {
+		err = errors.New("SFTP password or private key is required")
+		return
+	}
+
+	// 没有配置服务端公钥指纹时在首次连接时记录（TOFU），之后公钥变化时拒绝连接
+	pinned = new(string)
+	hostKey := sftpConf.HostKey
+	hostKeyCallback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		if "" == hostKey {
+			*pinned = fingerprint
+			return nil
+		}
+		if fingerprint != hostKey {
+			return fmt.Errorf("SFTP host key mismatch [expected=%s, actual=%s]", hostKey, fingerprint)
+		}
+		return nil
+	}
+
+	ret = &ssh.ClientConfig{
+		User:            sftpConf.Username,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         time.Duration(sftpConf.Timeout) * time.Second,
+	}
+	return
+}
+
+func (s *SFTPCloud) getRepoRootPath() string {
+	return path.Join(s.SFTP.Path, "siyuan", "repo")
+}
+
+func (s *SFTPCloud) getCurrentRepoDirPath() string {
+	return path.Join(s.getRepoRootPath(), s.Conf.Dir)
+}
+
+func (s *SFTPCloud) CreateRepo(name string) (err error) {
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+	return c.MkdirAll(path.Join(s.getRepoRootPath(), name))
+}
+
+func (s *SFTPCloud) RemoveRepo(name string) (err error) {
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+
+	repoPath := path.Join(s.getRepoRootPath(), name)
+	if err = c.RemoveAll(repoPath); nil != err && errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+func (s *SFTPCloud) GetRepos() (repos []*cloud.Repo, size int64, err error) {
+	repos = []*cloud.Repo{}
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+
+	infos, err := c.ReadDir(s.getRepoRootPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+
+		var repoSize int64
+		walker := c.Walk(path.Join(s.getRepoRootPath(), info.Name()))
+		for walker.Step() {
+			if nil == walker.Err() && !walker.Stat().IsDir() {
+				repoSize += walker.Stat().Size()
+			}
+		}
+
+		repos = append(repos, &cloud.Repo{
+			Name:    info.Name(),
+			Size:    repoSize,
+			Updated: info.ModTime().Format("2006-01-02 15:04:05"),
+		})
+		size += repoSize
+	}
+	return
+}
+
+func (s *SFTPCloud) UploadObject(filePath string, overwrite bool) (length int64, err error) {
+	absFilePath := filepath.Join(s.Conf.RepoPath, filePath)
+	data, err := os.ReadFile(absFilePath)
+	if err != nil {
+		return
+	}
+	return s.UploadBytes(filePath, data, overwrite)
+}
+
+func (s *SFTPCloud) UploadBytes(filePath string, data []byte, overwrite bool) (length int64, err error) {
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+
+	key := path.Join(s.getCurrentRepoDirPath(), filePath)
+	if !overwrite {
+		if _, statErr := c.Stat(key); nil == statErr {
+			return
+		}
+	}
+
+	if err = c.MkdirAll(path.Dir(key)); err != nil {
+		return
+	}
+
+	// 先写入临时文件再重命名，避免中断后留下不完整的对象
+	tmp := key + ".tmp"
+	f, err := c.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	if err = c.PosixRename(tmp, key); err != nil {
+		// 服务端不支持 posix-rename@openssh.com 扩展时先删除目标文件再重命名
+		if removeErr := c.Remove(key); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+			return
+		}
+		if err = c.Rename(tmp, key); err != nil {
+			return
+		}
+	}
+	length = int64(len(data))
+	return
+}
+
+func (s *SFTPCloud) DownloadObject(filePath string) (data []byte, err error) {
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+
+	key := path.Join(s.getCurrentRepoDirPath(), filePath)
+	f, err := c.Open(key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = cloud.ErrCloudObjectNotFound
+		}
+		return
+	}
+	defer f.Close()
+
+	data, err = io.ReadAll(f)
+	return
+}
+
+func (s *SFTPCloud) RemoveObject(filePath string) (err error) {
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+
+	key := path.Join(s.getCurrentRepoDirPath(), filePath)
+	if err = c.Remove(key); nil != err && errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+func (s *SFTPCloud) ListObjects(pathPrefix string) (ret map[string]*entity.ObjectInfo, err error) {
+	return listRepoObjects(s, pathPrefix)
+}
+
+func (s *SFTPCloud) GetTags() (tags []*cloud.Ref, err error) {
+	tags, err = listRepoRefs(s, "tags")
+	if nil == tags {
+		tags = []*cloud.Ref{}
+	}
+	return
+}
+
+func (s *SFTPCloud) GetIndex(id string) (index *entity.Index, err error) {
+	return getRepoIndex(s, id)
+}
+
+func (s *SFTPCloud) GetRefsFiles() (fileIDs []string, refs []*cloud.Ref, err error) {
+	return getRepoRefsFiles(s)
+}
+
+func (s *SFTPCloud) GetChunks(checkChunkIDs []string) (chunkIDs []string, err error) {
+	return getRepoChunks(s, checkChunkIDs)
+}
+
+func (s *SFTPCloud) GetConcurrentReqs() int {
+	return s.SFTP.ConcurrentReqs
+}
+
+func (s *SFTPCloud) get(key string) (data []byte, err error) {
+	return s.DownloadObject(key)
+}
+
+func (s *SFTPCloud) list(prefix string) (ret []*RepoObject, err error) {
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+
+	infos, err := c.ReadDir(path.Join(s.getCurrentRepoDirPath(), prefix))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		ret = append(ret, &RepoObject{Name: info.Name(), Size: info.Size(), Updated: info.ModTime().UnixMilli()})
+	}
+	return
+}
+
+func (s *SFTPCloud) missing(keys []string) (ret []string, err error) {
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+
+	for _, key := range keys {
+		if _, statErr := c.Stat(path.Join(s.getCurrentRepoDirPath(), key)); nil != statErr {
+			if !errors.Is(statErr, os.ErrNotExist) {
+				err = statErr
+				return
+			}
+			ret = append(ret, key)
+		}
+	}
+	return
+}
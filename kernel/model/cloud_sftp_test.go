@@ -0,0 +1,194 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"golang.org/x/crypto/ssh"
+)
+
+// startTestSFTPServer 在本地启动一个使用密码认证的 SFTP 服务，返回监听端口和服务端公钥指纹。
+func startTestSFTPServer(t *testing.T) (port int, fingerprint string) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key failed: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("create host key signer failed: %s", err)
+	}
+
+	serverConf := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if "siyuan" == c.User() && "pass" == string(pass) {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	serverConf.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if nil != acceptErr {
+				return
+			}
+			go serveTestSFTPConn(conn, serverConf)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, ssh.FingerprintSHA256(signer.PublicKey())
+}
+
+func serveTestSFTPConn(conn net.Conn, serverConf *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, serverConf)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if "session" != newChannel.ChannelType() {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, acceptErr := newChannel.Accept()
+		if nil != acceptErr {
+			return
+		}
+
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				ok := "subsystem" == req.Type && "sftp" == string(req.Payload[4:])
+				req.Reply(ok, nil)
+			}
+		}(requests)
+
+		server, serverErr := sftp.NewServer(channel)
+		if nil != serverErr {
+			return
+		}
+		go func() {
+			server.Serve()
+			server.Close()
+		}()
+	}
+}
+
+func newTestSFTPCloud(t *testing.T, port int, hostKey string) (ret *SFTPCloud, root string) {
+	root = t.TempDir()
+	sftpConf := &conf.SFTP{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "siyuan",
+		Password: "pass",
+		HostKey:  hostKey,
+		Path:     filepath.ToSlash(root),
+		Timeout:  5,
+	}
+	ret = NewSFTPCloud(&cloud.BaseCloud{Conf: &cloud.Conf{Dir: "main", RepoPath: t.TempDir()}}, sftpConf)
+	t.Cleanup(ret.closeClient)
+	return
+}
+
+func TestSFTPCloudObjects(t *testing.T) {
+	port, fingerprint := startTestSFTPServer(t)
+	s, root := newTestSFTPCloud(t, port, fingerprint)
+
+	if err := s.CreateRepo("main"); err != nil {
+		t.Fatalf("create repo failed: %s", err)
+	}
+	if _, err := s.UploadBytes("objects/ab/cdef", []byte("chunk"), false); err != nil {
+		t.Fatalf("upload object failed: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "siyuan", "repo", "main", "objects", "ab", "cdef")); err != nil {
+		t.Fatalf("uploaded object not found: %s", err)
+	}
+	// 不覆盖时保留已有对象
+	if _, err := s.UploadBytes("objects/ab/cdef", []byte("other"), false); err != nil {
+		t.Fatalf("upload object failed: %s", err)
+	}
+
+	data, err := s.DownloadObject("objects/ab/cdef")
+	if err != nil || "chunk" != string(data) {
+		t.Fatalf("download object failed [data=%s, err=%v]", data, err)
+	}
+	if _, err = s.DownloadObject("objects/ab/missing"); err != cloud.ErrCloudObjectNotFound {
+		t.Fatalf("expected object not found, got [%v]", err)
+	}
+
+	chunks, err := s.GetChunks([]string{"abcdef", "ab0000"})
+	if err != nil || 1 != len(chunks) || "ab0000" != chunks[0] {
+		t.Fatalf("unexpected missing chunks [chunks=%v, err=%v]", chunks, err)
+	}
+
+	if err = s.RemoveObject("objects/ab/cdef"); err != nil {
+		t.Fatalf("remove object failed: %s", err)
+	}
+	if _, err = s.DownloadObject("objects/ab/cdef"); err != cloud.ErrCloudObjectNotFound {
+		t.Fatalf("expected removed object not found, got [%v]", err)
+	}
+
+	repos, _, err := s.GetRepos()
+	if err != nil || 1 != len(repos) || "main" != repos[0].Name {
+		t.Fatalf("unexpected repos [repos=%v, err=%v]", repos, err)
+	}
+}
+
+func TestSFTPCloudRefsFiles(t *testing.T) {
+	port, fingerprint := startTestSFTPServer(t)
+	s, _ := newTestSFTPCloud(t, port, fingerprint)
+	uploadTestRepoRefs(t, s)
+	checkTestRepoRefs(t, s)
+}
+
+func TestSFTPCloudHostKeyMismatch(t *testing.T) {
+	port, _ := startTestSFTPServer(t)
+	s, _ := newTestSFTPCloud(t, port, "SHA256:mismatch")
+
+	if _, err := s.client(); nil == err || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Fatalf("expected host key mismatch, got [%v]", err)
+	}
+}
+
+func TestSFTPCloudPinHostKey(t *testing.T) {
+	port, fingerprint := startTestSFTPServer(t)
+	s, _ := newTestSFTPCloud(t, port, "")
+
+	if _, err := s.client(); err != nil {
+		t.Fatalf("connect failed: %s", err)
+	}
+	if fingerprint != s.SFTP.HostKey {
+		t.Fatalf("expected pinned host key [%s], got [%s]", fingerprint, s.SFTP.HostKey)
+	}
+}
@@ -0,0 +1,399 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 多端协同编辑：服务端为每个文档维护递增的序号，并缓存最近提交的叶子块 Markdown 版本。
+// 客户端在 update 操作中携带基于的文档序号 baseSeq，若该块在此之后被其他会话修改过，则以 baseSeq 时的版本为基准做三方合并。
+// 序号仅保存在内存中，所以文档序号从进程启动时间派生的 collabSeqBase 开始，重启前的序号都小于它，可以被识别出来。
+
+const (
+	collabBlockVersionsMax = 32               // 每个块最多缓存的版本数
+	collabDocIdleTimeout   = 30 * time.Minute // 文档空闲多久后清理缓存的块版本
+	collabPresenceTimeout  = 30 * time.Second // 在线状态过期时间
+)
+
+type collabBlockVersion struct {
+	seq      int64
+	markdown string
+	session  string
+}
+
+type collabDoc struct {
+	seq     int64
+	blocks  map[string][]*collabBlockVersion
+	touched time.Time
+}
+
+// CollabPresence 描述了某个会话正在编辑的块。
+type CollabPresence struct {
+	App     string `json:"app"`
+	Session string `json:"session"`
+	User    string `json:"user"`
+	RootID  string `json:"rootID"`
+	BlockID string `json:"blockID"`
+	Updated int64  `json:"updated"`
+}
+
+var (
+	collabSeqBase   = time.Now().UnixMilli() * 1000 // 每毫秒最多 1000 次提交才会与重启前的序号重叠，并且不超过 JavaScript 的安全整数
+	collabDocs      = map[string]*collabDoc{}
+	collabPresences = map[string]*CollabPresence{} // session -> presence
+	collabLock      = sync.Mutex{}
+)
+
+// GetCollabDocSeq 返回文档当前的协同序号。
+func GetCollabDocSeq(rootID string) int64 {
+	collabLock.Lock()
+	defer collabLock.Unlock()
+
+	if doc := collabDocs[rootID]; nil != doc {
+		return doc.seq
+	}
+	return collabSeqBase
+}
+
+// SetCollabPresence 设置会话正在编辑的块并广播给其他会话，blockID 为空表示离开文档。
+func SetCollabPresence(app, session, user, rootID, blockID string) {
+	collabLock.Lock()
+	presence := &CollabPresence{App: app, Session: session, User: user, RootID: rootID, BlockID: blockID, Updated: time.Now().UnixMilli()}
+	if "" == rootID {
+		delete(collabPresences, session)
+	} else {
+		collabPresences[session] = presence
+	}
+	collabLock.Unlock()
+
+	evt := util.NewCmdResult("collabPresence", 0, util.PushModeBroadcastExcludeSelf)
+	evt.AppId = app
+	evt.SessionId = session
+	evt.Data = presence
+	util.PushEvent(evt)
+}
+
+// GetCollabPresences 返回文档中其他会话的在线状态。
+func GetCollabPresences(rootID string) (ret []*CollabPresence) {
+	collabLock.Lock()
+	defer collabLock.Unlock()
+
+	ret = []*CollabPresence{}
+	now := time.Now()
+	for session, presence := range collabPresences {
+		if now.Sub(time.UnixMilli(presence.Updated)) > collabPresenceTimeout {
+			delete(collabPresences, session)
+			continue
+		}
+		if rootID == presence.RootID {
+			ret = append(ret, presence)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Updated > ret[j].Updated })
+	return
+}
+
+// IsMergedTransactions 判断事务中是否存在被服务端合并过的更新操作，合并后的内容需要推送给发起方。
+func IsMergedTransactions(transactions []*Transaction) bool {
+	for _, tx := range transactions {
+		for _, op := range tx.DoOperations {
+			if op.Merged {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergeConcurrentUpdate 合并并发的叶子块更新，返回合并后的子树，不需要合并或者无法合并时返回 nil（后写入者覆盖）。
+func (tx *Transaction) mergeConcurrentUpdate(operation *Operation, tree *parse.Tree, oldNode *ast.Node, subTree *parse.Tree) (ret *parse.Tree) {
+	newNode := subTree.Root.FirstChild
+	if nil == newNode || oldNode.IsContainerBlock() || newNode.Type != oldNode.Type || newNode.ID != oldNode.ID {
+		return
+	}
+
+	ours := treenode.FormatNode(oldNode, tx.luteEngine)
+	base, ok := collabBaseVersion(tree.ID, oldNode.ID, operation.BaseSeq, tx.Session, ours)
+	if !ok {
+		return
+	}
+
+	theirs := treenode.FormatNode(newNode, tx.luteEngine)
+	merged, ok := mergeText3(base, ours, theirs)
+	if !ok {
+		logging.LogWarnf("merge concurrent update of block [%s] conflicted, the latest update wins", oldNode.ID)
+		return
+	}
+	if merged == theirs {
+		return
+	}
+
+	md := strings.TrimRight(merged, "\n") + "\n" + string(parse.IAL2Tokens(newNode.KramdownIAL))
+	ret = parse.Parse("", []byte(md), tx.luteEngine.ParseOptions)
+	if nil == ret || nil == ret.Root.FirstChild || oldNode.ID != ret.Root.FirstChild.ID {
+		logging.LogWarnf("parse merged block [%s] failed", oldNode.ID)
+		return nil
+	}
+	operation.Data = tx.luteEngine.Tree2BlockDOM(ret, tx.luteEngine.RenderOptions)
+	operation.Merged = true
+
+	// 撤销时应该恢复到合并前服务端的内容，而不是发起方本地的旧内容
+	for _, undo := range tx.UndoOperations {
+		if "update" == undo.Action && oldNode.ID == undo.ID {
+			undo.Data = tx.luteEngine.RenderNodeBlockDOM(oldNode)
+		}
+	}
+	return
+}
+
+// collabBaseVersion 返回块在 baseSeq 时的版本，仅在该块此后被其他会话修改过时返回 true。
+func collabBaseVersion(rootID, id string, baseSeq int64, session, current string) (ret string, ok bool) {
+	collabLock.Lock()
+	defer collabLock.Unlock()
+
+	doc := getCollabDoc(rootID)
+	versions := doc.blocks[id]
+	if 1 > len(versions) || current != versions[len(versions)-1].markdown {
+		// 记录当前已提交的内容作为后续合并的基准
+		versions = append(versions, &collabBlockVersion{seq: doc.seq, markdown: current})
+		doc.blocks[id] = capCollabVersions(versions)
+	}
+
+	if collabSeqBase > baseSeq || baseSeq > doc.seq {
+		// 重启前的序号无法对应到当前缓存的版本
+		return
+	}
+
+	var base *collabBlockVersion
+	concurrent := false
+	for _, v := range versions {
+		if v.seq <= baseSeq {
+			base = v
+		} else if "" == session || session != v.session {
+			concurrent = true
+		}
+	}
+	if nil == base || !concurrent {
+		return
+	}
+	return base.markdown, true
+}
+
+// commitCollab 在事务提交时递增文档序号并记录更新后的块版本。
+func (tx *Transaction) commitCollab() {
+	collabLock.Lock()
+	defer collabLock.Unlock()
+
+	tx.DocSeqs = map[string]int64{}
+	for rootID := range tx.trees {
+		doc := getCollabDoc(rootID)
+		doc.seq++
+		tx.DocSeqs[rootID] = doc.seq
+	}
+
+	for _, op := range tx.DoOperations {
+		if "update" != op.Action {
+			continue
+		}
+		node := tx.nodes[op.ID]
+		if nil == node || node.IsContainerBlock() {
+			continue
+		}
+		root := treenode.TreeRoot(node)
+		if nil == root {
+			continue
+		}
+		seq, ok := tx.DocSeqs[root.ID]
+		if !ok {
+			continue
+		}
+		doc := getCollabDoc(root.ID)
+		versions := append(doc.blocks[op.ID], &collabBlockVersion{seq: seq, markdown: treenode.FormatNode(node, tx.luteEngine), session: tx.Session})
+		doc.blocks[op.ID] = capCollabVersions(versions)
+	}
+
+	now := time.Now()
+	for _, doc := range collabDocs {
+		if now.Sub(doc.touched) > collabDocIdleTimeout && 0 < len(doc.blocks) {
+			// 序号需要保留，仅清理块版本
+			doc.blocks = map[string][]*collabBlockVersion{}
+		}
+	}
+}
+
+func getCollabDoc(rootID string) (ret *collabDoc) {
+	ret = collabDocs[rootID]
+	if nil == ret {
+		ret = &collabDoc{seq: collabSeqBase, blocks: map[string][]*collabBlockVersion{}}
+		collabDocs[rootID] = ret
+	}
+	ret.touched = time.Now()
+	return
+}
+
+func capCollabVersions(versions []*collabBlockVersion) []*collabBlockVersion {
+	if collabBlockVersionsMax < len(versions) {
+		versions = versions[len(versions)-collabBlockVersionsMax:]
+	}
+	return versions
+}
+
+type textHunk struct {
+	start, end int // 基准文本中被替换的区间 [start, end)
+	text       []string
+}
+
+// mergeText3 以 base 为基准三方合并 ours 和 theirs，两边修改的区间重叠时返回 false。
+func mergeText3(base, ours, theirs string) (ret string, ok bool) {
+	if ours == base || ours == theirs {
+		return theirs, true
+	}
+	if theirs == base {
+		return ours, true
+	}
+
+	baseTokens := textTokens(base)
+	hunks := append(diffHunks(baseTokens, textTokens(ours)), diffHunks(baseTokens, textTokens(theirs))...)
+	sort.SliceStable(hunks, func(i, j int) bool {
+		if hunks[i].start != hunks[j].start {
+			return hunks[i].start < hunks[j].start
+		}
+		return hunks[i].end < hunks[j].end
+	})
+
+	var merged []*textHunk
+	for _, h := range hunks {
+		if 0 < len(merged) {
+			last := merged[len(merged)-1]
+			if last.start == h.start && last.end == h.end && strings.Join(last.text, "") == strings.Join(h.text, "") {
+				continue // 两边做了相同的修改
+			}
+			if h.start < last.end || h.start == last.start {
+				return
+			}
+		}
+		merged = append(merged, h)
+	}
+
+	buf := strings.Builder{}
+	pos := 0
+	for _, h := range merged {
+		buf.WriteString(strings.Join(baseTokens[pos:h.start], ""))
+		buf.WriteString(strings.Join(h.text, ""))
+		pos = h.end
+	}
+	buf.WriteString(strings.Join(baseTokens[pos:], ""))
+	return buf.String(), true
+}
+
+// diffHunks 计算从 a 到 b 的修改区间。
+func diffHunks(a, b []string) (ret []*textHunk) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if 0 == len(a) && 0 == len(b) {
+		return
+	}
+
+	n, m := len(a), len(b)
+	if 0 == n || 0 == m || 256*1024 < n*m {
+		// 差异较大时整体作为一个修改区间
+		return []*textHunk{{start: prefix, end: prefix + n, text: b}}
+	}
+
+	// 最长公共子序列
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; 0 <= i; i-- {
+		for j := m - 1; 0 <= j; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var cur *textHunk
+	i, j := 0, 0
+	for i < n || j < m {
+		if i < n && j < m && a[i] == b[j] {
+			if nil != cur {
+				ret = append(ret, cur)
+				cur = nil
+			}
+			i++
+			j++
+			continue
+		}
+		if nil == cur {
+			cur = &textHunk{start: prefix + i, end: prefix + i}
+		}
+		if j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]) {
+			cur.text = append(cur.text, b[j])
+			j++
+		} else {
+			i++
+			cur.end = prefix + i
+		}
+	}
+	if nil != cur {
+		ret = append(ret, cur)
+	}
+	return
+}
+
+// textTokens 将文本切分为单词、CJK 字符和其他单个字符。
+func textTokens(text string) (ret []string) {
+	word := strings.Builder{}
+	for _, r := range text {
+		if (unicode.IsLetter(r) || unicode.IsDigit(r)) && !unicode.Is(unicode.Han, r) {
+			word.WriteRune(r)
+			continue
+		}
+		if 0 < word.Len() {
+			ret = append(ret, word.String())
+			word.Reset()
+		}
+		ret = append(ret, string(r))
+	}
+	if 0 < word.Len() {
+		ret = append(ret, word.String())
+	}
+	return
+}
@@ -0,0 +1,111 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffHunks(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected []textHunk
+	}{
+		{"a b c", "a b c", nil},
+		{"a b c", "a x c", []textHunk{{start: 2, end: 3, text: []string{"x"}}}},
+		{"a b c", "a b c d", []textHunk{{start: 5, end: 5, text: []string{" ", "d"}}}},
+		{"a b c", "b c", []textHunk{{start: 0, end: 2}}},
+		{"a b c d", "x b c y", []textHunk{{start: 0, end: 1, text: []string{"x"}}, {start: 6, end: 7, text: []string{"y"}}}},
+		{"", "new", []textHunk{{start: 0, end: 0, text: []string{"new"}}}},
+	}
+	for _, c := range cases {
+		got := diffHunks(textTokens(c.a), textTokens(c.b))
+		if len(c.expected) != len(got) {
+			t.Fatalf("diff [%s] -> [%s] expected [%d] hunks, got [%d]", c.a, c.b, len(c.expected), len(got))
+		}
+		for i, h := range got {
+			e := c.expected[i]
+			if e.start != h.start || e.end != h.end || strings.Join(e.text, "") != strings.Join(h.text, "") {
+				t.Fatalf("diff [%s] -> [%s] hunk [%d] expected [%+v], got [%+v]", c.a, c.b, i, e, *h)
+			}
+		}
+	}
+}
+
+func TestMergeText3(t *testing.T) {
+	cases := []struct {
+		base, ours, theirs string
+		expected           string
+		ok                 bool
+	}{
+		{"hello world", "hello world", "hello there", "hello there", true},
+		{"hello world", "hello there", "hello world", "hello there", true},
+		{"hello world", "hello there", "hello there", "hello there", true},
+		{"the quick fox", "the slow fox", "the quick brown fox", "the slow brown fox", true},
+		{"one two three", "zero two three", "one two four", "zero two four", true},
+		{"思源笔记很好用", "思源笔记非常好用", "思源笔记很好用！", "思源笔记非常好用！", true},
+		{"one two three", "one 2 three", "one II three", "", false},
+		{"a b", "x b", "y b", "", false},
+	}
+	for _, c := range cases {
+		got, ok := mergeText3(c.base, c.ours, c.theirs)
+		if c.ok != ok || (ok && c.expected != got) {
+			t.Errorf("merge [%s] [%s] [%s] expected [%s, %v], got [%s, %v]", c.base, c.ours, c.theirs, c.expected, c.ok, got, ok)
+		}
+	}
+}
+
+func TestCollabBaseVersion(t *testing.T) {
+	oldDocs := collabDocs
+	defer func() { collabDocs = oldDocs }()
+	collabDocs = map[string]*collabDoc{}
+
+	rootID, id := "20240101000000-aaaaaaa", "20240101000000-bbbbbbb"
+	baseSeq := GetCollabDocSeq(rootID)
+	if collabSeqBase != baseSeq {
+		t.Fatalf("expected seq [%d] for a new doc, got [%d]", collabSeqBase, baseSeq)
+	}
+
+	// 记录初始版本后由另一个会话提交修改
+	if _, ok := collabBaseVersion(rootID, id, baseSeq, "s1", "v1"); ok {
+		t.Fatalf("expected no base version without concurrent update")
+	}
+	doc := collabDocs[rootID]
+	doc.seq++
+	doc.blocks[id] = append(doc.blocks[id], &collabBlockVersion{seq: doc.seq, markdown: "v2", session: "s2"})
+
+	if base, ok := collabBaseVersion(rootID, id, baseSeq, "s1", "v2"); !ok || "v1" != base {
+		t.Fatalf("expected base version [v1], got [%s, %v]", base, ok)
+	}
+	if _, ok := collabBaseVersion(rootID, id, baseSeq, "s2", "v2"); ok {
+		t.Fatalf("expected no base version for own update")
+	}
+	if _, ok := collabBaseVersion(rootID, id, doc.seq, "s1", "v2"); ok {
+		t.Fatalf("expected no base version for the latest seq")
+	}
+
+	// 重启前的序号即使小于当前序号也不能作为基准
+	for _, seq := range []int64{0, 1, collabSeqBase - 1} {
+		if _, ok := collabBaseVersion(rootID, id, seq, "s1", "v2"); ok {
+			t.Fatalf("expected pre-restart seq [%d] to be rejected", seq)
+		}
+	}
+	if _, ok := collabBaseVersion(rootID, id, doc.seq+1, "s1", "v2"); ok {
+		t.Fatalf("expected future seq to be rejected")
+	}
+}
@@ -0,0 +1,581 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 块评论按文档保存在 data/storage/comment/{rootID}.json 中，不写入 .sy，导出时不会包含评论。
+// 块移动到其他文档后评论跟随移动，文档删除时一并删除评论，块被删除时保留评论以便撤销后恢复。
+// 加密笔记本中文档的评论使用笔记本的会话密钥加密，格式和加密的 .sy 相同，笔记本锁定时不能读写评论。
+
+var ErrCommentNotFound = errors.New("comment not found")
+
+// Comment 描述块上的一条评论，ParentID 为空的是主题评论，否则是主题下的回复。
+type Comment struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parentID"`
+	BlockID  string `json:"blockID"`
+	RootID   string `json:"rootID"`
+	Author   string `json:"author"`
+	Content  string `json:"content"`
+	Resolved bool   `json:"resolved"` // 仅主题评论使用
+	Created  int64  `json:"created"`
+	Updated  int64  `json:"updated"`
+}
+
+// CommentThread 描述一个评论主题及其回复。
+type CommentThread struct {
+	*Comment
+	Replies []*Comment `json:"replies"`
+}
+
+var commentLock = sync.Mutex{}
+
+// AddComment 在块上新建主题评论，parentID 不为空时回复该主题。
+func AddComment(blockID, parentID, author, content string) (ret *Comment, err error) {
+	content = strings.TrimSpace(content)
+	if "" == content {
+		err = errors.New("comment content is empty")
+		return
+	}
+
+	commentLock.Lock()
+	defer commentLock.Unlock()
+
+	bt := treenode.GetBlockTree(blockID)
+	if nil == bt {
+		err = ErrBlockNotFound
+		return
+	}
+	if filesys.IsBoxLocked(bt.BoxID) {
+		err = filesys.ErrBoxLocked
+		return
+	}
+
+	comments, err := getDocComments(bt.RootID)
+	if err != nil {
+		return
+	}
+
+	if "" != parentID {
+		parent := getComment(comments, parentID)
+		if nil == parent || "" != parent.ParentID {
+			err = ErrCommentNotFound
+			return
+		}
+		blockID = parent.BlockID
+	}
+
+	now := time.Now().UnixMilli()
+	ret = &Comment{
+		ID:       ast.NewNodeID(),
+		ParentID: parentID,
+		BlockID:  blockID,
+		RootID:   bt.RootID,
+		Author:   author,
+		Content:  content,
+		Created:  now,
+		Updated:  now,
+	}
+	comments = append(comments, ret)
+	if "" != parentID {
+		getComment(comments, parentID).Updated = now
+	}
+	if err = saveDocComments(bt.RootID, comments); err != nil {
+		return
+	}
+	pushComment("add", ret)
+	return
+}
+
+// UpdateComment 修改评论内容。
+func UpdateComment(id, content string) (ret *Comment, err error) {
+	content = strings.TrimSpace(content)
+	if "" == content {
+		err = errors.New("comment content is empty")
+		return
+	}
+
+	commentLock.Lock()
+	defer commentLock.Unlock()
+
+	comments, ret, err := loadCommentByID(id)
+	if err != nil {
+		return
+	}
+
+	ret.Content = content
+	ret.Updated = time.Now().UnixMilli()
+	if err = saveDocComments(ret.RootID, comments); err != nil {
+		return
+	}
+	pushComment("update", ret)
+	return
+}
+
+// ResolveComment 设置主题评论的解决状态。
+func ResolveComment(id string, resolved bool) (ret *Comment, err error) {
+	commentLock.Lock()
+	defer commentLock.Unlock()
+
+	comments, ret, err := loadCommentByID(id)
+	if err != nil {
+		return
+	}
+	if "" != ret.ParentID {
+		err = errors.New("only the thread comment can be resolved")
+		return
+	}
+
+	ret.Resolved = resolved
+	ret.Updated = time.Now().UnixMilli()
+	if err = saveDocComments(ret.RootID, comments); err != nil {
+		return
+	}
+	pushComment("resolve", ret)
+	return
+}
+
+// RemoveComment 删除评论，删除主题评论时一并删除其回复。
+func RemoveComment(id string) (err error) {
+	commentLock.Lock()
+	defer commentLock.Unlock()
+
+	comments, comment, err := loadCommentByID(id)
+	if err != nil {
+		return
+	}
+
+	var remains []*Comment
+	for _, c := range comments {
+		if c.ID == id || c.ParentID == id {
+			continue
+		}
+		remains = append(remains, c)
+	}
+	if err = saveDocComments(comment.RootID, remains); err != nil {
+		return
+	}
+	pushComment("remove", comment)
+	return
+}
+
+// GetBlockComments 返回块上的评论主题。
+func GetBlockComments(blockID string) (ret []*CommentThread, err error) {
+	ret = []*CommentThread{}
+	bt := treenode.GetBlockTree(blockID)
+	if nil == bt {
+		return
+	}
+
+	commentLock.Lock()
+	comments, err := getDocComments(bt.RootID)
+	commentLock.Unlock()
+	if err != nil {
+		return
+	}
+
+	var blockComments []*Comment
+	for _, comment := range comments {
+		if comment.BlockID == blockID {
+			blockComments = append(blockComments, comment)
+		}
+	}
+	ret = buildCommentThreads(blockComments, nil)
+	return
+}
+
+// GetDocComments 返回文档中所有块上的评论主题。
+func GetDocComments(rootID string) (ret []*CommentThread, err error) {
+	ret = []*CommentThread{}
+	commentLock.Lock()
+	comments, err := getDocComments(rootID)
+	commentLock.Unlock()
+	if err != nil {
+		return
+	}
+	ret = buildCommentThreads(comments, nil)
+	return
+}
+
+// GetOpenComments 返回笔记本中未解决的评论主题，box 为空时查询所有笔记本。
+func GetOpenComments(box string, limit int) (ret []*CommentThread) {
+	ret = []*CommentThread{}
+	if 1 > limit {
+		limit = 128
+	}
+
+	heads := sql.GetOpenComments(box, limit)
+	threadIDs := map[string]bool{}
+	var rootIDs []string
+	for _, head := range heads {
+		if nil == treenode.GetBlockTree(head.BlockID) {
+			// 块已经被删除
+			continue
+		}
+		threadIDs[head.ID] = true
+		rootIDs = append(rootIDs, head.RootID)
+	}
+	rootIDs = gulu.Str.RemoveDuplicatedElem(rootIDs)
+
+	var comments []*Comment
+	commentLock.Lock()
+	for _, rootID := range rootIDs {
+		docComments, _ := getDocComments(rootID)
+		comments = append(comments, docComments...)
+	}
+	commentLock.Unlock()
+
+	ret = buildCommentThreads(comments, threadIDs)
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Updated > ret[j].Updated })
+	return
+}
+
+// IndexCommentsJob 重建所有文档的评论索引。
+func IndexCommentsJob() {
+	task.AppendTask(task.CommentDatabaseIndex, indexComments)
+}
+
+func indexComments() {
+	defer logging.Recover()
+
+	dir := filepath.Join(util.DataDir, "storage", "comment")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	commentLock.Lock()
+	defer commentLock.Unlock()
+	for _, entry := range entries {
+		rootID := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || !ast.IsNodeIDPattern(rootID) {
+			continue
+		}
+
+		comments, readErr := getDocComments(rootID)
+		if nil != readErr {
+			continue
+		}
+		indexDocComments(rootID, comments)
+	}
+}
+
+// relocateComments 将块已经移动到其他文档的评论主题移动到块当前所在文档中。
+func relocateComments(rootIDs []string) {
+	commentLock.Lock()
+	defer commentLock.Unlock()
+
+	for _, rootID := range gulu.Str.RemoveDuplicatedElem(rootIDs) {
+		comments, err := getDocComments(rootID)
+		if err != nil || 1 > len(comments) {
+			continue
+		}
+
+		threadRootIDs := map[string]string{} // 主题评论 ID -> 块当前所在文档 ID
+		for _, comment := range comments {
+			if "" != comment.ParentID {
+				continue
+			}
+			if bt := treenode.GetBlockTree(comment.BlockID); nil != bt && bt.RootID != rootID {
+				threadRootIDs[comment.ID] = bt.RootID
+			}
+		}
+		if 1 > len(threadRootIDs) {
+			continue
+		}
+
+		moved := map[string][]*Comment{}
+		var remains []*Comment
+		for _, comment := range comments {
+			threadID := comment.ParentID
+			if "" == threadID {
+				threadID = comment.ID
+			}
+			if newRootID := threadRootIDs[threadID]; "" != newRootID {
+				comment.RootID = newRootID
+				moved[newRootID] = append(moved[newRootID], comment)
+				continue
+			}
+			remains = append(remains, comment)
+		}
+
+		// 先写入目标文档，失败时保留在原文档中
+		for newRootID, movedComments := range moved {
+			targetComments, getErr := getDocComments(newRootID)
+			if nil == getErr {
+				getErr = saveDocComments(newRootID, append(targetComments, movedComments...))
+			}
+			if nil != getErr {
+				for _, comment := range movedComments {
+					comment.RootID = rootID
+				}
+				remains = append(remains, movedComments...)
+			}
+		}
+		saveDocComments(rootID, remains)
+	}
+}
+
+// rewriteBoxComments 加密或者解密笔记本中文档的评论，文档 ID 取自笔记本下的 .sy 文件名，笔记本锁定时也可以使用。
+func rewriteBoxComments(box *Box, transform func(data []byte) ([]byte, error)) (err error) {
+	commentLock.Lock()
+	defer commentLock.Unlock()
+
+	for _, rootID := range boxDocIDs(box) {
+		p := filepath.Join(util.DataDir, "storage", "comment", rootID+".json")
+		if !filelock.IsExist(p) {
+			continue
+		}
+
+		data, readErr := filelock.ReadFile(p)
+		if nil != readErr {
+			logging.LogErrorf("read storage [comment] failed: %s", readErr)
+			return readErr
+		}
+		if data, err = transform(data); err != nil {
+			logging.LogErrorf("transform storage [comment] [%s] failed: %s", rootID, err)
+			return
+		}
+		if err = filelock.WriteFile(p, data); err != nil {
+			logging.LogErrorf("write storage [comment] failed: %s", err)
+			return
+		}
+	}
+	return
+}
+
+// unindexBoxComments 移除笔记本中文档的评论索引，锁定加密笔记本时调用。
+func unindexBoxComments(box *Box) {
+	for _, rootID := range boxDocIDs(box) {
+		sql.IndexCommentsQueue(rootID, nil)
+	}
+}
+
+// indexBoxComments 重建笔记本中文档的评论索引，解锁加密笔记本时调用。
+func indexBoxComments(boxID string) {
+	box := Conf.GetBox(boxID)
+	if nil == box {
+		return
+	}
+
+	commentLock.Lock()
+	defer commentLock.Unlock()
+	for _, rootID := range boxDocIDs(box) {
+		if comments, err := getDocComments(rootID); nil == err && 0 < len(comments) {
+			indexDocComments(rootID, comments)
+		}
+	}
+}
+
+func boxDocIDs(box *Box) (ret []string) {
+	for _, file := range box.ListFiles("/") {
+		if file.isdir || !strings.HasSuffix(file.name, ".sy") {
+			continue
+		}
+		ret = append(ret, strings.TrimSuffix(file.name, ".sy"))
+	}
+	return
+}
+
+// removeDocsComments 删除文档的所有评论。
+func removeDocsComments(rootIDs []string) {
+	commentLock.Lock()
+	defer commentLock.Unlock()
+
+	for _, rootID := range rootIDs {
+		if !filelock.IsExist(filepath.Join(util.DataDir, "storage", "comment", rootID+".json")) {
+			continue
+		}
+		saveDocComments(rootID, nil)
+	}
+}
+
+// reindexDocsComments 重建文档的评论索引，文档移动到其他笔记本后需要更新索引中的笔记本。
+func reindexDocsComments(rootIDs []string) {
+	commentLock.Lock()
+	defer commentLock.Unlock()
+
+	for _, rootID := range rootIDs {
+		if comments, err := getDocComments(rootID); nil == err && 0 < len(comments) {
+			indexDocComments(rootID, comments)
+		}
+	}
+}
+
+func buildCommentThreads(comments []*Comment, threadIDs map[string]bool) (ret []*CommentThread) {
+	ret = []*CommentThread{}
+	threads := map[string]*CommentThread{}
+	for _, comment := range comments {
+		if "" != comment.ParentID {
+			continue
+		}
+		if nil != threadIDs && !threadIDs[comment.ID] {
+			continue
+		}
+		thread := &CommentThread{Comment: comment, Replies: []*Comment{}}
+		threads[comment.ID] = thread
+		ret = append(ret, thread)
+	}
+	for _, comment := range comments {
+		if thread := threads[comment.ParentID]; nil != thread {
+			thread.Replies = append(thread.Replies, comment)
+		}
+	}
+	for _, thread := range ret {
+		sort.Slice(thread.Replies, func(i, j int) bool { return thread.Replies[i].Created < thread.Replies[j].Created })
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created < ret[j].Created })
+	return
+}
+
+func loadCommentByID(id string) (comments []*Comment, ret *Comment, err error) {
+	indexed := sql.GetComment(id)
+	if nil == indexed {
+		sql.FlushQueue()
+		indexed = sql.GetComment(id)
+	}
+	if nil == indexed {
+		err = ErrCommentNotFound
+		return
+	}
+
+	comments, err = getDocComments(indexed.RootID)
+	if err != nil {
+		return
+	}
+	if ret = getComment(comments, id); nil == ret {
+		err = ErrCommentNotFound
+	}
+	return
+}
+
+func getComment(comments []*Comment, id string) *Comment {
+	for _, comment := range comments {
+		if comment.ID == id {
+			return comment
+		}
+	}
+	return nil
+}
+
+func getDocComments(rootID string) (ret []*Comment, err error) {
+	p := filepath.Join(util.DataDir, "storage", "comment", rootID+".json")
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if err != nil {
+		logging.LogErrorf("read storage [comment] failed: %s", err)
+		return
+	}
+	if data, err = filesys.DecryptTreeData(data); err != nil { // 笔记本锁定时返回 filesys.ErrBoxLocked
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal storage [comment] failed: %s", err)
+		return
+	}
+	return
+}
+
+func saveDocComments(rootID string, comments []*Comment) (err error) {
+	dirPath := filepath.Join(util.DataDir, "storage", "comment")
+	p := filepath.Join(dirPath, rootID+".json")
+	if 1 > len(comments) {
+		if err = filelock.Remove(p); err != nil {
+			logging.LogErrorf("remove storage [comment] failed: %s", err)
+			return
+		}
+		indexDocComments(rootID, nil)
+		return
+	}
+
+	if err = os.MkdirAll(dirPath, 0755); err != nil {
+		logging.LogErrorf("create storage [comment] dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(comments, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal storage [comment] failed: %s", err)
+		return
+	}
+	if bt := treenode.GetBlockTree(rootID); nil != bt {
+		if data, err = filesys.EncryptTreeData(bt.BoxID, data); err != nil { // 笔记本锁定时返回 filesys.ErrBoxLocked
+			return
+		}
+	}
+	if err = filelock.WriteFile(p, data); err != nil {
+		logging.LogErrorf("write storage [comment] failed: %s", err)
+		return
+	}
+	indexDocComments(rootID, comments)
+	return
+}
+
+func indexDocComments(rootID string, comments []*Comment) {
+	var box string
+	if bt := treenode.GetBlockTree(rootID); nil != bt {
+		box = bt.BoxID
+	}
+
+	var indexes []*sql.Comment
+	for _, comment := range comments {
+		indexes = append(indexes, &sql.Comment{
+			ID:       comment.ID,
+			ParentID: comment.ParentID,
+			BlockID:  comment.BlockID,
+			RootID:   rootID,
+			Box:      box,
+			Author:   comment.Author,
+			Content:  comment.Content,
+			Resolved: comment.Resolved,
+			Created:  comment.Created,
+			Updated:  comment.Updated,
+		})
+	}
+	sql.IndexCommentsQueue(rootID, indexes)
+}
+
+func pushComment(action string, comment *Comment) {
+	evt := util.NewCmdResult("comment", 0, util.PushModeBroadcast)
+	evt.Data = map[string]interface{}{
+		"action":  action,
+		"comment": comment,
+	}
+	util.PushEvent(evt)
+}
@@ -71,6 +71,7 @@ type AppConf struct {
 	Sync           *conf.Sync       `json:"sync"`           // 同步配置
 	Search         *conf.Search     `json:"search"`         // 搜索配置
 	Flashcard      *conf.Flashcard  `json:"flashcard"`      // 闪卡配置
+	Image          *conf.Image      `json:"image"`          // 图片配置
 	AI             *conf.AI         `json:"ai"`             // 人工智能配置
 	Bazaar         *conf.Bazaar     `json:"bazaar"`         // 集市配置
 	Stat           *conf.Stat       `json:"stat"`           // 统计
@@ -102,6 +103,59 @@ func (conf *AppConf) SetUILayout(uiLayout *conf.UILayout) {
 	conf.UILayout = uiLayout
 }
 
+// SetAI 整体替换人工智能配置。
+func (conf *AppConf) SetAI(ai *conf.AI) {
+	conf.m.Lock()
+	defer conf.m.Unlock()
+	conf.AI = ai
+}
+
+// GetAIProfiles 返回服务配置列表。返回的列表和其中的服务配置不会再被修改，调用方只读即可。
+func (conf *AppConf) GetAIProfiles() []*conf.AIProfile {
+	conf.m.Lock()
+	defer conf.m.Unlock()
+	return conf.AI.Profiles
+}
+
+// UpdateAIProfiles 复制服务配置列表和动作映射，修改副本后整体替换，update 返回错误时不做任何修改。
+//
+// 更新服务配置时需要替换列表中的元素，不能原地修改已有的服务配置。
+func (conf *AppConf) UpdateAIProfiles(update func(profiles []*conf.AIProfile, actions map[string]string) ([]*conf.AIProfile, error)) error {
+	conf.m.Lock()
+	defer conf.m.Unlock()
+	profiles := append(conf.AI.Profiles[:0:0], conf.AI.Profiles...)
+	actions := map[string]string{}
+	for action, profileID := range conf.AI.Actions {
+		actions[action] = profileID
+	}
+	profiles, err := update(profiles, actions)
+	if err != nil {
+		return err
+	}
+	conf.AI.Profiles = profiles
+	conf.AI.Actions = actions
+	return nil
+}
+
+// GetAIActions 返回动作映射。返回的映射不会再被修改，调用方只读即可。
+func (conf *AppConf) GetAIActions() map[string]string {
+	conf.m.Lock()
+	defer conf.m.Unlock()
+	return conf.AI.Actions
+}
+
+// UpdateAIActions 复制动作映射，修改副本后整体替换。
+func (conf *AppConf) UpdateAIActions(update func(actions map[string]string)) {
+	conf.m.Lock()
+	defer conf.m.Unlock()
+	actions := map[string]string{}
+	for action, profileID := range conf.AI.Actions {
+		actions[action] = profileID
+	}
+	update(actions)
+	conf.AI.Actions = actions
+}
+
 func (conf *AppConf) GetUser() *conf.User {
 	conf.m.Lock()
 	defer conf.m.Unlock()
@@ -378,6 +432,17 @@ func InitConf() {
 	Conf.Sync.Local.Endpoint = util.NormalizeLocalPath(Conf.Sync.Local.Endpoint)
 	Conf.Sync.Local.Timeout = util.NormalizeTimeout(Conf.Sync.Local.Timeout)
 	Conf.Sync.Local.ConcurrentReqs = util.NormalizeConcurrentReqs(Conf.Sync.Local.ConcurrentReqs, conf.ProviderLocal)
+	if nil == Conf.Sync.SFTP {
+		Conf.Sync.SFTP = &conf.SFTP{Port: 22}
+	}
+	Conf.Sync.SFTP.Timeout = util.NormalizeTimeout(Conf.Sync.SFTP.Timeout)
+	Conf.Sync.SFTP.ConcurrentReqs = util.NormalizeConcurrentReqs(Conf.Sync.SFTP.ConcurrentReqs, conf.ProviderSFTP)
+	if nil == Conf.Sync.LAN {
+		Conf.Sync.LAN = &conf.LAN{Discovery: true, Peers: []string{}, Interval: 300}
+	}
+	if 30 > Conf.Sync.LAN.Interval {
+		Conf.Sync.LAN.Interval = 30
+	}
 
 	if util.ContainerDocker == util.Container {
 		Conf.Sync.Perception = false
@@ -416,6 +481,9 @@ func InitConf() {
 	if 1 > Conf.Repo.RetentionIndexesDaily {
 		Conf.Repo.RetentionIndexesDaily = 2
 	}
+	if nil == Conf.Repo.BackupTargets {
+		Conf.Repo.BackupTargets = []*conf.BackupTarget{}
+	}
 	if 0 < len(Conf.Repo.Key) {
 		logging.LogInfof("repo key [%x]", sha1.Sum(Conf.Repo.Key))
 	}
@@ -456,6 +524,19 @@ func InitConf() {
 		Conf.Flashcard.Weights = conf.NewFlashcard().Weights
 	}
 
+	if nil == Conf.Image {
+		Conf.Image = conf.NewImage()
+	}
+	if 1 > Conf.Image.Quality || 100 < Conf.Image.Quality {
+		Conf.Image.Quality = conf.NewImage().Quality
+	}
+	if 0 > Conf.Image.MaxWidth {
+		Conf.Image.MaxWidth = 0
+	}
+	if 0 > Conf.Image.MaxHeight {
+		Conf.Image.MaxHeight = 0
+	}
+
 	if nil == Conf.AI {
 		Conf.AI = conf.NewAI()
 	}
@@ -480,6 +561,18 @@ func InitConf() {
 	if 1 > Conf.AI.OpenAI.APIMaxContexts || 64 < Conf.AI.OpenAI.APIMaxContexts {
 		Conf.AI.OpenAI.APIMaxContexts = 7
 	}
+	if 1 > Conf.AI.OpenAI.APIContextSize {
+		Conf.AI.OpenAI.APIContextSize = 4096
+	}
+	if nil == Conf.AI.Profiles {
+		Conf.AI.Profiles = []*conf.AIProfile{}
+	}
+	for _, profile := range Conf.AI.Profiles {
+		NormalizeAIProfile(profile)
+	}
+	if nil == Conf.AI.Actions {
+		Conf.AI.Actions = map[string]string{}
+	}
 
 	if "" != Conf.AI.OpenAI.APIKey {
 		logging.LogInfof("OpenAI API enabled\n"+
@@ -625,6 +718,7 @@ func Close(force, setCurrentWorkspace bool, execInstallPkg int) (exitCode int) {
 	logging.LogInfof("exiting kernel [force=%v, setCurrentWorkspace=%v, execInstallPkg=%d]", force, setCurrentWorkspace, execInstallPkg)
 	util.PushMsg(Conf.Language(95), 10000*60)
 	FlushTxQueue()
+	flushWritingStat()
 
 	if !force {
 		if Conf.Sync.Enabled && 3 != Conf.Sync.Mode &&
@@ -868,6 +962,8 @@ func (conf *AppConf) language(num int) (ret string) {
 }
 
 func InitBoxes() {
+	purgeEncryptedBoxIndexes()
+
 	blockCount := treenode.CountBlocks()
 	initialized := 0 < blockCount
 	for _, box := range Conf.GetOpenedBoxes() {
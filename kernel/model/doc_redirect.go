@@ -0,0 +1,237 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// DocRedirect 描述文档重命名或移动后旧的可读路径到文档 ID 的重定向。
+type DocRedirect struct {
+	Box     string `json:"box"`
+	HPath   string `json:"hPath"`
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+}
+
+const docRedirectMax = 4096 // 最多保留的重定向条数，超出后淘汰最早的记录
+
+var docRedirectLock = sync.Mutex{}
+
+// GetDocRedirects 列出重定向，boxID 为空时列出所有笔记本的重定向。
+func GetDocRedirects(boxID string) (ret []*DocRedirect) {
+	docRedirectLock.Lock()
+	defer docRedirectLock.Unlock()
+
+	ret = []*DocRedirect{}
+	redirects, err := getDocRedirects()
+	if err != nil {
+		return
+	}
+
+	for _, redirect := range redirects {
+		if "" != boxID && boxID != redirect.Box {
+			continue
+		}
+		ret = append(ret, redirect)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created > ret[j].Created })
+	return
+}
+
+// PruneDocRedirects 清理重定向。指定 hPaths 时删除对应的重定向，否则删除目标文档已经不存在或者旧路径上已经存在文档的重定向。
+func PruneDocRedirects(boxID string, hPaths []string) (count int, err error) {
+	docRedirectLock.Lock()
+	defer docRedirectLock.Unlock()
+
+	redirects, err := getDocRedirects()
+	if err != nil {
+		return
+	}
+
+	var tmp []*DocRedirect
+	for _, redirect := range redirects {
+		if "" != boxID && boxID != redirect.Box {
+			tmp = append(tmp, redirect)
+			continue
+		}
+
+		var remove bool
+		if 0 < len(hPaths) {
+			remove = gulu.Str.Contains(redirect.HPath, hPaths)
+		} else {
+			remove = !treenode.ExistBlockTree(redirect.ID) || 0 < len(treenode.GetBlockTreeRootsByHPath(redirect.Box, redirect.HPath))
+		}
+		if remove {
+			count++
+			continue
+		}
+		tmp = append(tmp, redirect)
+	}
+	if 0 < count {
+		err = setDocRedirects(tmp)
+	}
+	return
+}
+
+// ResolveDocRedirect 通过重定向解析旧的可读路径，目标文档不存在时返回空。
+func ResolveDocRedirect(boxID, hPath string) (id string) {
+	// 仅在打开的笔记本中解析，关闭和锁定的笔记本中的文档不能通过重定向访问
+	openedBoxes := map[string]bool{}
+	for _, box := range Conf.GetOpenedBoxes() {
+		openedBoxes[box.ID] = true
+	}
+
+	docRedirectLock.Lock()
+	defer docRedirectLock.Unlock()
+
+	redirects, err := getDocRedirects()
+	if err != nil {
+		return
+	}
+
+	hPath = gulu.Str.RemoveInvisible(hPath)
+	for _, redirect := range redirects {
+		if ("" == boxID || boxID == redirect.Box) && openedBoxes[redirect.Box] && hPath == redirect.HPath {
+			if bt := treenode.GetBlockTree(redirect.ID); nil != bt && openedBoxes[bt.BoxID] {
+				return redirect.ID
+			}
+		}
+	}
+	return
+}
+
+// GetDocIDByHPath 在所有打开的笔记本中通过可读路径查找文档，找不到时通过重定向解析。
+func GetDocIDByHPath(hPath string) (id string) {
+	hPath = path.Join("/", hPath)
+	for _, box := range Conf.GetOpenedBoxes() {
+		if root := treenode.GetBlockTreeRootByHPath(box.ID, hPath); nil != root {
+			return root.ID
+		}
+	}
+	return ResolveDocRedirect("", hPath)
+}
+
+// collectDocHPaths 获取文档及其子文档当前的可读路径，用于重命名或移动后记录重定向。
+func collectDocHPaths(boxID, p string) (ret map[string]string) {
+	ret = map[string]string{}
+	for _, bt := range treenode.GetBlockTreesByPathPrefix(strings.TrimSuffix(p, ".sy")) {
+		if "d" != bt.Type || boxID != bt.BoxID {
+			continue
+		}
+		ret[bt.ID] = bt.HPath
+	}
+	return
+}
+
+// recordDocRedirects 比较文档重命名或移动前后的可读路径，为发生变化的文档记录重定向。
+func recordDocRedirects(boxID string, oldHPaths map[string]string) {
+	var changed []*DocRedirect
+	now := time.Now().UnixMilli()
+	for id, oldHPath := range oldHPaths {
+		bt := treenode.GetBlockTree(id)
+		if nil == bt || (boxID == bt.BoxID && oldHPath == bt.HPath) {
+			continue
+		}
+		changed = append(changed, &DocRedirect{Box: boxID, HPath: oldHPath, ID: id, Created: now})
+	}
+	if 1 > len(changed) {
+		return
+	}
+
+	docRedirectLock.Lock()
+	defer docRedirectLock.Unlock()
+
+	redirects, err := getDocRedirects()
+	if err != nil {
+		return
+	}
+
+	var tmp []*DocRedirect
+	for _, redirect := range redirects {
+		replaced := false
+		for _, c := range changed {
+			if c.Box == redirect.Box && c.HPath == redirect.HPath {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			tmp = append(tmp, redirect)
+		}
+	}
+	tmp = append(tmp, changed...)
+	if docRedirectMax < len(tmp) {
+		sort.SliceStable(tmp, func(i, j int) bool { return tmp[i].Created < tmp[j].Created })
+		tmp = tmp[len(tmp)-docRedirectMax:]
+	}
+	setDocRedirects(tmp)
+}
+
+func setDocRedirects(redirects []*DocRedirect) (err error) {
+	dirPath := filepath.Join(util.DataDir, "storage")
+	if err = os.MkdirAll(dirPath, 0755); err != nil {
+		logging.LogErrorf("create storage [doc-redirect] dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(redirects, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal storage [doc-redirect] failed: %s", err)
+		return
+	}
+
+	lsPath := filepath.Join(dirPath, "doc-redirect.json")
+	err = filelock.WriteFile(lsPath, data)
+	if err != nil {
+		logging.LogErrorf("write storage [doc-redirect] failed: %s", err)
+		return
+	}
+	return
+}
+
+func getDocRedirects() (ret []*DocRedirect, err error) {
+	ret = []*DocRedirect{}
+	dataPath := filepath.Join(util.DataDir, "storage/doc-redirect.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read storage [doc-redirect] failed: %s", err)
+		return
+	}
+
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal storage [doc-redirect] failed: %s", err)
+		return
+	}
+	return
+}
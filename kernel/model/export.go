@@ -1707,6 +1707,10 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 			logging.LogErrorf("read file [%s] failed: %s", readPath, readErr)
 			continue
 		}
+		if data, readErr = filesys.DecryptTreeData(data); nil != readErr { // 导出的 .sy 不加密，否则导入时无法解析
+			logging.LogErrorf("decrypt file [%s] failed: %s", readPath, readErr)
+			continue
+		}
 
 		writePath := strings.TrimPrefix(tree.Path, rootDirPath)
 		writePath = filepath.Join(exportFolder, writePath)
@@ -1733,6 +1737,10 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 			logging.LogErrorf("read file [%s] failed: %s", readPath, readErr)
 			continue
 		}
+		if data, readErr = filesys.DecryptTreeData(data); nil != readErr { // 导出的 .sy 不加密，否则导入时无法解析
+			logging.LogErrorf("decrypt file [%s] failed: %s", readPath, readErr)
+			continue
+		}
 
 		writePath := strings.TrimPrefix(tree.Path, rootDirPath)
 		writePath = filepath.Join(exportFolder, treeID+".sy")
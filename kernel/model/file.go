@@ -465,6 +465,11 @@ func GetDoc(startID, endID, id string, index int, query string, queryTypes map[s
 		return
 	}
 
+	if 0 == mode && !isBacklink {
+		// 打开文档时预取文档引用的资源文件
+		go PrefetchDocAssets(tree.ID)
+	}
+
 	luteEngine := NewLute()
 	node := treenode.GetNodeInTree(tree, id)
 	if nil == node {
@@ -1258,6 +1263,10 @@ func GetIDsByHPath(hpath, boxID string) (ret []string, err error) {
 	ret = []string{}
 	roots := treenode.GetBlockTreeRootsByHPath(boxID, hpath)
 	if 1 > len(roots) {
+		// 文档重命名或移动后通过旧路径重定向
+		if id := ResolveDocRedirect(boxID, hpath); "" != id {
+			ret = append(ret, id)
+		}
 		return
 	}
 
@@ -1295,6 +1304,18 @@ func MoveDocs(fromPaths []string, toBoxID, toPath string, callback interface{})
 		}
 	}
 
+	// 只拉取的笔记本在本地只读，不能移入或者移出文档
+	if filesys.IsBoxReadonly(toBoxID) {
+		err = errors.New(Conf.Language(34))
+		return
+	}
+	for _, fromBox := range pathsBoxes {
+		if filesys.IsBoxReadonly(fromBox.ID) {
+			err = errors.New(Conf.Language(34))
+			return
+		}
+	}
+
 	// 检查路径深度是否超过限制
 	for fromPath, fromBox := range pathsBoxes {
 		childDepth := util.GetChildDocDepth(filepath.Join(util.DataDir, fromBox.ID, fromPath))
@@ -1323,10 +1344,12 @@ func MoveDocs(fromPaths []string, toBoxID, toPath string, callback interface{})
 			util.PushEndlessProgress(fmt.Sprintf(Conf.Language(70), fmt.Sprintf("%d/%d", count, len(fromPaths))))
 		}
 
+		oldHPaths := collectDocHPaths(fromBox.ID, fromPath)
 		_, err = moveDoc(fromBox, fromPath, toBox, toPath, luteEngine, callback)
 		if err != nil {
 			return
 		}
+		recordDocRedirects(fromBox.ID, oldHPaths)
 	}
 	cache.ClearDocsIAL()
 	IncSync()
@@ -1459,11 +1482,13 @@ func moveDoc(fromBox *Box, fromPath string, toBox *Box, toPath string, luteEngin
 		moveSorts(tree.ID, fromBox.ID, toBox.ID)
 	}
 
+	movedIDs := []string{tree.ID}
 	if needMoveSubDocs {
 		// 将其所有子文档的移动事件推送到前端 https://github.com/siyuan-note/siyuan/issues/11661
 		subDocsFolder := path.Join(toFolder, tree.ID)
 		syFiles := listSyFiles(path.Join(toBox.ID, subDocsFolder))
 		for _, syFile := range syFiles {
+			movedIDs = append(movedIDs, strings.TrimSuffix(path.Base(syFile), ".sy"))
 			relPath := strings.TrimPrefix(syFile, "/"+path.Join(toBox.ID, toFolder))
 			subFromPath := path.Join(path.Dir(fromPath), relPath)
 			subToPath := path.Join(toFolder, relPath)
@@ -1492,6 +1517,10 @@ func moveDoc(fromBox *Box, fromPath string, toBox *Box, toPath string, luteEngin
 	evt.Callback = callback
 	util.PushEvent(evt)
 
+	appendDocChange("moveDoc", []string{fromBox.ID, toBox.ID}, movedIDs)
+	if !isSameBox {
+		reindexDocsComments(movedIDs)
+	}
 	refreshDocInfo(fromParentTree)
 	return
 }
@@ -1524,6 +1553,12 @@ func RemoveDocs(paths []string) {
 }
 
 func removeDoc(box *Box, p string, luteEngine *lute.Lute) {
+	if filesys.IsBoxReadonly(box.ID) {
+		logging.LogWarnf("remove doc [%s] in read-only box [%s] is not allowed", p, box.ID)
+		util.PushMsg(Conf.Language(34), 5000)
+		return
+	}
+
 	tree, _ := filesys.LoadTree(box.ID, p, luteEngine)
 	if nil == tree {
 		return
@@ -1608,6 +1643,8 @@ func removeDoc(box *Box, p string, luteEngine *lute.Lute) {
 	}
 	util.PushEvent(evt)
 
+	appendDocChange("removeDoc", []string{box.ID}, allRemoveRootIDs)
+	removeDocsComments(allRemoveRootIDs)
 	refreshParentDocInfo(tree)
 	task.AppendTask(task.DatabaseIndex, removeDoc0, tree, childrenDir)
 }
@@ -1655,6 +1692,7 @@ func RenameDoc(boxID, p, title string) (err error) {
 	}
 	title = strings.ReplaceAll(title, "/", "")
 
+	oldHPaths := collectDocHPaths(box.ID, p)
 	tree.HPath = path.Join(path.Dir(tree.HPath), title)
 	tree.Root.SetIALAttr("title", title)
 	tree.Root.SetIALAttr("updated", util.CurrentTimeSecondsStr())
@@ -1674,6 +1712,8 @@ func RenameDoc(boxID, p, title string) (err error) {
 	util.PushEvent(evt)
 
 	box.renameSubTrees(tree)
+	appendDocChange("renameDoc", []string{box.ID}, []string{tree.ID})
+	recordDocRedirects(box.ID, oldHPaths) // 记录旧路径到文档的重定向，外部通过路径的链接仍然可用
 	updateRefTextRenameDoc(tree)
 	IncSync()
 	return
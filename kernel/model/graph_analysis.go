@@ -0,0 +1,355 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"math"
+	"sort"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	GraphCentralityPageRank = "pagerank"
+	GraphCentralityDegree   = "degree"
+)
+
+// GraphAnalysis 关系图分析结果。
+type GraphAnalysis struct {
+	NodeCount   int               `json:"nodeCount"`
+	RefCount    int               `json:"refCount"`
+	Centrality  []*GraphNodeRank  `json:"centrality"`  // 按中心度降序排列的节点
+	Communities []*GraphCommunity `json:"communities"` // 社区，仅包含两个及以上节点的社区
+	Orphans     []*GraphNode      `json:"orphans"`     // 没有任何引用和被引用的文档
+	DeadEnds    []*GraphNode      `json:"deadEnds"`    // 被引用但没有引用其他文档的文档
+	Path        []*GraphNode      `json:"path"`        // 两个块之间的最短引用路径
+}
+
+type GraphNodeRank struct {
+	*GraphNode
+	InDegree  int     `json:"inDegree"`
+	OutDegree int     `json:"outDegree"`
+	Score     float64 `json:"score"`
+}
+
+type GraphCommunity struct {
+	ID    int      `json:"id"`
+	Nodes []string `json:"nodes"`
+}
+
+type graphAdjacency struct {
+	nodes    map[string]*GraphNode
+	ids      []string            // 按 ID 排序的节点，保证计算结果稳定
+	out      map[string][]string // 引用边 from -> to
+	in       map[string][]string // 引用边 to -> from
+	undirect map[string][]string // 无向边，包括引用边和层级边
+}
+
+// AnalyzeGraph 分析关系图。id 不为空时分析局部关系图，否则分析全局关系图，过滤条件和关系图设置一致。
+func AnalyzeGraph(id, query, centrality string, limit int, fromID, toID string) (ret *GraphAnalysis) {
+	var nodes []*GraphNode
+	var links []*GraphLink
+	if "" != id {
+		_, nodes, links = BuildTreeGraph(id, query)
+	} else {
+		_, nodes, links = BuildGraph(query)
+	}
+
+	ret = &GraphAnalysis{
+		Centrality:  []*GraphNodeRank{},
+		Communities: []*GraphCommunity{},
+		Orphans:     []*GraphNode{},
+		DeadEnds:    []*GraphNode{},
+		Path:        []*GraphNode{},
+	}
+	if 1 > limit {
+		limit = 32
+	}
+
+	adj := newGraphAdjacency(nodes, links)
+	ret.NodeCount = len(adj.ids)
+	for _, tos := range adj.out {
+		ret.RefCount += len(tos)
+	}
+
+	var scores map[string]float64
+	switch centrality {
+	case GraphCentralityDegree:
+		scores = adj.degreeCentrality()
+	default:
+		scores = adj.pageRank(0.85, 64, 1e-6)
+	}
+	for _, nodeID := range adj.ids {
+		if 0 == len(adj.in[nodeID]) && 0 == len(adj.out[nodeID]) {
+			continue
+		}
+
+		ret.Centrality = append(ret.Centrality, &GraphNodeRank{
+			GraphNode: adj.nodes[nodeID],
+			InDegree:  len(adj.in[nodeID]),
+			OutDegree: len(adj.out[nodeID]),
+			Score:     scores[nodeID],
+		})
+	}
+	sort.SliceStable(ret.Centrality, func(i, j int) bool { return ret.Centrality[i].Score > ret.Centrality[j].Score })
+	if limit < len(ret.Centrality) {
+		ret.Centrality = ret.Centrality[:limit]
+	}
+
+	ret.Communities = adj.labelPropagation(32)
+	ret.Orphans, ret.DeadEnds = adj.orphansAndDeadEnds()
+	if "" != fromID && "" != toID {
+		for _, nodeID := range adj.shortestPath(fromID, toID) {
+			ret.Path = append(ret.Path, adj.nodes[nodeID])
+		}
+	}
+	return
+}
+
+func newGraphAdjacency(nodes []*GraphNode, links []*GraphLink) (ret *graphAdjacency) {
+	ret = &graphAdjacency{
+		nodes:    map[string]*GraphNode{},
+		out:      map[string][]string{},
+		in:       map[string][]string{},
+		undirect: map[string][]string{},
+	}
+	for _, node := range nodes {
+		if _, ok := ret.nodes[node.ID]; ok {
+			continue
+		}
+		ret.nodes[node.ID] = node
+		ret.ids = append(ret.ids, node.ID)
+	}
+	sort.Strings(ret.ids)
+
+	seen := map[string]bool{}
+	for _, link := range links {
+		if link.From == link.To || nil == ret.nodes[link.From] || nil == ret.nodes[link.To] {
+			continue
+		}
+
+		key := link.From + "->" + link.To
+		if link.Ref {
+			if !seen["r"+key] {
+				seen["r"+key] = true
+				ret.out[link.From] = append(ret.out[link.From], link.To)
+				ret.in[link.To] = append(ret.in[link.To], link.From)
+			}
+		}
+
+		if !seen["u"+key] {
+			seen["u"+key] = true
+			seen["u"+link.To+"->"+link.From] = true
+			ret.undirect[link.From] = append(ret.undirect[link.From], link.To)
+			ret.undirect[link.To] = append(ret.undirect[link.To], link.From)
+		}
+	}
+	for _, m := range []map[string][]string{ret.out, ret.in, ret.undirect} {
+		for _, neighbors := range m {
+			sort.Strings(neighbors)
+		}
+	}
+	return
+}
+
+func (adj *graphAdjacency) degreeCentrality() (ret map[string]float64) {
+	ret = map[string]float64{}
+	n := len(adj.ids)
+	if 2 > n {
+		return
+	}
+
+	for _, nodeID := range adj.ids {
+		ret[nodeID] = float64(len(adj.in[nodeID])+len(adj.out[nodeID])) / float64(n-1)
+	}
+	return
+}
+
+func (adj *graphAdjacency) pageRank(damping float64, maxIterations int, tolerance float64) (ret map[string]float64) {
+	ret = map[string]float64{}
+	n := float64(len(adj.ids))
+	if 1 > n {
+		return
+	}
+
+	for _, nodeID := range adj.ids {
+		ret[nodeID] = 1 / n
+	}
+
+	for i := 0; i < maxIterations; i++ {
+		var danglingSum float64
+		for _, nodeID := range adj.ids {
+			if 0 == len(adj.out[nodeID]) {
+				danglingSum += ret[nodeID]
+			}
+		}
+
+		next := map[string]float64{}
+		base := (1-damping)/n + damping*danglingSum/n
+		for _, nodeID := range adj.ids {
+			next[nodeID] = base
+		}
+		for _, nodeID := range adj.ids {
+			outs := adj.out[nodeID]
+			if 0 == len(outs) {
+				continue
+			}
+
+			share := damping * ret[nodeID] / float64(len(outs))
+			for _, to := range outs {
+				next[to] += share
+			}
+		}
+
+		var delta float64
+		for _, nodeID := range adj.ids {
+			delta += math.Abs(next[nodeID] - ret[nodeID])
+		}
+		ret = next
+		if delta < tolerance {
+			break
+		}
+	}
+	return
+}
+
+// labelPropagation 使用标签传播算法检测社区。
+func (adj *graphAdjacency) labelPropagation(maxIterations int) (ret []*GraphCommunity) {
+	ret = []*GraphCommunity{}
+	labels := map[string]string{}
+	for _, nodeID := range adj.ids {
+		labels[nodeID] = nodeID
+	}
+
+	for i := 0; i < maxIterations; i++ {
+		changed := false
+		for _, nodeID := range adj.ids {
+			neighbors := adj.undirect[nodeID]
+			if 1 > len(neighbors) {
+				continue
+			}
+
+			counts := map[string]int{}
+			for _, neighbor := range neighbors {
+				counts[labels[neighbor]]++
+			}
+
+			best, bestCount := labels[nodeID], counts[labels[nodeID]]
+			for label, count := range counts {
+				if count > bestCount || (count == bestCount && label < best) {
+					best, bestCount = label, count
+				}
+			}
+			if best != labels[nodeID] {
+				labels[nodeID] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	groups := map[string][]string{}
+	var groupLabels []string
+	for _, nodeID := range adj.ids {
+		label := labels[nodeID]
+		if _, ok := groups[label]; !ok {
+			groupLabels = append(groupLabels, label)
+		}
+		groups[label] = append(groups[label], nodeID)
+	}
+	sort.SliceStable(groupLabels, func(i, j int) bool { return len(groups[groupLabels[i]]) > len(groups[groupLabels[j]]) })
+	for _, label := range groupLabels {
+		if 2 > len(groups[label]) {
+			continue
+		}
+		ret = append(ret, &GraphCommunity{ID: len(ret), Nodes: groups[label]})
+	}
+	return
+}
+
+// orphansAndDeadEnds 按文档聚合引用关系，返回孤立文档和只被引用的文档。
+func (adj *graphAdjacency) orphansAndDeadEnds() (orphans, deadEnds []*GraphNode) {
+	orphans, deadEnds = []*GraphNode{}, []*GraphNode{}
+	rootOf := func(node *GraphNode) string {
+		if "" == node.Path {
+			return ""
+		}
+		return util.GetTreeID(node.Path)
+	}
+
+	outRoots, inRoots := map[string]bool{}, map[string]bool{}
+	for from, tos := range adj.out {
+		fromRoot := rootOf(adj.nodes[from])
+		for _, to := range tos {
+			toRoot := rootOf(adj.nodes[to])
+			if "" == fromRoot || "" == toRoot || fromRoot == toRoot {
+				continue
+			}
+			outRoots[fromRoot] = true
+			inRoots[toRoot] = true
+		}
+	}
+
+	for _, nodeID := range adj.ids {
+		node := adj.nodes[nodeID]
+		if "NodeDocument" != node.Type {
+			continue
+		}
+
+		if !outRoots[nodeID] && !inRoots[nodeID] {
+			orphans = append(orphans, node)
+		} else if !outRoots[nodeID] && inRoots[nodeID] {
+			deadEnds = append(deadEnds, node)
+		}
+	}
+	return
+}
+
+// shortestPath 使用广度优先搜索查找两个节点之间的最短路径，只沿引用边（正向和反向）查找，不经过层级边。
+func (adj *graphAdjacency) shortestPath(fromID, toID string) (ret []string) {
+	if nil == adj.nodes[fromID] || nil == adj.nodes[toID] {
+		return
+	}
+	if fromID == toID {
+		return []string{fromID}
+	}
+
+	prev := map[string]string{fromID: ""}
+	queue := []string{fromID}
+	for 0 < len(queue) {
+		cur := queue[0]
+		queue = queue[1:]
+		neighbors := append(append([]string{}, adj.out[cur]...), adj.in[cur]...)
+		for _, next := range neighbors {
+			if _, visited := prev[next]; visited {
+				continue
+			}
+
+			prev[next] = cur
+			if next == toID {
+				for at := toID; "" != at; at = prev[at] {
+					ret = append([]string{at}, ret...)
+				}
+				return
+			}
+			queue = append(queue, next)
+		}
+	}
+	return
+}
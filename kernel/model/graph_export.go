@@ -0,0 +1,412 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	GraphExportFormatGraphML = "graphml"
+	GraphExportFormatGEXF    = "gexf"
+	GraphExportFormatDOT     = "dot"
+)
+
+var ErrUnsupportedGraphFormat = errors.New("unsupported graph export format")
+
+// ExportGraph 导出关系图。id 不为空时导出局部关系图，否则导出全局关系图，过滤条件和关系图设置一致。
+// 返回的 filePath 为导出文件的访问路径。
+func ExportGraph(id, query, format string) (name, filePath string, err error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	var ext string
+	switch format {
+	case GraphExportFormatGraphML:
+		ext = ".graphml"
+	case GraphExportFormatGEXF:
+		ext = ".gexf"
+	case GraphExportFormatDOT:
+		ext = ".dot"
+	default:
+		err = ErrUnsupportedGraphFormat
+		return
+	}
+
+	var nodes []*GraphNode
+	var links []*GraphLink
+	if "" != id {
+		_, nodes, links = BuildTreeGraph(id, query)
+		name = "graph"
+		if bt := treenode.GetBlockTree(id); nil != bt {
+			name = path.Base(bt.HPath)
+		}
+	} else {
+		_, nodes, links = BuildGraph(query)
+		name = "graph"
+	}
+	name = util.FilterFileName(name)
+	if "" == name {
+		name = "graph"
+	}
+
+	nodes, links = normalizeExportGraph(nodes, links)
+	var data []byte
+	switch format {
+	case GraphExportFormatGraphML:
+		data, err = graph2GraphML(nodes, links)
+	case GraphExportFormatGEXF:
+		data, err = graph2GEXF(nodes, links)
+	case GraphExportFormatDOT:
+		data = graph2DOT(name, nodes, links)
+	}
+	if err != nil {
+		logging.LogErrorf("serialize graph to [%s] failed: %s", format, err)
+		return
+	}
+
+	exportFolder := filepath.Join(util.TempDir, "export", "graph")
+	if err = os.MkdirAll(exportFolder, 0755); err != nil {
+		logging.LogErrorf("mkdir [%s] failed: %s", exportFolder, err)
+		return
+	}
+
+	name += ext
+	savePath := filepath.Join(exportFolder, name)
+	if err = filelock.WriteFile(savePath, data); err != nil {
+		logging.LogErrorf("write graph export file [%s] failed: %s", savePath, err)
+		return
+	}
+	filePath = "/export/graph/" + url.PathEscape(name)
+	return
+}
+
+// normalizeExportGraph 去除重复的节点和指向不存在节点的边，重复的边只保留一条，引用边优先。
+func normalizeExportGraph(nodes []*GraphNode, links []*GraphLink) (retNodes []*GraphNode, retLinks []*GraphLink) {
+	nodeIDs := map[string]bool{}
+	for _, node := range nodes {
+		if nodeIDs[node.ID] {
+			continue
+		}
+		nodeIDs[node.ID] = true
+		retNodes = append(retNodes, node)
+	}
+
+	linkIndexes := map[string]int{}
+	for _, link := range links {
+		if !nodeIDs[link.From] || !nodeIDs[link.To] {
+			continue
+		}
+
+		key := link.From + "->" + link.To
+		if idx, ok := linkIndexes[key]; ok {
+			if link.Ref {
+				retLinks[idx] = link
+			}
+			continue
+		}
+		linkIndexes[key] = len(retLinks)
+		retLinks = append(retLinks, link)
+	}
+	return
+}
+
+func graphNodeKind(node *GraphNode) string {
+	if "" == node.Path {
+		return "tag"
+	}
+	return "block"
+}
+
+func graphNodeLabel(node *GraphNode) string {
+	if "" != node.Label {
+		return node.Label
+	}
+	return node.Title
+}
+
+func graphLinkKind(link *GraphLink) string {
+	if link.Ref {
+		return "ref"
+	}
+	return "hierarchy"
+}
+
+type graphMLDoc struct {
+	XMLName xml.Name      `xml:"graphml"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Keys    []*graphMLKey `xml:"key"`
+	Graph   *graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string         `xml:"id,attr"`
+	EdgeDefault string         `xml:"edgedefault,attr"`
+	Nodes       []*graphMLNode `xml:"node"`
+	Edges       []*graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string         `xml:"id,attr"`
+	Data []*graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string         `xml:"id,attr"`
+	Source string         `xml:"source,attr"`
+	Target string         `xml:"target,attr"`
+	Data   []*graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func graph2GraphML(nodes []*GraphNode, links []*GraphLink) (ret []byte, err error) {
+	doc := &graphMLDoc{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []*graphMLKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "kind", For: "node", AttrName: "kind", AttrType: "string"},
+			{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+			{ID: "box", For: "node", AttrName: "box", AttrType: "string"},
+			{ID: "path", For: "node", AttrName: "path", AttrType: "string"},
+			{ID: "refs", For: "node", AttrName: "refs", AttrType: "int"},
+			{ID: "defs", For: "node", AttrName: "defs", AttrType: "int"},
+			{ID: "size", For: "node", AttrName: "size", AttrType: "double"},
+			{ID: "edgeKind", For: "edge", AttrName: "kind", AttrType: "string"},
+		},
+		Graph: &graphMLGraph{ID: "siyuan", EdgeDefault: "directed"},
+	}
+
+	for _, node := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, &graphMLNode{
+			ID: node.ID,
+			Data: []*graphMLData{
+				{Key: "label", Value: graphNodeLabel(node)},
+				{Key: "kind", Value: graphNodeKind(node)},
+				{Key: "type", Value: node.Type},
+				{Key: "box", Value: node.Box},
+				{Key: "path", Value: node.Path},
+				{Key: "refs", Value: strconv.Itoa(node.Refs)},
+				{Key: "defs", Value: strconv.Itoa(node.Defs)},
+				{Key: "size", Value: strconv.FormatFloat(node.Size, 'f', -1, 64)},
+			},
+		})
+	}
+	for i, link := range links {
+		doc.Graph.Edges = append(doc.Graph.Edges, &graphMLEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: link.From,
+			Target: link.To,
+			Data:   []*graphMLData{{Key: "edgeKind", Value: graphLinkKind(link)}},
+		})
+	}
+	return marshalGraphXML(doc)
+}
+
+type gexfDoc struct {
+	XMLName xml.Name   `xml:"gexf"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Version string     `xml:"version,attr"`
+	Meta    *gexfMeta  `xml:"meta"`
+	Graph   *gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	LastModifiedDate string `xml:"lastmodifieddate,attr"`
+	Creator          string `xml:"creator"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string            `xml:"defaultedgetype,attr"`
+	Mode            string            `xml:"mode,attr"`
+	Attributes      []*gexfAttributes `xml:"attributes"`
+	Nodes           []*gexfNode       `xml:"nodes>node"`
+	Edges           []*gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string           `xml:"class,attr"`
+	Attributes []*gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string          `xml:"id,attr"`
+	Label     string          `xml:"label,attr"`
+	AttValues []*gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID        string          `xml:"id,attr"`
+	Source    string          `xml:"source,attr"`
+	Target    string          `xml:"target,attr"`
+	AttValues []*gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func graph2GEXF(nodes []*GraphNode, links []*GraphLink) (ret []byte, err error) {
+	doc := &gexfDoc{
+		Xmlns:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Meta: &gexfMeta{
+			LastModifiedDate: time.Now().Format("2006-01-02"),
+			Creator:          "SiYuan v" + util.Ver,
+		},
+		Graph: &gexfGraph{
+			DefaultEdgeType: "directed",
+			Mode:            "static",
+			Attributes: []*gexfAttributes{
+				{
+					Class: "node",
+					Attributes: []*gexfAttribute{
+						{ID: "kind", Title: "kind", Type: "string"},
+						{ID: "type", Title: "type", Type: "string"},
+						{ID: "box", Title: "box", Type: "string"},
+						{ID: "path", Title: "path", Type: "string"},
+						{ID: "refs", Title: "refs", Type: "integer"},
+						{ID: "defs", Title: "defs", Type: "integer"},
+						{ID: "size", Title: "size", Type: "double"},
+					},
+				},
+				{
+					Class:      "edge",
+					Attributes: []*gexfAttribute{{ID: "kind", Title: "kind", Type: "string"}},
+				},
+			},
+		},
+	}
+
+	for _, node := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, &gexfNode{
+			ID:    node.ID,
+			Label: graphNodeLabel(node),
+			AttValues: []*gexfAttValue{
+				{For: "kind", Value: graphNodeKind(node)},
+				{For: "type", Value: node.Type},
+				{For: "box", Value: node.Box},
+				{For: "path", Value: node.Path},
+				{For: "refs", Value: strconv.Itoa(node.Refs)},
+				{For: "defs", Value: strconv.Itoa(node.Defs)},
+				{For: "size", Value: strconv.FormatFloat(node.Size, 'f', -1, 64)},
+			},
+		})
+	}
+	for i, link := range links {
+		doc.Graph.Edges = append(doc.Graph.Edges, &gexfEdge{
+			ID:        strconv.Itoa(i),
+			Source:    link.From,
+			Target:    link.To,
+			AttValues: []*gexfAttValue{{For: "kind", Value: graphLinkKind(link)}},
+		})
+	}
+	return marshalGraphXML(doc)
+}
+
+func marshalGraphXML(doc interface{}) (ret []byte, err error) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	buf.Write(data)
+	buf.WriteByte('\n')
+	ret = buf.Bytes()
+	return
+}
+
+func graph2DOT(name string, nodes []*GraphNode, links []*GraphLink) (ret []byte) {
+	buf := bytes.Buffer{}
+	buf.WriteString("digraph ")
+	buf.WriteString(dotQuote(name))
+	buf.WriteString(" {\n")
+	for _, node := range nodes {
+		buf.WriteString("  ")
+		buf.WriteString(dotQuote(node.ID))
+		buf.WriteString(" [label=")
+		buf.WriteString(dotQuote(graphNodeLabel(node)))
+		buf.WriteString(", kind=")
+		buf.WriteString(dotQuote(graphNodeKind(node)))
+		buf.WriteString(", type=")
+		buf.WriteString(dotQuote(node.Type))
+		buf.WriteString(", box=")
+		buf.WriteString(dotQuote(node.Box))
+		buf.WriteString(", path=")
+		buf.WriteString(dotQuote(node.Path))
+		buf.WriteString(", refs=")
+		buf.WriteString(strconv.Itoa(node.Refs))
+		buf.WriteString(", defs=")
+		buf.WriteString(strconv.Itoa(node.Defs))
+		if "" == node.Path {
+			buf.WriteString(", shape=box")
+		}
+		buf.WriteString("];\n")
+	}
+	for _, link := range links {
+		buf.WriteString("  ")
+		buf.WriteString(dotQuote(link.From))
+		buf.WriteString(" -> ")
+		buf.WriteString(dotQuote(link.To))
+		buf.WriteString(" [kind=")
+		buf.WriteString(dotQuote(graphLinkKind(link)))
+		if !link.Ref {
+			buf.WriteString(", style=dashed")
+		}
+		buf.WriteString("];\n")
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	return "\"" + s + "\""
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/88250/lute/parse"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -192,34 +193,8 @@ func Doc2Heading(srcID, targetID string, after bool) (srcTreeBox, srcTreePath st
 		headingLevel = 6
 	}
 
-	srcTree.Root.RemoveIALAttr("scroll") // Remove `scroll` attribute when converting the document to a heading https://github.com/siyuan-note/siyuan/issues/9297
-	srcTree.Root.RemoveIALAttr("type")
-	tagIAL := srcTree.Root.IALAttr("tags")
-	tags := strings.Split(tagIAL, ",")
-	srcTree.Root.RemoveIALAttr("tags")
-	heading := &ast.Node{ID: srcTree.Root.ID, Type: ast.NodeHeading, HeadingLevel: headingLevel, KramdownIAL: srcTree.Root.KramdownIAL}
-	heading.SetIALAttr("updated", util.CurrentTimeSecondsStr())
-	heading.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(srcTree.Root.IALAttr("title"))})
-	heading.RemoveIALAttr("title")
+	heading := doc2HeadingNode(srcTree, headingLevel)
 	heading.Box, heading.Path = targetTree.Box, targetTree.Path
-	if "" != tagIAL && 0 < len(tags) {
-		// 带标签的文档块转换为标题块时将标签移动到标题块下方 https://github.com/siyuan-note/siyuan/issues/6550
-
-		tagPara := treenode.NewParagraph("")
-		for i, tag := range tags {
-			if "" == tag {
-				continue
-			}
-
-			tagPara.AppendChild(&ast.Node{Type: ast.NodeTextMark, TextMarkType: "tag", TextMarkTextContent: tag})
-			if i < len(tags)-1 {
-				tagPara.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(" ")})
-			}
-		}
-		if nil != tagPara.FirstChild {
-			srcTree.Root.PrependChild(tagPara)
-		}
-	}
 
 	var nodes []*ast.Node
 	if after {
@@ -273,6 +248,8 @@ func Doc2Heading(srcID, targetID string, after bool) (srcTreeBox, srcTreePath st
 	treenode.RemoveBlockTreesByRootID(srcTree.ID)
 	treenode.RemoveBlockTreesByRootID(targetTree.ID)
 	err = indexWriteTreeUpsertQueue(targetTree)
+	appendDocChange("doc2Heading", []string{srcTree.Box, targetTree.Box}, []string{srcTree.ID, targetTree.ID})
+	relocateComments([]string{srcTree.ID})
 	IncSync()
 	go func() {
 		time.Sleep(util.SQLFlushInterval)
@@ -283,6 +260,38 @@ func Doc2Heading(srcID, targetID string, after bool) (srcTreeBox, srcTreePath st
 	return
 }
 
+// doc2HeadingNode 将文档块转换为标题块，文档块 ID 作为标题块 ID，标签会移动到文档内容最前面。
+func doc2HeadingNode(srcTree *parse.Tree, headingLevel int) (heading *ast.Node) {
+	srcTree.Root.RemoveIALAttr("scroll") // Remove `scroll` attribute when converting the document to a heading https://github.com/siyuan-note/siyuan/issues/9297
+	srcTree.Root.RemoveIALAttr("type")
+	tagIAL := srcTree.Root.IALAttr("tags")
+	tags := strings.Split(tagIAL, ",")
+	srcTree.Root.RemoveIALAttr("tags")
+	heading = &ast.Node{ID: srcTree.Root.ID, Type: ast.NodeHeading, HeadingLevel: headingLevel, KramdownIAL: srcTree.Root.KramdownIAL}
+	heading.SetIALAttr("updated", util.CurrentTimeSecondsStr())
+	heading.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(srcTree.Root.IALAttr("title"))})
+	heading.RemoveIALAttr("title")
+	if "" != tagIAL && 0 < len(tags) {
+		// 带标签的文档块转换为标题块时将标签移动到标题块下方 https://github.com/siyuan-note/siyuan/issues/6550
+
+		tagPara := treenode.NewParagraph("")
+		for i, tag := range tags {
+			if "" == tag {
+				continue
+			}
+
+			tagPara.AppendChild(&ast.Node{Type: ast.NodeTextMark, TextMarkType: "tag", TextMarkTextContent: tag})
+			if i < len(tags)-1 {
+				tagPara.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(" ")})
+			}
+		}
+		if nil != tagPara.FirstChild {
+			srcTree.Root.PrependChild(tagPara)
+		}
+	}
+	return
+}
+
 func Heading2Doc(srcHeadingID, targetBoxID, targetPath, previousPath string) (srcRootBlockID, newTargetPath string, err error) {
 	FlushTxQueue()
 
@@ -413,6 +422,8 @@ func Heading2Doc(srcHeadingID, targetBoxID, targetPath, previousPath string) (sr
 	if err = indexWriteTreeUpsertQueue(newTree); err != nil {
 		return "", "", err
 	}
+	appendDocChange("heading2Doc", []string{srcTree.Box, newTree.Box}, []string{srcTree.ID, newTree.ID})
+	relocateComments([]string{srcTree.ID})
 	IncSync()
 	go func() {
 		RefreshBacklink(srcTree.ID)
@@ -421,3 +432,176 @@ func Heading2Doc(srcHeadingID, targetBoxID, targetPath, previousPath string) (sr
 	}()
 	return
 }
+
+// SplitDoc 将文档中指定级别的标题全部转换为子文档，标题块 ID 会作为子文档 ID，所以引用保持有效。
+func SplitDoc(rootID string, level int) (box string, paths []string, err error) {
+	if 1 > level || 6 < level {
+		err = errors.New("invalid heading level")
+		return
+	}
+
+	FlushTxQueue()
+
+	tree, _ := LoadTreeByBlockID(rootID)
+	if nil == tree {
+		err = ErrBlockNotFound
+		return
+	}
+
+	var headingIDs []string
+	for c := tree.Root.FirstChild; nil != c; c = c.Next {
+		if ast.NodeHeading == c.Type && level == c.HeadingLevel {
+			headingIDs = append(headingIDs, c.ID)
+		}
+	}
+	if 1 > len(headingIDs) {
+		err = errors.New("no heading of the specified level found")
+		return
+	}
+
+	box, paths = tree.Box, []string{}
+	var previousPath string
+	for _, headingID := range headingIDs {
+		if _, previousPath, err = Heading2Doc(headingID, tree.Box, tree.Path, previousPath); err != nil {
+			return
+		}
+		paths = append(paths, previousPath)
+	}
+	return
+}
+
+// MergeDocs 将多个文档合并到第一个文档末尾，被合并文档的标题转换为标题块并移除被合并的文档。
+// 文档块 ID 会作为标题块 ID，所以指向被合并文档的引用会指向对应的标题块。
+func MergeDocs(ids []string) (targetID string, err error) {
+	ids = gulu.Str.RemoveDuplicatedElem(ids)
+	if 2 > len(ids) {
+		err = errors.New("at least two documents are required")
+		return
+	}
+
+	FlushTxQueue()
+
+	targetTree, _ := LoadTreeByBlockID(ids[0])
+	if nil == targetTree {
+		err = ErrBlockNotFound
+		return
+	}
+	targetID = targetTree.ID
+
+	var srcTrees []*parse.Tree
+	for _, id := range ids[1:] {
+		srcTree, _ := LoadTreeByBlockID(id)
+		if nil == srcTree {
+			err = ErrBlockNotFound
+			return
+		}
+		if srcTree.ID == targetTree.ID {
+			continue
+		}
+
+		subDir := filepath.Join(util.DataDir, srcTree.Box, strings.TrimSuffix(srcTree.Path, ".sy"))
+		if gulu.File.IsDir(subDir) && !util.IsEmptyDir(subDir) {
+			err = errors.New(Conf.Language(20))
+			return
+		}
+		srcTrees = append(srcTrees, srcTree)
+	}
+	if 1 > len(srcTrees) {
+		return
+	}
+
+	generateOpTypeHistory(targetTree, HistoryOpUpdate)
+	sql.DeleteRefsTreeQueue(targetTree)
+
+	headingLevel := treenode.TopHeadingLevel(targetTree)
+	if 1 > headingLevel {
+		headingLevel = 1
+	}
+
+	if last := targetTree.Root.LastChild; nil != last && ast.NodeParagraph == last.Type && nil == last.FirstChild {
+		last.Unlink()
+	}
+
+	var removedIDs []string
+	for _, srcTree := range srcTrees {
+		generateOpTypeHistory(srcTree, HistoryOpUpdate)
+		sql.DeleteRefsTreeQueue(srcTree)
+
+		deltaLevel := headingLevel - treenode.TopHeadingLevel(srcTree) + 1
+		heading := doc2HeadingNode(srcTree, headingLevel)
+		heading.Box, heading.Path = targetTree.Box, targetTree.Path
+		targetTree.Root.AppendChild(heading)
+
+		var nodes []*ast.Node
+		for c := srcTree.Root.FirstChild; nil != c; c = c.Next {
+			nodes = append(nodes, c)
+		}
+		for _, n := range nodes {
+			if ast.NodeHeading == n.Type {
+				n.HeadingLevel = n.HeadingLevel + deltaLevel
+				if 6 < n.HeadingLevel {
+					n.HeadingLevel = 6
+				}
+			}
+			n.Box, n.Path = targetTree.Box, targetTree.Path
+			targetTree.Root.AppendChild(n)
+		}
+
+		removedIDs = append(removedIDs, srcTree.ID)
+	}
+
+	// 先写入目标文档，成功后再删除被合并的文档，避免写入失败时丢失内容
+	targetTree.Root.SetIALAttr("updated", util.CurrentTimeSecondsStr())
+	treenode.RemoveBlockTreesByRootID(targetTree.ID)
+	if err = indexWriteTreeUpsertQueue(targetTree); err != nil {
+		logging.LogErrorf("write merged tree [%s] failed: %s", targetTree.ID, err)
+		// 被合并的文档没有删除，恢复块树
+		luteEngine := util.NewLute()
+		for _, tree := range append(srcTrees, targetTree) {
+			if loaded, loadErr := filesys.LoadTree(tree.Box, tree.Path, luteEngine); nil == loadErr {
+				treenode.UpsertBlockTree(loaded)
+			}
+		}
+		return
+	}
+
+	for _, srcTree := range srcTrees {
+		subDir := filepath.Join(util.DataDir, srcTree.Box, strings.TrimSuffix(srcTree.Path, ".sy"))
+		if gulu.File.IsDir(subDir) {
+			if removeErr := os.Remove(subDir); nil != removeErr {
+				logging.LogWarnf("remove empty dir [%s] failed: %s", subDir, removeErr)
+			}
+		}
+
+		box := Conf.Box(srcTree.Box)
+		if removeErr := box.Remove(srcTree.Path); nil != removeErr {
+			logging.LogWarnf("remove tree [%s] failed: %s", srcTree.Path, removeErr)
+		}
+		box.removeSort([]string{srcTree.ID})
+		treenode.RemoveBlockTreesByRootID(srcTree.ID)
+	}
+
+	RemoveRecentDoc(removedIDs)
+	evt := util.NewCmdResult("removeDoc", 0, util.PushModeBroadcast)
+	evt.Data = map[string]interface{}{
+		"ids": removedIDs,
+	}
+	util.PushEvent(evt)
+
+	var mergedBoxes []string
+	for _, srcTree := range srcTrees {
+		mergedBoxes = append(mergedBoxes, srcTree.Box)
+	}
+	appendDocChange("mergeDocs", append(mergedBoxes, targetTree.Box), append(removedIDs, targetTree.ID))
+	relocateComments(removedIDs)
+	IncSync()
+	go func() {
+		time.Sleep(util.SQLFlushInterval)
+		for _, id := range removedIDs {
+			RefreshBacklink(id)
+		}
+		RefreshBacklink(targetTree.ID)
+		ResetVirtualBlockRefCache()
+	}()
+	return
+}
@@ -41,6 +41,7 @@ import (
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/task"
@@ -168,6 +169,11 @@ func GetDocHistoryContent(historyPath, keyword string, highlight bool) (id, root
 	}
 	isLargeDoc = 1024*1024*1 <= len(data)
 
+	if data, err = filesys.DecryptTreeData(data); err != nil {
+		logging.LogErrorf("decrypt file [%s] failed: %s", historyPath, err)
+		return
+	}
+
 	luteEngine := NewLute()
 	historyTree, err := dataparser.ParseJSONWithoutFix(data, luteEngine.ParseOptions)
 	if err != nil {
@@ -882,6 +888,10 @@ func indexHistoryDir(name string, luteEngine *lute.Lute) {
 		content := tree.Root.Content()
 		p := strings.TrimPrefix(doc, util.HistoryDir)
 		p = filepath.ToSlash(p[1:])
+		if parts := strings.Split(p, "/"); 2 < len(parts) && filesys.IsBoxEncrypted(parts[1]) {
+			// 加密笔记本的历史不索引内容，避免明文落盘
+			content = ""
+		}
 		histories = append(histories, &sql.History{
 			ID:      tree.Root.ID,
 			Type:    HistoryTypeDoc,
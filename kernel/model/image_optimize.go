@@ -0,0 +1,318 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/go-humanize"
+	"github.com/disintegration/imaging"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ImageOptimizeResult 描述一个图片资源文件的优化结果。
+type ImageOptimizeResult struct {
+	Path    string `json:"path"`
+	NewPath string `json:"newPath"` // 转换格式后的路径，未转换时和 Path 相同
+	Size    int64  `json:"size"`    // 优化前大小
+	NewSize int64  `json:"newSize"` // 优化后大小
+	Saved   int64  `json:"saved"`
+}
+
+// OptimizeImages 按照图片配置优化指定的图片资源文件，paths 为空时优化 data/assets 下的所有图片。
+//
+// 转换格式后会更新所有文档和属性视图中的引用，引用更新成功后才移除原始文件，被替换的原始文件移入数据历史。
+func OptimizeImages(paths []string, opt *conf.Image) (ret []*ImageOptimizeResult, saved int64, err error) {
+	ret = []*ImageOptimizeResult{}
+	if nil == opt {
+		opt = Conf.Image
+	}
+
+	if 1 > len(paths) {
+		assetsDir := util.GetDataAssetsAbsPath()
+		filelock.Walk(assetsDir, func(absPath string, d fs.DirEntry, walkErr error) error {
+			if nil != walkErr || nil == d || assetsDir == absPath {
+				return nil
+			}
+			if isSkipFile(d.Name()) || filelock.IsHidden(absPath) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || !isOptimizableImage(absPath) {
+				return nil
+			}
+
+			p := filepath.ToSlash(absPath)
+			paths = append(paths, p[strings.Index(p, "assets/"):])
+			return nil
+		})
+	}
+
+	util.PushEndlessProgress(Conf.Language(116))
+	defer util.PushClearProgress()
+
+	historyDir, err := GetHistoryDir(HistoryOpClean)
+	if err != nil {
+		return
+	}
+
+	var oldNew []string
+	converted := map[string]string{} // 转换格式的原始文件绝对路径 -> 转换后的文件绝对路径
+	discardConverted := func() {
+		// 引用没有更新，保留原始文件，移除转换后的文件
+		for _, newAbsPath := range converted {
+			filelock.Remove(newAbsPath)
+		}
+	}
+	for i, p := range paths {
+		absPath, getErr := GetAssetAbsPath(p)
+		if nil != getErr || !isOptimizableImage(absPath) {
+			continue
+		}
+
+		historyPath := filepath.Join(historyDir, p)
+		if err = filelock.Copy(absPath, historyPath); err != nil {
+			logging.LogErrorf("backup image [%s] failed: %s", absPath, err)
+			discardConverted()
+			return
+		}
+
+		newAbsPath, size, newSize, changed, optErr := optimizeImage(absPath, opt)
+		if nil != optErr {
+			logging.LogWarnf("optimize image [%s] failed: %s", absPath, optErr)
+		}
+		if !changed {
+			os.Remove(historyPath)
+			continue
+		}
+
+		newPath := p
+		if newAbsPath != absPath {
+			newPath = strings.TrimSuffix(p, filepath.Ext(p)) + filepath.Ext(newAbsPath)
+			oldNew = append(oldNew, p, newPath)
+			converted[absPath] = newAbsPath
+		}
+		ret = append(ret, &ImageOptimizeResult{Path: p, NewPath: newPath, Size: size, NewSize: newSize, Saved: size - newSize})
+		saved += size - newSize
+		util.PushEndlessProgress(fmt.Sprintf("[%d/%d] %s", i+1, len(paths), p))
+	}
+	indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+
+	if 0 < len(oldNew) {
+		FlushTxQueue()
+		if err = rewriteAssetsRefs(oldNew); err != nil {
+			discardConverted()
+			return
+		}
+	}
+	for absPath, newAbsPath := range converted {
+		removeConvertedImage(absPath, newAbsPath)
+	}
+
+	logging.LogInfof("optimized images [%d], saved [%s]", len(ret), humanize.BytesCustomCeil(uint64(saved), 2))
+	if 0 < len(ret) {
+		IncSync()
+	}
+	return
+}
+
+// optimizeUploadImage 在上传时优化图片，返回优化后的文件名。
+func optimizeUploadImage(absPath string, opt *conf.Image) (fName string) {
+	fName = filepath.Base(absPath)
+	if !isOptimizableImage(absPath) {
+		return
+	}
+
+	newAbsPath, size, newSize, _, err := optimizeImage(absPath, opt)
+	if err != nil {
+		logging.LogWarnf("optimize image [%s] failed: %s", absPath, err)
+		return
+	}
+	if newAbsPath != absPath {
+		// 刚上传的文件还没有被引用，可以直接移除原始文件
+		removeConvertedImage(absPath, newAbsPath)
+	}
+	if size != newSize {
+		logging.LogInfof("optimized image [%s], saved [%d]", newAbsPath, size-newSize)
+	}
+	return filepath.Base(newAbsPath)
+}
+
+func isOptimizableImage(absPath string) bool {
+	switch strings.ToLower(filepath.Ext(absPath)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// imageOptimizedComment 标记已经优化过的 JPEG，再次优化时不重新编码，避免反复有损压缩降低画质。
+var imageOptimizedComment = []byte("SiYuan optimized")
+
+// optimizeImage 缩小并重新压缩 JPEG/PNG 图片，不透明的 PNG 可转换为 JPEG。
+//
+// 只有缩小了尺寸、转换了格式、移除了 GPS 信息或者体积变小时才会覆盖原文件。
+// 转换格式时写入新的文件，原始文件由调用方在更新引用后通过 removeConvertedImage 移除。
+func optimizeImage(absPath string, opt *conf.Image) (newAbsPath string, size, newSize int64, changed bool, err error) {
+	newAbsPath = absPath
+	data, err := filelock.ReadFile(absPath)
+	if err != nil {
+		return
+	}
+	size, newSize = int64(len(data)), int64(len(data))
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return
+	}
+
+	resized := false
+	bounds := img.Bounds()
+	if (0 < opt.MaxWidth && bounds.Dx() > opt.MaxWidth) || (0 < opt.MaxHeight && bounds.Dy() > opt.MaxHeight) {
+		maxWidth, maxHeight := opt.MaxWidth, opt.MaxHeight
+		if 1 > maxWidth {
+			maxWidth = bounds.Dx()
+		}
+		if 1 > maxHeight {
+			maxHeight = bounds.Dy()
+		}
+		img = imaging.Fit(img, maxWidth, maxHeight, imaging.Lanczos)
+		resized = true
+	}
+
+	ext := strings.ToLower(filepath.Ext(absPath))
+	isJPEG := ".jpg" == ext || ".jpeg" == ext
+	if isJPEG && !resized && nil != util.JPEGComment(data, imageOptimizedComment) {
+		// 已经优化过的 JPEG 只在需要时移除 GPS 信息，不重新编码
+		if !opt.StripGPS {
+			return
+		}
+		data = bytes.Clone(data)
+		if exif := util.JPEGExif(data); nil == exif || !util.StripExifGPS(exif) {
+			return
+		}
+		if err = filelock.WriteFile(absPath, data); err != nil {
+			return
+		}
+		changed = true
+		removeAssetThumbnail(absPath)
+		removeOptimizedAssetCache(absPath)
+		return
+	}
+
+	converted := !isJPEG && opt.ConvertPNG && isOpaqueImage(img)
+	if converted {
+		newAbsPath = strings.TrimSuffix(absPath, filepath.Ext(absPath)) + ".jpg"
+		if filelock.IsExist(newAbsPath) {
+			converted = false
+			newAbsPath = absPath
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	gpsStripped := false
+	if isJPEG || converted {
+		if err = jpeg.Encode(buf, img, &jpeg.Options{Quality: opt.Quality}); err != nil {
+			return
+		}
+		buf = bytes.NewBuffer(util.InsertJPEGComment(buf.Bytes(), imageOptimizedComment))
+
+		if exif := util.JPEGExif(data); nil != exif {
+			// 重新编码会丢失 EXIF，需要写回原始的 EXIF 数据
+			exif = bytes.Clone(exif)
+			util.ResetExifOrientation(exif)
+			if opt.StripGPS {
+				gpsStripped = util.StripExifGPS(exif)
+			}
+			buf = bytes.NewBuffer(util.InsertJPEGExif(buf.Bytes(), exif))
+		}
+	} else {
+		encoder := &png.Encoder{CompressionLevel: png.BestCompression}
+		if err = encoder.Encode(buf, img); err != nil {
+			return
+		}
+	}
+
+	if !resized && !converted && !gpsStripped && buf.Len() >= len(data) {
+		return
+	}
+
+	if err = filelock.WriteFile(newAbsPath, buf.Bytes()); err != nil {
+		newAbsPath = absPath
+		return
+	}
+	newSize = int64(buf.Len())
+	changed = true
+
+	if newAbsPath == absPath {
+		removeAssetThumbnail(absPath)
+		removeOptimizedAssetCache(absPath)
+	}
+	return
+}
+
+// removeConvertedImage 在引用更新为转换后的文件之后移除原始文件，并将资源文件文本迁移到转换后的文件上。
+func removeConvertedImage(absPath, newAbsPath string) {
+	if err := filelock.Remove(absPath); err != nil {
+		logging.LogErrorf("remove converted image [%s] failed: %s", absPath, err)
+		return
+	}
+	removeAssetThumbnail(absPath)
+	p := removeOptimizedAssetCache(absPath)
+	if "" == p {
+		return
+	}
+
+	newPath := strings.TrimSuffix(p, filepath.Ext(p)) + filepath.Ext(newAbsPath)
+	if text := util.GetAssetText(p); "" != text {
+		util.SetAssetText(newPath, text)
+	}
+	util.RemoveAssetText(p)
+}
+
+// removeOptimizedAssetCache 移除资源文件缓存，返回资源文件相对于 data 的路径。
+func removeOptimizedAssetCache(absPath string) (ret string) {
+	p := filepath.ToSlash(absPath)
+	idx := strings.Index(p, "assets/")
+	if -1 == idx {
+		return
+	}
+	ret = p[idx:]
+	cache.RemoveAsset(ret)
+	return
+}
+
+func isOpaqueImage(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return opaque.Opaque()
+	}
+	return false
+}
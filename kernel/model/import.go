@@ -164,6 +164,11 @@ func ImportSY(zipPath, boxID, toPath string) (err error) {
 			err = readErr
 			return
 		}
+		if data, readErr = filesys.DecryptTreeData(data); nil != readErr {
+			logging.LogErrorf("decrypt .sy [%s] failed: %s", syPath, readErr)
+			err = readErr
+			return
+		}
 		tree, _, parseErr := dataparser.ParseJSON(data, luteEngine.ParseOptions)
 		if nil != parseErr {
 			logging.LogErrorf("parse .sy [%s] failed: %s", syPath, parseErr)
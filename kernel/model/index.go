@@ -241,6 +241,11 @@ func IndexRefs() {
 					continue
 				}
 
+				if data, readErr = filesys.DecryptTreeData(data); nil != readErr {
+					logging.LogWarnf("decrypt data [path=%s] failed: %s", treeAbsPath, readErr)
+					continue
+				}
+
 				if !bytes.Contains(data, []byte("TextMarkBlockRefID")) && !bytes.Contains(data, []byte("TextMarkFileAnnotationRefID")) {
 					continue
 				}
@@ -0,0 +1,194 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func newTestLANServer(t *testing.T) *gin.Engine {
+	oldConf := Conf
+	Conf = &AppConf{
+		Sync: &conf.Sync{LAN: &conf.LAN{Enabled: true}},
+		Repo: &conf.Repo{Key: []byte("0123456789abcdef0123456789abcdef")},
+	}
+	t.Cleanup(func() { Conf = oldConf })
+
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Handle("POST", "/api/lan/putObject", CheckLANAuth, func(c *gin.Context) { c.Status(http.StatusOK) })
+	return server
+}
+
+// newTestLANRequest 构造签名后的请求，返回的请求体已经加密。
+func newTestLANRequest(t *testing.T, body string) (*http.Request, string) {
+	data, err := EncryptLANPayload([]byte(body))
+	if err != nil {
+		t.Fatalf("encrypt payload failed: %s", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:6806/api/lan/putObject?key=objects%2Fab%2Fcd", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("new request failed: %s", err)
+	}
+	signLANRequest(req, data)
+	return req, string(data)
+}
+
+func serveTestLANRequest(server *gin.Engine, req *http.Request, body string) int {
+	req.Body = io.NopCloser(strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func TestCheckLANAuth(t *testing.T) {
+	server := newTestLANServer(t)
+
+	req, body := newTestLANRequest(t, "data")
+	if code := serveTestLANRequest(server, req, body); http.StatusOK != code {
+		t.Fatalf("expected signed request accepted, got [%d]", code)
+	}
+	if code := serveTestLANRequest(server, req, body); http.StatusUnauthorized != code {
+		t.Fatalf("expected replayed request rejected, got [%d]", code)
+	}
+
+	req, _ = newTestLANRequest(t, "data")
+	if code := serveTestLANRequest(server, req, "tampered"); http.StatusUnauthorized != code {
+		t.Fatalf("expected tampered body rejected, got [%d]", code)
+	}
+
+	req, body = newTestLANRequest(t, "data")
+	req.URL.RawQuery = "key=objects%2Fab%2Fef"
+	if code := serveTestLANRequest(server, req, body); http.StatusUnauthorized != code {
+		t.Fatalf("expected tampered URI rejected, got [%d]", code)
+	}
+
+	req, body = newTestLANRequest(t, "data")
+	req.Header.Del(lanHeaderNonce)
+	if code := serveTestLANRequest(server, req, body); http.StatusUnauthorized != code {
+		t.Fatalf("expected request without nonce rejected, got [%d]", code)
+	}
+
+	// 超出签名窗口的请求即使签名正确也拒绝
+	req, body = newTestLANRequest(t, "data")
+	timestamp := strconv.FormatInt(time.Now().Add(-2*lanSignWindow).UnixMilli(), 10)
+	nonce := req.Header.Get(lanHeaderNonce)
+	req.Header.Set(lanHeaderTimestamp, timestamp)
+	req.Header.Set(lanHeaderSign, lanRequestSign(timestamp, nonce, req.URL.RequestURI(), []byte(body)))
+	if code := serveTestLANRequest(server, req, body); http.StatusUnauthorized != code {
+		t.Fatalf("expected expired request rejected, got [%d]", code)
+	}
+
+	Conf.Repo.Key = []byte("another key")
+	req, body = newTestLANRequest(t, "data")
+	Conf.Repo.Key = []byte("0123456789abcdef0123456789abcdef")
+	if code := serveTestLANRequest(server, req, body); http.StatusUnauthorized != code {
+		t.Fatalf("expected request signed by another key rejected, got [%d]", code)
+	}
+
+	// 签名正确但不是使用传输密钥加密的请求体
+	req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:6806/api/lan/putObject", strings.NewReader("plain data"))
+	if err != nil {
+		t.Fatalf("new request failed: %s", err)
+	}
+	signLANRequest(req, []byte("plain data"))
+	if code := serveTestLANRequest(server, req, "plain data"); http.StatusBadRequest != code {
+		t.Fatalf("expected plaintext payload rejected, got [%d]", code)
+	}
+}
+
+func TestLANPayload(t *testing.T) {
+	newTestLANServer(t)
+
+	data, err := EncryptLANPayload([]byte("payload"))
+	if err != nil || "payload" == string(data) {
+		t.Fatalf("encrypt payload failed [data=%s, err=%v]", data, err)
+	}
+	plain, err := decryptLANPayload(data)
+	if err != nil || "payload" != string(plain) {
+		t.Fatalf("decrypt payload failed [data=%s, err=%v]", plain, err)
+	}
+	if _, err = decryptLANPayload([]byte("short")); nil == err {
+		t.Fatalf("expected short payload rejected")
+	}
+
+	Conf.Repo.Key = []byte("another key")
+	if _, err = decryptLANPayload(data); nil == err {
+		t.Fatalf("expected payload encrypted by another key rejected")
+	}
+}
+
+func TestLANCloud(t *testing.T) {
+	store := &localLANStore{dir: t.TempDir()}
+	s := NewLANCloud(&cloud.BaseCloud{Conf: &cloud.Conf{Dir: "main", RepoPath: t.TempDir()}}, store)
+
+	if _, err := s.UploadBytes("objects/ab/cdef", []byte("chunk"), false); err != nil {
+		t.Fatalf("upload object failed: %s", err)
+	}
+	if _, err := s.UploadBytes("objects/ab/cdef", []byte("other"), false); err != nil {
+		t.Fatalf("upload object failed: %s", err)
+	}
+	data, err := s.DownloadObject("objects/ab/cdef")
+	if err != nil || "chunk" != string(data) {
+		t.Fatalf("download object failed [data=%s, err=%v]", data, err)
+	}
+	if _, err = s.DownloadObject("objects/ab/missing"); err != cloud.ErrCloudObjectNotFound {
+		t.Fatalf("expected object not found, got [%v]", err)
+	}
+	// 对象键不能逃逸出托管仓库目录
+	if _, err = s.UploadBytes("../../escape", []byte("x"), true); err != nil {
+		t.Fatalf("upload object failed: %s", err)
+	}
+	if data, err = store.get("escape"); err != nil || "x" != string(data) {
+		t.Fatalf("expected escaped key cleaned into store [data=%s, err=%v]", data, err)
+	}
+
+	chunks, err := s.GetChunks([]string{"abcdef", "ab0000"})
+	if err != nil || 1 != len(chunks) || "ab0000" != chunks[0] {
+		t.Fatalf("unexpected missing chunks [chunks=%v, err=%v]", chunks, err)
+	}
+
+	objects, err := s.ListObjects("objects/ab")
+	if err != nil || 1 != len(objects) || nil == objects["cdef"] || 5 != objects["cdef"].Size {
+		t.Fatalf("unexpected objects [objects=%v, err=%v]", objects, err)
+	}
+
+	if err = s.RemoveObject("objects/ab/cdef"); err != nil {
+		t.Fatalf("remove object failed: %s", err)
+	}
+	if _, err = s.DownloadObject("objects/ab/cdef"); err != cloud.ErrCloudObjectNotFound {
+		t.Fatalf("expected removed object not found, got [%v]", err)
+	}
+}
+
+func TestLANCloudRefsFiles(t *testing.T) {
+	store := &localLANStore{dir: t.TempDir()}
+	s := NewLANCloud(&cloud.BaseCloud{Conf: &cloud.Conf{Dir: "main", RepoPath: t.TempDir()}}, store)
+	uploadTestRepoRefs(t, s)
+	checkTestRepoRefs(t, s)
+}
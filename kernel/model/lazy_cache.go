@@ -0,0 +1,363 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// LazyCacheStats 懒加载缓存统计信息。
+type LazyCacheStats struct {
+	Enabled      bool    `json:"enabled"`
+	Budget       int64   `json:"budget"`       // 缓存上限，单位：字节，0 表示不限制
+	CachedBytes  int64   `json:"cachedBytes"`  // 已经物化的资源文件大小
+	CachedCount  int     `json:"cachedCount"`  // 已经物化的资源文件数
+	Hits         int64   `json:"hits"`         // 访问懒加载的资源文件时已经物化在本地
+	Misses       int64   `json:"misses"`       // 访问时需要从仓库加载，不包括预取
+	HitRate      float64 `json:"hitRate"`      // 命中率
+	PendingLoads int     `json:"pendingLoads"` // 正在加载的资源文件数
+	Evictions    int64   `json:"evictions"`    // 淘汰的资源文件数
+}
+
+// lazyCacheEntry 记录一个通过懒加载物化到本地的资源文件。
+type lazyCacheEntry struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"modTime"`  // 物化时的文件修改时间，和当前不一致说明文件在本地被修改过，不能淘汰
+	Accessed int64  `json:"accessed"` // 最近访问时间，用于 LRU 淘汰
+}
+
+type lazyLoadCall struct {
+	done chan struct{}
+	err  error
+}
+
+type lazyAssetCache struct {
+	entries   map[string]*lazyCacheEntry
+	pending   map[string]*lazyLoadCall
+	hits      int64
+	misses    int64
+	evictions int64
+	loaded    bool
+	lock      sync.Mutex
+}
+
+var lazyCache = &lazyAssetCache{
+	entries: map[string]*lazyCacheEntry{},
+	pending: map[string]*lazyLoadCall{},
+}
+
+var (
+	lazyRepo            *dejavu.Repo
+	lazyRepoFingerprint string
+	lazyRepoLock        = sync.Mutex{}
+)
+
+// getLazyRepository 返回懒加载使用的仓库实例，同步配置不变时复用同一个实例，避免每次加载都重新构造仓库。
+func getLazyRepository() (ret *dejavu.Repo, err error) {
+	cloudConf, err := buildCloudConf()
+	if err != nil {
+		return
+	}
+
+	// buildCloudConf 不包含 SFTP 配置，需要单独计入指纹
+	data, err := gulu.JSON.MarshalJSON([]any{cloudConf, Conf.Sync.SFTP})
+	if err != nil {
+		return
+	}
+	fingerprint := fmt.Sprintf("%d:%x:%t:%x", Conf.Sync.Provider, sha256.Sum256(data), Conf.Repo.LazyLoadEnabled, sha256.Sum256(Conf.Repo.Key))
+
+	lazyRepoLock.Lock()
+	defer lazyRepoLock.Unlock()
+	if nil != lazyRepo && fingerprint == lazyRepoFingerprint {
+		return lazyRepo, nil
+	}
+
+	if ret, err = newRepository(); err != nil {
+		return
+	}
+	lazyRepo, lazyRepoFingerprint = ret, fingerprint
+	return
+}
+
+func lazyCacheIndexPath() string {
+	return filepath.Join(util.ConfDir, "lazy-cache.json")
+}
+
+func (cache *lazyAssetCache) ensureLoaded() {
+	if cache.loaded {
+		return
+	}
+	cache.loaded = true
+
+	p := lazyCacheIndexPath()
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if err != nil {
+		logging.LogErrorf("read lazy cache index [%s] failed: %s", p, err)
+		return
+	}
+
+	var entries []*lazyCacheEntry
+	if err = gulu.JSON.UnmarshalJSON(data, &entries); err != nil {
+		logging.LogErrorf("unmarshal lazy cache index [%s] failed: %s", p, err)
+		return
+	}
+	for _, entry := range entries {
+		cache.entries[entry.Path] = entry
+	}
+}
+
+func (cache *lazyAssetCache) save() {
+	var entries []*lazyCacheEntry
+	for _, entry := range cache.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	data, err := gulu.JSON.MarshalIndentJSON(entries, "", "\t")
+	if err != nil {
+		logging.LogErrorf("marshal lazy cache index failed: %s", err)
+		return
+	}
+
+	p := lazyCacheIndexPath()
+	if err = filelock.WriteFile(p, data); err != nil {
+		logging.LogErrorf("write lazy cache index [%s] failed: %s", p, err)
+	}
+}
+
+// touch 记录一次资源文件访问，只有通过懒加载物化的资源文件才计入命中。
+func (cache *lazyAssetCache) touch(relativePath string) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.ensureLoaded()
+	if entry := cache.entries[relativePath]; nil != entry {
+		cache.hits++
+		entry.Accessed = time.Now().UnixMilli()
+	}
+}
+
+// load 在访问时从仓库加载资源文件，计入未命中。
+func (cache *lazyAssetCache) load(relativePath string) error {
+	return cache.loadAsset(relativePath, false)
+}
+
+// prefetch 预取资源文件，不计入命中率。
+func (cache *lazyAssetCache) prefetch(relativePath string) error {
+	return cache.loadAsset(relativePath, true)
+}
+
+// loadAsset 从仓库加载资源文件，同一个资源文件并发加载时只会加载一次。
+func (cache *lazyAssetCache) loadAsset(relativePath string, prefetch bool) (err error) {
+	cache.lock.Lock()
+	cache.ensureLoaded()
+	if !prefetch {
+		cache.misses++
+	}
+	if call := cache.pending[relativePath]; nil != call {
+		cache.lock.Unlock()
+		<-call.done
+		return call.err
+	}
+
+	call := &lazyLoadCall{done: make(chan struct{})}
+	cache.pending[relativePath] = call
+	cache.lock.Unlock()
+
+	defer func() {
+		call.err = err
+		close(call.done)
+
+		cache.lock.Lock()
+		delete(cache.pending, relativePath)
+		cache.lock.Unlock()
+	}()
+
+	repo, err := getLazyRepository()
+	if err != nil {
+		logging.LogErrorf("get repository for lazy load failed: %s", err)
+		return
+	}
+
+	if err = repo.LoadAssetOnDemand(relativePath); err != nil {
+		logging.LogErrorf("lazy load asset [%s] failed: %s", relativePath, err)
+		return
+	}
+
+	info, statErr := os.Stat(filepath.Join(util.DataDir, relativePath))
+	if nil != statErr {
+		return
+	}
+
+	cache.lock.Lock()
+	cache.entries[relativePath] = &lazyCacheEntry{
+		Path:     relativePath,
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UnixNano(),
+		Accessed: time.Now().UnixMilli(),
+	}
+	evicted := cache.evict(repo, relativePath)
+	cache.save()
+	cache.lock.Unlock()
+
+	if 0 < len(evicted) {
+		// 修复需要扫描整个仓库，放在锁外执行，避免阻塞其他资源文件的加载
+		if _, repairErr := repo.RepairLazyDataConsistency(); nil != repairErr {
+			logging.LogErrorf("repair lazy data consistency after evicting [%d] assets failed: %s", len(evicted), repairErr)
+		}
+	}
+	return
+}
+
+// evict 缓存超出上限时按最近访问时间淘汰资源文件，keep 为刚加载的资源文件，不会被淘汰。
+//
+// 只淘汰仓库记录为已缓存并且物化后没有在本地修改过的资源文件，这些文件和仓库中的版本一致。
+// 返回被淘汰的资源文件路径，调用方需要在释放锁后通过仓库修复懒加载数据一致性，将这些文件重新标记为懒加载，之后可以再次按需加载。
+func (cache *lazyAssetCache) evict(repo *dejavu.Repo, keep string) (evicted []string) {
+	budget := Conf.Repo.LazyCacheSize * 1024 * 1024
+	if 1 > budget {
+		return
+	}
+
+	var total int64
+	var entries []*lazyCacheEntry
+	for p, entry := range cache.entries {
+		info, err := os.Stat(filepath.Join(util.DataDir, p))
+		if nil != err {
+			// 文件已经不在本地（被删除或者被清理），不再跟踪
+			delete(cache.entries, p)
+			continue
+		}
+		if info.ModTime().UnixNano() != entry.ModTime || info.Size() != entry.Size {
+			// 文件在本地被修改过，转为普通资源文件
+			delete(cache.entries, p)
+			continue
+		}
+
+		total += entry.Size
+		entries = append(entries, entry)
+	}
+	if total <= budget {
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Accessed < entries[j].Accessed })
+	for _, entry := range entries {
+		if total <= budget {
+			break
+		}
+		if entry.Path == keep || nil != cache.pending[entry.Path] {
+			continue
+		}
+		if !repo.IsAssetCached(entry.Path) {
+			// 不是仓库懒加载物化的文件，不能删除
+			delete(cache.entries, entry.Path)
+			total -= entry.Size
+			continue
+		}
+
+		absPath := filepath.Join(util.DataDir, entry.Path)
+		if err := filelock.Remove(absPath); err != nil {
+			logging.LogErrorf("evict lazy cached asset [%s] failed: %s", absPath, err)
+			continue
+		}
+
+		total -= entry.Size
+		delete(cache.entries, entry.Path)
+		cache.evictions++
+		evicted = append(evicted, entry.Path)
+		logging.LogInfof("evicted lazy cached asset [%s]", entry.Path)
+	}
+	return
+}
+
+func (cache *lazyAssetCache) clear() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.ensureLoaded()
+	cache.entries = map[string]*lazyCacheEntry{}
+	cache.save()
+}
+
+// GetLazyCacheStats 获取懒加载缓存统计信息。
+func GetLazyCacheStats() (ret *LazyCacheStats) {
+	lazyCache.lock.Lock()
+	defer lazyCache.lock.Unlock()
+
+	lazyCache.ensureLoaded()
+	ret = &LazyCacheStats{
+		Enabled:      Conf.Repo.LazyLoadEnabled,
+		Budget:       Conf.Repo.LazyCacheSize * 1024 * 1024,
+		CachedCount:  len(lazyCache.entries),
+		Hits:         lazyCache.hits,
+		Misses:       lazyCache.misses,
+		PendingLoads: len(lazyCache.pending),
+		Evictions:    lazyCache.evictions,
+	}
+	for _, entry := range lazyCache.entries {
+		ret.CachedBytes += entry.Size
+	}
+	if total := ret.Hits + ret.Misses; 0 < total {
+		ret.HitRate = float64(ret.Hits) / float64(total)
+	}
+	return
+}
+
+// PrefetchDocAssets 预取文档引用的所有资源文件。
+func PrefetchDocAssets(rootID string) {
+	if !Conf.Repo.LazyLoadEnabled || !Conf.Repo.LazyPrefetchEnabled {
+		return
+	}
+
+	assets, err := DocAssets(rootID)
+	if err != nil {
+		return
+	}
+
+	for _, asset := range assets {
+		if !strings.HasPrefix(asset, "assets/") {
+			continue
+		}
+		if idx := strings.Index(asset, "?"); 0 < idx {
+			asset = asset[:idx]
+		}
+		if filelock.IsExist(filepath.Join(util.DataDir, asset)) {
+			continue
+		}
+
+		lazyCache.prefetch(asset)
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/task"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -113,6 +114,11 @@ func RemoveBox(boxID string) (err error) {
 		return errors.New(fmt.Sprintf("can not remove [%s] caused by it is a reserved file", boxID))
 	}
 
+	if filesys.IsBoxLocked(boxID) {
+		err = filesys.ErrBoxLocked
+		return
+	}
+
 	FlushTxQueue()
 	isUserGuide := IsUserGuide(boxID)
 	createDocLock.Lock()
@@ -185,6 +191,11 @@ func Mount(boxID string) (alreadyMount bool, err error) {
 	boxLock.Store(boxID, true)
 	defer boxLock.Delete(boxID)
 
+	if filesys.IsBoxLocked(boxID) {
+		err = filesys.ErrBoxLocked
+		return
+	}
+
 	FlushTxQueue()
 	isUserGuide := IsUserGuide(boxID)
 
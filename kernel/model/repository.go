@@ -54,6 +54,7 @@ import (
 	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/task"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -424,6 +425,11 @@ func parseTitleInSnapshot(fileID string, repo *dejavu.Repo, luteEngine *lute.Lut
 			return
 		}
 
+		if data, err = filesys.DecryptTreeData(data); err != nil {
+			logging.LogErrorf("decrypt file [%s] failed: %s", fileID, err)
+			return
+		}
+
 		var tree *parse.Tree
 		tree, err = dataparser.ParseJSONWithoutFix(data, luteEngine.ParseOptions)
 		if err != nil {
@@ -438,6 +444,9 @@ func parseTitleInSnapshot(fileID string, repo *dejavu.Repo, luteEngine *lute.Lut
 
 func parseTreeInSnapshot(data []byte, luteEngine *lute.Lute) (isLargeDoc bool, tree *parse.Tree, err error) {
 	isLargeDoc = 1024*1024*1 <= len(data)
+	if data, err = filesys.DecryptTreeData(data); err != nil {
+		return
+	}
 	tree, err = dataparser.ParseJSONWithoutFix(data, luteEngine.ParseOptions)
 	if err != nil {
 		return
@@ -796,7 +805,7 @@ func DownloadCloudSnapshot(tag, id string) (err error) {
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -838,7 +847,7 @@ func UploadCloudSnapshot(tag, id string) (err error) {
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -884,7 +893,7 @@ func RemoveCloudRepoTag(tag string) (err error) {
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -916,7 +925,7 @@ func GetCloudRepoTagSnapshots() (ret []*dejavu.Log, err error) {
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -952,7 +961,7 @@ func GetCloudRepoSnapshots(page int) (ret []*dejavu.Log, pageCount, totalCount i
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -1122,7 +1131,7 @@ func syncRepoDownload() (err error) {
 		return
 	}
 
-	repo, err := newRepository()
+	repo, err := newSyncRepository()
 	if err != nil {
 		planSyncAfter(fixSyncInterval)
 
@@ -1172,6 +1181,7 @@ func syncRepoDownload() (err error) {
 	}
 
 	util.PushStatusBar(fmt.Sprintf(Conf.Language(149), elapsed.Seconds()))
+	recordSyncedIndex(repo)
 	Conf.Sync.Synced = util.CurrentTimeMillis()
 	msg := fmt.Sprintf(Conf.Language(150), trafficStat.UploadFileCount, trafficStat.DownloadFileCount, trafficStat.UploadChunkCount, trafficStat.DownloadChunkCount, humanize.BytesCustomCeil(uint64(trafficStat.UploadBytes), 2), humanize.BytesCustomFloor(uint64(trafficStat.DownloadBytes), 2))
 	Conf.Sync.Stat = msg
@@ -1194,7 +1204,7 @@ func syncRepoUpload() (err error) {
 		return
 	}
 
-	repo, err := newRepository()
+	repo, err := newSyncRepository()
 	if err != nil {
 		planSyncAfter(fixSyncInterval)
 
@@ -1244,6 +1254,7 @@ func syncRepoUpload() (err error) {
 	}
 
 	util.PushStatusBar(fmt.Sprintf(Conf.Language(149), elapsed.Seconds()))
+	recordSyncedIndex(repo)
 	Conf.Sync.Synced = util.CurrentTimeMillis()
 	msg := fmt.Sprintf(Conf.Language(150), trafficStat.UploadFileCount, trafficStat.DownloadFileCount, trafficStat.UploadChunkCount, trafficStat.DownloadChunkCount, humanize.BytesCustomCeil(uint64(trafficStat.UploadBytes), 2), humanize.BytesCustomCeil(uint64(trafficStat.DownloadBytes), 2))
 	Conf.Sync.Stat = msg
@@ -1269,7 +1280,7 @@ func bootSyncRepo() (err error) {
 		return
 	}
 
-	repo, err := newRepository()
+	repo, err := newSyncRepository()
 	if err != nil {
 		autoSyncErrCount++
 		planSyncAfter(fixSyncInterval)
@@ -1411,7 +1422,7 @@ func syncRepo(exit, byHand bool) (dataChanged bool, err error) {
 		return
 	}
 
-	repo, err := newRepository()
+	repo, err := newSyncRepository()
 	if err != nil {
 		autoSyncErrCount++
 		planSyncAfter(fixSyncInterval)
@@ -1476,6 +1487,7 @@ func syncRepo(exit, byHand bool) (dataChanged bool, err error) {
 	dataChanged = nil == beforeIndex || beforeIndex.ID != afterIndex.ID || mergeResult.DataChanged()
 
 	util.PushStatusBar(fmt.Sprintf(Conf.Language(149), elapsed.Seconds()))
+	recordSyncedIndex(repo)
 	Conf.Sync.Synced = util.CurrentTimeMillis()
 	msg := fmt.Sprintf(Conf.Language(150), trafficStat.UploadFileCount, trafficStat.DownloadFileCount, trafficStat.UploadChunkCount, trafficStat.DownloadChunkCount, humanize.BytesCustomCeil(uint64(trafficStat.UploadBytes), 2), humanize.BytesCustomCeil(uint64(trafficStat.DownloadBytes), 2))
 	Conf.Sync.Stat = msg
@@ -1646,6 +1658,8 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 		}
 	}
 
+	appendSyncChange(upserts, removes)
+
 	if needReloadFlashcard {
 		LoadFlashcards()
 	}
@@ -1830,14 +1844,38 @@ func newRepository() (ret *dejavu.Repo, err error) {
 		return
 	}
 
-	var cloudRepo cloud.Cloud
-	switch Conf.Sync.Provider {
+	cloudRepo, err := newCloudRepo(Conf.Sync, cloudConf)
+	if err != nil {
+		return
+	}
+	return newRepositoryWithCloud(cloudRepo)
+}
+
+// newSyncRepository 构造云端同步使用的仓库，在同步忽略规则的基础上排除不参与同步的笔记本。
+//
+// 笔记本同步方式只影响云端同步，本地快照和备份目标仍然包含所有笔记本。
+func newSyncRepository() (ret *dejavu.Repo, err error) {
+	cloudConf, err := buildCloudConf()
+	if err != nil {
+		return
+	}
+
+	cloudRepo, err := newCloudRepo(Conf.Sync, cloudConf)
+	if err != nil {
+		return
+	}
+	return newRepositoryWithCloud(cloudRepo, getBoxSyncIgnoreLines()...)
+}
+
+// newCloudRepo 根据同步配置中的云端存储服务提供者构造云端仓库。
+func newCloudRepo(syncConf *conf.Sync, cloudConf *cloud.Conf) (ret cloud.Cloud, err error) {
+	switch syncConf.Provider {
 	case conf.ProviderSiYuan:
-		cloudRepo = cloud.NewSiYuan(&cloud.BaseCloud{Conf: cloudConf})
+		ret = cloud.NewSiYuan(&cloud.BaseCloud{Conf: cloudConf})
 	case conf.ProviderS3:
 		s3HTTPClient := &http.Client{Transport: httpclient.NewTransport(cloudConf.S3.SkipTlsVerify)}
 		s3HTTPClient.Timeout = time.Duration(cloudConf.S3.Timeout) * time.Second
-		cloudRepo = cloud.NewS3(&cloud.BaseCloud{Conf: cloudConf}, s3HTTPClient)
+		ret = cloud.NewS3(&cloud.BaseCloud{Conf: cloudConf}, s3HTTPClient)
 	case conf.ProviderWebDAV:
 		webdavClient := gowebdav.NewClient(cloudConf.WebDAV.Endpoint, cloudConf.WebDAV.Username, cloudConf.WebDAV.Password)
 		a := cloudConf.WebDAV.Username + ":" + cloudConf.WebDAV.Password
@@ -1846,17 +1884,27 @@ func newRepository() (ret *dejavu.Repo, err error) {
 		webdavClient.SetHeader("User-Agent", util.UserAgent)
 		webdavClient.SetTimeout(time.Duration(cloudConf.WebDAV.Timeout) * time.Second)
 		webdavClient.SetTransport(httpclient.NewTransport(cloudConf.WebDAV.SkipTlsVerify))
-		cloudRepo = cloud.NewWebDAV(&cloud.BaseCloud{Conf: cloudConf}, webdavClient)
+		ret = cloud.NewWebDAV(&cloud.BaseCloud{Conf: cloudConf}, webdavClient)
 	case conf.ProviderLocal:
-		cloudRepo = cloud.NewLocal(&cloud.BaseCloud{Conf: cloudConf})
+		ret = cloud.NewLocal(&cloud.BaseCloud{Conf: cloudConf})
+	case conf.ProviderSFTP:
+		ret = NewSFTPCloud(&cloud.BaseCloud{Conf: cloudConf}, syncConf.SFTP)
 	default:
-		err = fmt.Errorf("unknown cloud provider [%d]", Conf.Sync.Provider)
-		return
+		err = fmt.Errorf("unknown cloud provider [%d]", syncConf.Provider)
 	}
+	return
+}
 
+func newRepositoryWithCloud(cloudRepo cloud.Cloud, extraIgnoreLines ...string) (ret *dejavu.Repo, err error) {
+	return newRepositoryWithCloudInDir(util.RepoDir, cloudRepo, extraIgnoreLines...)
+}
+
+// newRepositoryWithCloudInDir 使用指定的本地仓库目录构造数据仓库，局域网同步为每个局域网仓库使用单独的本地仓库。
+func newRepositoryWithCloudInDir(repoDir string, cloudRepo cloud.Cloud, extraIgnoreLines ...string) (ret *dejavu.Repo, err error) {
 	ignoreLines := getSyncIgnoreLines()
 	ignoreLines = append(ignoreLines, "/.siyuan/conf.json") // 忽略旧版同步配置
-	ret, err = dejavu.NewRepoWithLazyLoad(util.DataDir, util.RepoDir, util.HistoryDir, util.TempDir, Conf.System.ID, Conf.System.Name, Conf.System.OS, Conf.Repo.Key, ignoreLines, cloudRepo, Conf.Repo.LazyLoadEnabled)
+	ignoreLines = append(ignoreLines, extraIgnoreLines...)
+	ret, err = dejavu.NewRepoWithLazyLoad(util.DataDir, repoDir, util.HistoryDir, util.TempDir, Conf.System.ID, Conf.System.Name, Conf.System.OS, Conf.Repo.Key, ignoreLines, cloudRepo, Conf.Repo.LazyLoadEnabled)
 	if err != nil {
 		logging.LogErrorf("init data repo failed: %s", err)
 		return
@@ -2092,9 +2140,13 @@ func buildCloudConf() (ret *cloud.Conf, err error) {
 		Conf.Sync.CloudName = "main"
 		Conf.Save()
 	}
+	return buildCloudConfBySync(Conf.Sync)
+}
 
+// buildCloudConfBySync 根据同步配置构造云端仓库配置。
+func buildCloudConfBySync(syncConf *conf.Sync) (ret *cloud.Conf, err error) {
 	userId, token, availableSize := "0", "", int64(1024*1024*1024*1024*2)
-	if nil != Conf.User && conf.ProviderSiYuan == Conf.Sync.Provider {
+	if nil != Conf.User && conf.ProviderSiYuan == syncConf.Provider {
 		u := Conf.GetUser()
 		userId = u.UserId
 		token = u.UserToken
@@ -2102,45 +2154,47 @@ func buildCloudConf() (ret *cloud.Conf, err error) {
 	}
 
 	ret = &cloud.Conf{
-		Dir:           Conf.Sync.CloudName,
+		Dir:           syncConf.CloudName,
 		UserID:        userId,
 		Token:         token,
 		AvailableSize: availableSize,
 		Server:        util.GetCloudServer(),
 	}
 
-	switch Conf.Sync.Provider {
+	switch syncConf.Provider {
 	case conf.ProviderSiYuan:
 		ret.Endpoint = util.GetCloudSyncServer()
 	case conf.ProviderS3:
 		ret.S3 = &cloud.ConfS3{
-			Endpoint:       Conf.Sync.S3.Endpoint,
-			AccessKey:      Conf.Sync.S3.AccessKey,
-			SecretKey:      Conf.Sync.S3.SecretKey,
-			Bucket:         Conf.Sync.S3.Bucket,
-			Region:         Conf.Sync.S3.Region,
-			PathStyle:      Conf.Sync.S3.PathStyle,
-			SkipTlsVerify:  Conf.Sync.S3.SkipTlsVerify,
-			Timeout:        Conf.Sync.S3.Timeout,
-			ConcurrentReqs: Conf.Sync.S3.ConcurrentReqs,
+			Endpoint:       syncConf.S3.Endpoint,
+			AccessKey:      syncConf.S3.AccessKey,
+			SecretKey:      syncConf.S3.SecretKey,
+			Bucket:         syncConf.S3.Bucket,
+			Region:         syncConf.S3.Region,
+			PathStyle:      syncConf.S3.PathStyle,
+			SkipTlsVerify:  syncConf.S3.SkipTlsVerify,
+			Timeout:        syncConf.S3.Timeout,
+			ConcurrentReqs: syncConf.S3.ConcurrentReqs,
 		}
 	case conf.ProviderWebDAV:
 		ret.WebDAV = &cloud.ConfWebDAV{
-			Endpoint:       Conf.Sync.WebDAV.Endpoint,
-			Username:       Conf.Sync.WebDAV.Username,
-			Password:       Conf.Sync.WebDAV.Password,
-			SkipTlsVerify:  Conf.Sync.WebDAV.SkipTlsVerify,
-			Timeout:        Conf.Sync.WebDAV.Timeout,
-			ConcurrentReqs: Conf.Sync.WebDAV.ConcurrentReqs,
+			Endpoint:       syncConf.WebDAV.Endpoint,
+			Username:       syncConf.WebDAV.Username,
+			Password:       syncConf.WebDAV.Password,
+			SkipTlsVerify:  syncConf.WebDAV.SkipTlsVerify,
+			Timeout:        syncConf.WebDAV.Timeout,
+			ConcurrentReqs: syncConf.WebDAV.ConcurrentReqs,
 		}
 	case conf.ProviderLocal:
 		ret.Local = &cloud.ConfLocal{
-			Endpoint:       Conf.Sync.Local.Endpoint,
-			Timeout:        Conf.Sync.Local.Timeout,
-			ConcurrentReqs: Conf.Sync.Local.ConcurrentReqs,
+			Endpoint:       syncConf.Local.Endpoint,
+			Timeout:        syncConf.Local.Timeout,
+			ConcurrentReqs: syncConf.Local.ConcurrentReqs,
 		}
+	case conf.ProviderSFTP:
+		// SFTP 配置由 SFTPCloud 直接持有
 	default:
-		err = fmt.Errorf("invalid provider [%d]", Conf.Sync.Provider)
+		err = fmt.Errorf("invalid provider [%d]", syncConf.Provider)
 		return
 	}
 	return
@@ -2242,17 +2296,12 @@ func pushReloadPlugin(upsertPluginSet, removePluginNameSet *hashset.Set) {
 
 // LoadAssetOnDemand 按需加载指定的资源文件
 func LoadAssetOnDemand(assetPath string) error {
-	repo, err := newRepository()
-	if err != nil {
-		return fmt.Errorf("repository not available: %w", err)
-	}
-
-	return repo.LoadAssetOnDemand(assetPath)
+	return lazyCache.load(assetPath)
 }
 
 // IsAssetCached 检查资源是否已缓存
 func IsAssetCached(assetPath string) bool {
-	repo, err := newRepository()
+	repo, err := getLazyRepository()
 	if err != nil {
 		return false
 	}
@@ -2262,17 +2311,21 @@ func IsAssetCached(assetPath string) bool {
 
 // ClearLazyCache 清理懒加载缓存
 func ClearLazyCache() error {
-	repo, err := newRepository()
+	repo, err := getLazyRepository()
 	if err != nil {
 		return fmt.Errorf("repository not available: %w", err)
 	}
 
-	return repo.ClearLazyCache()
+	if err = repo.ClearLazyCache(); err != nil {
+		return err
+	}
+	lazyCache.clear()
+	return nil
 }
 
 // RepairLazyDataConsistency 修复懒加载数据一致性问题
 func RepairLazyDataConsistency() (int, error) {
-	repo, err := newRepository()
+	repo, err := getLazyRepository()
 	if err != nil {
 		return 0, fmt.Errorf("repository not available: %w", err)
 	}
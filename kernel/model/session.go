@@ -371,6 +371,17 @@ func CheckAuth(c *gin.Context) {
 	c.Next()
 }
 
+// GetSessionAuthor 返回请求会话对应的作者名，管理员会话对应工作空间所有者，使用其账号用户名，其他会话返回空。
+func GetSessionAuthor(c *gin.Context) string {
+	if !IsAdminRoleContext(c) {
+		return ""
+	}
+	if user := Conf.GetUser(); nil != user {
+		return user.UserName
+	}
+	return ""
+}
+
 func CheckAdminRole(c *gin.Context) {
 	if IsAdminRoleContext(c) {
 		c.Next()
@@ -464,6 +475,7 @@ func ControlConcurrency(c *gin.Context) {
 		strings.HasPrefix(reqPath, "/api/query/") ||
 		strings.HasPrefix(reqPath, "/api/search/") ||
 		strings.HasPrefix(reqPath, "/api/network/") ||
+		strings.HasPrefix(reqPath, "/api/lan/") ||
 		strings.HasPrefix(reqPath, "/api/broadcast/") ||
 		strings.HasPrefix(reqPath, "/es/") {
 		c.Next()
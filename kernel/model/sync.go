@@ -19,10 +19,13 @@ package model
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -252,7 +255,7 @@ func checkSync(boot, exit, byHand bool) bool {
 		if !IsSubscriber() {
 			return false
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP:
 		if !IsPaidUser() {
 			return false
 		}
@@ -432,15 +435,7 @@ func SetSyncProvider(provider int) (err error) {
 }
 
 func SetSyncProviderS3(s3 *conf.S3) (err error) {
-	s3.Endpoint = strings.TrimSpace(s3.Endpoint)
-	s3.Endpoint = util.NormalizeEndpoint(s3.Endpoint)
-	s3.AccessKey = strings.TrimSpace(s3.AccessKey)
-	s3.SecretKey = strings.TrimSpace(s3.SecretKey)
-	s3.Bucket = strings.TrimSpace(s3.Bucket)
-	s3.Region = strings.TrimSpace(s3.Region)
-	s3.Timeout = util.NormalizeTimeout(s3.Timeout)
-	s3.ConcurrentReqs = util.NormalizeConcurrentReqs(s3.ConcurrentReqs, conf.ProviderS3)
-
+	normalizeSyncProviderS3(s3)
 	if !cloud.IsValidCloudDirName(s3.Bucket) {
 		util.PushErrMsg(Conf.Language(37), 5000)
 		return
@@ -452,6 +447,47 @@ func SetSyncProviderS3(s3 *conf.S3) (err error) {
 }
 
 func SetSyncProviderWebDAV(webdav *conf.WebDAV) (err error) {
+	if err = normalizeSyncProviderWebDAV(webdav); err != nil {
+		return
+	}
+
+	Conf.Sync.WebDAV = webdav
+	Conf.Save()
+	return
+}
+
+func SetSyncProviderLocal(local *conf.Local) (err error) {
+	if err = normalizeSyncProviderLocal(local); err != nil {
+		return
+	}
+
+	Conf.Sync.Local = local
+	Conf.Save()
+	return
+}
+
+func SetSyncProviderSFTP(sftp *conf.SFTP) (err error) {
+	if err = normalizeSyncProviderSFTP(sftp); err != nil {
+		return
+	}
+
+	Conf.Sync.SFTP = sftp
+	Conf.Save()
+	return
+}
+
+func normalizeSyncProviderS3(s3 *conf.S3) {
+	s3.Endpoint = strings.TrimSpace(s3.Endpoint)
+	s3.Endpoint = util.NormalizeEndpoint(s3.Endpoint)
+	s3.AccessKey = strings.TrimSpace(s3.AccessKey)
+	s3.SecretKey = strings.TrimSpace(s3.SecretKey)
+	s3.Bucket = strings.TrimSpace(s3.Bucket)
+	s3.Region = strings.TrimSpace(s3.Region)
+	s3.Timeout = util.NormalizeTimeout(s3.Timeout)
+	s3.ConcurrentReqs = util.NormalizeConcurrentReqs(s3.ConcurrentReqs, conf.ProviderS3)
+}
+
+func normalizeSyncProviderWebDAV(webdav *conf.WebDAV) (err error) {
 	webdav.Endpoint = strings.TrimSpace(webdav.Endpoint)
 	webdav.Endpoint = util.NormalizeEndpoint(webdav.Endpoint)
 
@@ -465,13 +501,10 @@ func SetSyncProviderWebDAV(webdav *conf.WebDAV) (err error) {
 	webdav.Password = strings.TrimSpace(webdav.Password)
 	webdav.Timeout = util.NormalizeTimeout(webdav.Timeout)
 	webdav.ConcurrentReqs = util.NormalizeConcurrentReqs(webdav.ConcurrentReqs, conf.ProviderWebDAV)
-
-	Conf.Sync.WebDAV = webdav
-	Conf.Save()
 	return
 }
 
-func SetSyncProviderLocal(local *conf.Local) (err error) {
+func normalizeSyncProviderLocal(local *conf.Local) (err error) {
 	local.Endpoint = strings.TrimSpace(local.Endpoint)
 	local.Endpoint = util.NormalizeLocalPath(local.Endpoint)
 
@@ -504,9 +537,35 @@ func SetSyncProviderLocal(local *conf.Local) (err error) {
 
 	local.Timeout = util.NormalizeTimeout(local.Timeout)
 	local.ConcurrentReqs = util.NormalizeConcurrentReqs(local.ConcurrentReqs, conf.ProviderLocal)
+	return
+}
 
-	Conf.Sync.Local = local
-	Conf.Save()
+func normalizeSyncProviderSFTP(sftp *conf.SFTP) (err error) {
+	sftp.Host = strings.TrimSpace(sftp.Host)
+	sftp.Username = strings.TrimSpace(sftp.Username)
+	sftp.HostKey = strings.TrimSpace(sftp.HostKey)
+	// 相对路径相对于登录目录，为空时直接使用登录目录
+	sftp.Path = strings.ReplaceAll(strings.TrimSpace(sftp.Path), "\\", "/")
+	if "" != sftp.Path {
+		if sftp.Path = path.Clean(sftp.Path); "." == sftp.Path {
+			sftp.Path = ""
+		}
+	}
+	if 1 > sftp.Port || 65535 < sftp.Port {
+		sftp.Port = 22
+	}
+
+	if "" == sftp.Host || "" == sftp.Username {
+		err = errors.New(fmt.Sprintf(Conf.Language(77), "host and username are required"))
+		return
+	}
+	if "" == sftp.Password && "" == strings.TrimSpace(sftp.PrivateKey) {
+		err = errors.New(fmt.Sprintf(Conf.Language(77), "password or private key is required"))
+		return
+	}
+
+	sftp.Timeout = util.NormalizeTimeout(sftp.Timeout)
+	sftp.ConcurrentReqs = util.NormalizeConcurrentReqs(sftp.ConcurrentReqs, conf.ProviderSFTP)
 	return
 }
 
@@ -517,7 +576,7 @@ var (
 
 func CreateCloudSyncDir(name string) (err error) {
 	switch Conf.Sync.Provider {
-	case conf.ProviderSiYuan, conf.ProviderLocal:
+	case conf.ProviderSiYuan, conf.ProviderLocal, conf.ProviderSFTP:
 		break
 	default:
 		err = errors.New(Conf.Language(131))
@@ -545,7 +604,7 @@ func CreateCloudSyncDir(name string) (err error) {
 
 func RemoveCloudSyncDir(name string) (err error) {
 	switch Conf.Sync.Provider {
-	case conf.ProviderSiYuan, conf.ProviderLocal:
+	case conf.ProviderSiYuan, conf.ProviderLocal, conf.ProviderSFTP:
 		break
 	default:
 		err = errors.New(Conf.Language(131))
@@ -733,6 +792,9 @@ func isProviderOnline(byHand bool) (ret bool) {
 	case conf.ProviderLocal:
 		checkURL = "file://" + Conf.Sync.Local.Endpoint
 		timeout = Conf.Sync.Local.Timeout * 1000
+	case conf.ProviderSFTP:
+		checkURL = "sftp://" + net.JoinHostPort(Conf.Sync.SFTP.Host, strconv.Itoa(Conf.Sync.SFTP.Port))
+		timeout = Conf.Sync.SFTP.Timeout * 1000
 	default:
 		logging.LogWarnf("unknown provider: %d", Conf.Sync.Provider)
 		return false
@@ -0,0 +1,354 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 同步块：属性 custom-sync-block 值相同的块属于同一个同步块组，它们可以位于不同文档中。
+// 编辑任一实例后通过事务将内容同步到其他实例；删除某个实例不影响其他实例。
+
+const SyncedBlockAttr = "custom-sync-block"
+
+// CreateSyncedBlock 将块标记为同步块，返回同步块组 ID，已经是同步块的话直接返回其组 ID。
+func CreateSyncedBlock(id string) (syncID string, err error) {
+	tree, err := LoadTreeByBlockID(id)
+	if err != nil {
+		return
+	}
+	node := treenode.GetNodeInTree(tree, id)
+	if nil == node {
+		err = errors.New(fmt.Sprintf(Conf.Language(15), id))
+		return
+	}
+	if ast.NodeDocument == node.Type {
+		err = errors.New("document can not be a synced block")
+		return
+	}
+
+	if syncID = node.IALAttr(SyncedBlockAttr); "" != syncID {
+		return
+	}
+	syncID = ast.NewNodeID()
+	err = setNodeAttrs(node, tree, map[string]string{SyncedBlockAttr: syncID})
+	return
+}
+
+// InsertSyncedBlock 在指定位置插入同步块 id 的一个新实例。
+func InsertSyncedBlock(id, parentID, previousID, nextID string) (ret *Transaction, err error) {
+	if _, err = CreateSyncedBlock(id); err != nil {
+		return
+	}
+
+	FlushTxQueue()
+	tree, err := LoadTreeByBlockID(id)
+	if err != nil {
+		return
+	}
+	node := treenode.GetNodeInTree(tree, id)
+	if nil == node {
+		err = errors.New(fmt.Sprintf(Conf.Language(15), id))
+		return
+	}
+
+	luteEngine := util.NewLute()
+	instance := cloneSyncedBlock(node, nil, ast.NewNodeID(), luteEngine)
+	if nil == instance {
+		err = errors.New("clone synced block failed")
+		return
+	}
+
+	ret = &Transaction{
+		skipSyncedBlockPropagation: true,
+		DoOperations:               []*Operation{{Action: "insert", ID: instance.ID, ParentID: parentID, PreviousID: previousID, NextID: nextID, Data: luteEngine.RenderNodeBlockDOM(instance)}},
+		UndoOperations:             []*Operation{{Action: "delete", ID: instance.ID}},
+	}
+	transactions := []*Transaction{ret}
+	PerformTransactions(&transactions)
+	FlushTxQueue()
+	return
+}
+
+// UnsyncBlock 将块从同步块组中移除，块内容保留，其他实例不受影响。
+func UnsyncBlock(id string) (err error) {
+	return SetBlockAttrs(id, map[string]string{SyncedBlockAttr: ""})
+}
+
+// GetSyncedBlocks 返回块所在同步块组的所有实例。
+func GetSyncedBlocks(id string) (ret []*Block) {
+	ret = []*Block{}
+	syncID := sql.GetBlockAttrs(id)[SyncedBlockAttr]
+	if "" == syncID {
+		return
+	}
+
+	sql.FlushQueue()
+	sqlBlocks := sql.GetBlocks(sql.QueryBlockIDsByAttr(SyncedBlockAttr, syncID))
+	var blocks []*sql.Block
+	for _, sqlBlock := range sqlBlocks {
+		if nil != sqlBlock {
+			blocks = append(blocks, sqlBlock)
+		}
+	}
+	ret = fromSQLBlocks(&blocks, "", 36)
+	return
+}
+
+// collectSyncedBlockSources 收集事务中内容发生变化的同步块，返回 {同步块组 ID: 被编辑的实例 ID}。
+func (tx *Transaction) collectSyncedBlockSources() (ret map[string]string) {
+	ret = map[string]string{}
+	if tx.skipSyncedBlockPropagation {
+		return
+	}
+
+	getNode := func(id string) *ast.Node {
+		if "" == id {
+			return nil
+		}
+		for _, tree := range tx.trees {
+			if node := treenode.GetNodeInTree(tree, id); nil != node {
+				return node
+			}
+		}
+		return nil
+	}
+
+	var changed []*ast.Node
+	for _, op := range tx.DoOperations {
+		switch op.Action {
+		case "update", "insert", "move", "append", "appendInsert", "prependInsert":
+			changed = append(changed, getNode(op.ID), getNode(op.ParentID))
+		}
+	}
+	for _, op := range tx.UndoOperations {
+		// 删除和移动操作需要通过撤销操作中的原始位置找到变化的同步块
+		switch op.Action {
+		case "insert", "move":
+			if parent := getNode(op.ParentID); nil != parent {
+				changed = append(changed, parent)
+			} else if previous := getNode(op.PreviousID); nil != previous {
+				changed = append(changed, previous.Parent)
+			} else if next := getNode(op.NextID); nil != next {
+				changed = append(changed, next.Parent)
+			}
+		}
+	}
+
+	for _, node := range changed {
+		for n := node; nil != n && ast.NodeDocument != n.Type; n = n.Parent {
+			if syncID := n.IALAttr(SyncedBlockAttr); "" != syncID {
+				ret[syncID] = n.ID
+				break
+			}
+		}
+	}
+	return
+}
+
+// propagateSyncedBlocks 将同步块实例的内容同步到同组的其他实例。
+func propagateSyncedBlocks(sources map[string]string) {
+	defer logging.Recover()
+
+	FlushTxQueue()
+	sql.FlushQueue()
+	luteEngine := util.NewLute()
+	for syncID, srcID := range sources {
+		srcTree, err := LoadTreeByBlockID(srcID)
+		if err != nil {
+			continue
+		}
+		src := treenode.GetNodeInTree(srcTree, srcID)
+		if nil == src || syncID != src.IALAttr(SyncedBlockAttr) {
+			continue
+		}
+
+		tx := &Transaction{skipSyncedBlockPropagation: true}
+		for _, id := range sql.QueryBlockIDsByAttr(SyncedBlockAttr, syncID) {
+			if id == srcID {
+				continue
+			}
+			bt := treenode.GetBlockTree(id)
+			if nil == bt || bt.RootID == id {
+				continue
+			}
+			targetTree, loadErr := LoadTreeByBlockID(id)
+			if nil != loadErr {
+				continue
+			}
+			target := treenode.GetNodeInTree(targetTree, id)
+			if nil == target {
+				continue
+			}
+
+			instance := cloneSyncedBlock(src, target, id, luteEngine)
+			if nil == instance {
+				continue
+			}
+			tx.DoOperations = append(tx.DoOperations, &Operation{Action: "update", ID: id, Data: luteEngine.RenderNodeBlockDOM(instance)})
+		}
+		if 1 > len(tx.DoOperations) {
+			continue
+		}
+
+		transactions := []*Transaction{tx}
+		PerformTransactions(&transactions)
+		FlushTxQueue()
+
+		evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+		evt.Data = transactions
+		util.PushEvent(evt)
+	}
+}
+
+// cloneSyncedBlock 复制同步块实例的内容，根块使用 id。target 是被更新的实例，为 nil 时表示新建实例。
+//
+// 只同步内容：根块保留 target 自身的属性，子块尽量复用 target 中对应子块的 ID，这样子块上的引用不会因为同步而失效，
+// 见 matchSyncedBlockChildren。
+func cloneSyncedBlock(src, target *ast.Node, id string, luteEngine *lute.Lute) (ret *ast.Node) {
+	subTree := luteEngine.BlockDOM2Tree(luteEngine.RenderNodeBlockDOM(src))
+	if nil == subTree || nil == subTree.Root.FirstChild {
+		return
+	}
+	ret = subTree.Root.FirstChild
+	if ast.NodeListItem == src.Type && ast.NodeList == ret.Type {
+		ret = ret.FirstChild
+	}
+	if nil == ret {
+		return
+	}
+
+	matched := matchSyncedBlockChildren(ret, target)
+	ast.Walk(ret, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || n == ret {
+			return ast.WalkContinue
+		}
+		if matchedID := matched[n]; "" != matchedID {
+			n.ID = matchedID
+		} else {
+			n.ID = ast.NewNodeID()
+		}
+		n.SetIALAttr("id", n.ID)
+		return ast.WalkContinue
+	})
+
+	ret.ID = id
+	if nil != target {
+		ret.KramdownIAL = nil
+		for _, kv := range target.KramdownIAL {
+			ret.KramdownIAL = append(ret.KramdownIAL, []string{kv[0], kv[1]})
+		}
+	} else {
+		ret.KramdownIAL = [][]string{{"id", id}, {"updated", id[:14]}, {SyncedBlockAttr, src.IALAttr(SyncedBlockAttr)}}
+	}
+	ret.SetIALAttr("id", id)
+	return
+}
+
+// syncedBlockChild 描述同步块实例中的一个子块，path 是从实例根块开始逐层的子块序号。
+type syncedBlockChild struct {
+	node    *ast.Node
+	path    string
+	content string
+	used    bool
+}
+
+// matchSyncedBlockChildren 为复制出的实例中的子块找到 target 中对应的子块，返回子块到 target 中子块 ID 的映射。
+//
+// 先按内容匹配：类型和内容都相同的子块视为同一个子块，这样在前面插入或者删除子块后其余子块仍能对应上；
+// 剩余的子块再按结构匹配：位置和类型都相同的子块视为被编辑过的同一个子块。都匹配不上的子块使用新的 ID。
+func matchSyncedBlockChildren(clone, target *ast.Node) (ret map[*ast.Node]string) {
+	ret = map[*ast.Node]string{}
+	if nil == target {
+		return
+	}
+
+	targetChildren := syncedBlockChildren(target)
+	cloneChildren := syncedBlockChildren(clone)
+	for _, c := range cloneChildren {
+		for _, t := range targetChildren {
+			if !t.used && c.node.Type == t.node.Type && c.content == t.content {
+				t.used, c.used = true, true
+				ret[c.node] = t.node.ID
+				break
+			}
+		}
+	}
+	for _, c := range cloneChildren {
+		if c.used {
+			continue
+		}
+		for _, t := range targetChildren {
+			if !t.used && c.node.Type == t.node.Type && c.path == t.path {
+				t.used, c.used = true, true
+				ret[c.node] = t.node.ID
+				break
+			}
+		}
+	}
+	return
+}
+
+func syncedBlockChildren(root *ast.Node) (ret []*syncedBlockChild) {
+	var walk func(parent *ast.Node, parentPath string)
+	walk = func(parent *ast.Node, parentPath string) {
+		i := 0
+		for n := parent.FirstChild; nil != n; n = n.Next {
+			if !n.IsBlock() {
+				continue
+			}
+			p := parentPath + "/" + strconv.Itoa(i)
+			i++
+			ret = append(ret, &syncedBlockChild{node: n, path: p, content: n.Content()})
+			walk(n, p)
+		}
+	}
+	walk(root, "")
+	return
+}
+
+// syncedBlocksSearchFilter 返回搜索时过滤同步块重复实例的条件，同一同步块组只保留 ID 最小的实例。
+// 在查询中过滤而不是对结果去重，这样匹配数和分页都基于去重后的结果。
+func syncedBlocksSearchFilter() string {
+	return " AND id NOT IN (SELECT a.block_id FROM attributes a WHERE a.name = '" + SyncedBlockAttr + "'" +
+		" AND a.block_id > (SELECT MIN(b.block_id) FROM attributes b WHERE b.name = '" + SyncedBlockAttr + "' AND b.value = a.value))"
+}
+
+// querySyncedBlockRefs 返回指向同一同步块组中其他实例的引用，反链中将同步块视为同一个定义块。
+func querySyncedBlockRefs(id string, containChildren bool) (ret []*sql.Ref) {
+	syncID := sql.GetBlockAttrs(id)[SyncedBlockAttr]
+	if "" == syncID {
+		return
+	}
+
+	for _, instanceID := range sql.QueryBlockIDsByAttr(SyncedBlockAttr, syncID) {
+		if instanceID == id {
+			continue
+		}
+		ret = append(ret, sql.QueryRefsByDefID(instanceID, containChildren)...)
+	}
+	return
+}
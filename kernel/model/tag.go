@@ -112,6 +112,7 @@ func RemoveTag(label string) (err error) {
 	}
 
 	updateAttributeViewBlockText(updateNodes)
+	removeTagMeta(label)
 
 	sql.FlushQueue()
 	util.PushClearProgress()
@@ -211,6 +212,7 @@ func RenameTag(oldLabel, newLabel string) (err error) {
 	}
 
 	updateAttributeViewBlockText(updateNodes)
+	renameTagMeta(oldLabel, newLabel)
 
 	sql.FlushQueue()
 	util.PushClearProgress()
@@ -231,6 +233,11 @@ type Tag struct {
 	Depth    int    `json:"depth"`
 	Count    int    `json:"count"`
 
+	Color       string   `json:"color"`       // 颜色
+	Icon        string   `json:"icon"`        // 图标
+	Description string   `json:"description"` // 描述
+	Aliases     []string `json:"aliases"`     // 别名
+
 	tags Tags
 }
 
@@ -241,13 +248,15 @@ func BuildTags() (ret *Tags) {
 	sql.FlushQueue()
 
 	ret = &Tags{}
-	labels := labelTags()
+	metas := GetTagMetas()
+	labels := labelTags(metas)
 	tags := Tags{}
 	for label := range labels {
 		tags = buildTags(tags, strings.Split(label, "/"), 0)
 	}
 	appendTagChildren(&tags, labels)
 	sortTags(tags)
+	fillTagMetas(tags, metas)
 
 	var total int
 	tmp := &Tags{}
@@ -264,6 +273,31 @@ func BuildTags() (ret *Tags) {
 	return
 }
 
+func fillTagMetas(tags Tags, metas []*TagMeta) {
+	if 1 > len(metas) {
+		return
+	}
+
+	labelMetas := map[string]*TagMeta{}
+	for _, m := range metas {
+		labelMetas[m.Label] = m
+	}
+
+	var fill func(tags Tags)
+	fill = func(tags Tags) {
+		for _, tag := range tags {
+			if m := labelMetas[util.UnescapeHTML(tag.Label)]; nil != m {
+				tag.Color = m.Color
+				tag.Icon = m.Icon
+				tag.Description = m.Description
+				tag.Aliases = m.Aliases
+			}
+			fill(tag.Children)
+		}
+	}
+	fill(tags)
+}
+
 func countTag(tag *Tag, total *int) {
 	*total += 1
 	for _, child := range tag.tags {
@@ -305,9 +339,27 @@ func SearchTags(keyword string) (ret []string) {
 
 	sql.FlushQueue()
 
+	metas := GetTagMetas()
 	labels := labelBlocksByKeyword(keyword)
+	for _, m := range metas {
+		// 通过别名搜索时返回规范标签
+		for _, alias := range m.Aliases {
+			if "" != keyword && strings.Contains(strings.ToLower(alias), strings.ToLower(keyword)) {
+				labels[m.Label] = nil
+				break
+			}
+		}
+	}
+
 	keyword = strings.Join(strings.Split(keyword, " "), search.TermSep)
+	resolved := map[string]bool{}
 	for label := range labels {
+		label = resolveTagAlias(metas, label)
+		if resolved[label] {
+			continue
+		}
+		resolved[label] = true
+
 		if "" == keyword {
 			ret = append(ret, util.EscapeHTML(label))
 			continue
@@ -361,12 +413,13 @@ func labelBlocksByKeyword(keyword string) (ret map[string]TagBlocks) {
 	return
 }
 
-func labelTags() (ret map[string]Tags) {
+func labelTags(metas []*TagMeta) (ret map[string]Tags) {
 	ret = map[string]Tags{}
 
 	tagSpans := sql.QueryTagSpans("")
 	for _, tagSpan := range tagSpans {
 		label := util.UnescapeHTML(tagSpan.Content)
+		label = resolveTagAlias(metas, label) // 别名计入规范标签
 		if _, ok := ret[label]; ok {
 			ret[label] = append(ret[label], &Tag{})
 		} else {
@@ -0,0 +1,511 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// TagMeta 标签元数据，存储在 data/storage/tag.json 中。
+type TagMeta struct {
+	Label       string   `json:"label"`       // 规范标签
+	Color       string   `json:"color"`       // 颜色
+	Icon        string   `json:"icon"`        // 图标
+	Description string   `json:"description"` // 描述
+	Aliases     []string `json:"aliases"`     // 别名，在搜索和标签树中解析为规范标签
+}
+
+var tagMetasLock = sync.Mutex{}
+
+func GetTagMetas() (ret []*TagMeta) {
+	tagMetasLock.Lock()
+	defer tagMetasLock.Unlock()
+	ret, _ = getTagMetas()
+	return
+}
+
+func SetTagMeta(meta *TagMeta) (err error) {
+	meta.Label = normalizeTagLabel(meta.Label)
+	if "" == meta.Label {
+		return errors.New(Conf.Language(114))
+	}
+	if invalidChar := treenode.ContainsMarker(meta.Label); "" != invalidChar {
+		return errors.New(fmt.Sprintf(Conf.Language(112), invalidChar))
+	}
+
+	var aliases []string
+	for _, alias := range meta.Aliases {
+		alias = normalizeTagLabel(alias)
+		if "" == alias || alias == meta.Label {
+			continue
+		}
+		if invalidChar := treenode.ContainsMarker(alias); "" != invalidChar {
+			return errors.New(fmt.Sprintf(Conf.Language(112), invalidChar))
+		}
+		aliases = append(aliases, alias)
+	}
+	meta.Aliases = gulu.Str.RemoveDuplicatedElem(aliases)
+
+	tagMetasLock.Lock()
+	defer tagMetasLock.Unlock()
+
+	metas, err := getTagMetas()
+	if err != nil {
+		return
+	}
+
+	for _, m := range metas {
+		if m.Label == meta.Label {
+			continue
+		}
+
+		for _, alias := range meta.Aliases {
+			if alias == m.Label || gulu.Str.Contains(alias, m.Aliases) {
+				return fmt.Errorf("tag alias [%s] is already used by tag [%s]", alias, m.Label)
+			}
+		}
+		if gulu.Str.Contains(meta.Label, m.Aliases) {
+			return fmt.Errorf("tag [%s] is already an alias of tag [%s]", meta.Label, m.Label)
+		}
+	}
+
+	update := false
+	for i, m := range metas {
+		if m.Label == meta.Label {
+			metas[i] = meta
+			update = true
+			break
+		}
+	}
+	if !update {
+		metas = append(metas, meta)
+	}
+
+	err = setTagMetas(metas)
+	ReloadTag()
+	return
+}
+
+func RemoveTagMeta(label string) (err error) {
+	tagMetasLock.Lock()
+	defer tagMetasLock.Unlock()
+
+	metas, err := getTagMetas()
+	if err != nil {
+		return
+	}
+
+	for i, m := range metas {
+		if m.Label == label {
+			metas = append(metas[:i], metas[i+1:]...)
+			break
+		}
+	}
+
+	err = setTagMetas(metas)
+	ReloadTag()
+	return
+}
+
+// ResolveTagAlias 将别名解析为规范标签，别名的子标签也会被解析，比如 js/react 解析为 javascript/react。
+func ResolveTagAlias(label string) string {
+	return resolveTagAlias(GetTagMetas(), label)
+}
+
+func resolveTagAlias(metas []*TagMeta, label string) string {
+	for _, m := range metas {
+		for _, alias := range m.Aliases {
+			if label == alias {
+				return m.Label
+			}
+			if strings.HasPrefix(label, alias+"/") {
+				return m.Label + strings.TrimPrefix(label, alias)
+			}
+		}
+	}
+	return label
+}
+
+// tagAliasLabels 返回规范标签及其所有别名。
+func tagAliasLabels(metas []*TagMeta, label string) (ret []string) {
+	label = resolveTagAlias(metas, label)
+	ret = append(ret, label)
+	for _, m := range metas {
+		if m.Label == label {
+			ret = append(ret, m.Aliases...)
+			break
+		}
+	}
+	return
+}
+
+// tagAliasFTSQuery 将标签搜索 #label# 扩展为规范标签和所有别名的 FTS 查询。
+func tagAliasFTSQuery(query string) (ret string, ok bool) {
+	query = strings.TrimSpace(query)
+	if 3 > len(query) || !strings.HasPrefix(query, "#") || !strings.HasSuffix(query, "#") {
+		return
+	}
+
+	label := query[1 : len(query)-1]
+	labels := tagAliasLabels(GetTagMetas(), label)
+	if 2 > len(labels) {
+		return
+	}
+
+	var terms []string
+	for _, l := range labels {
+		l = strings.ReplaceAll(l, "\"", "\"\"")
+		l = strings.ReplaceAll(l, "'", "''")
+		terms = append(terms, "\"#"+l+"#\"")
+	}
+	return strings.Join(terms, " OR "), true
+}
+
+func renameTagMeta(oldLabel, newLabel string) {
+	tagMetasLock.Lock()
+	defer tagMetasLock.Unlock()
+
+	metas, err := getTagMetas()
+	if err != nil {
+		return
+	}
+
+	renameLabel := func(label string) string {
+		if label == oldLabel || strings.HasPrefix(label, oldLabel+"/") {
+			return newLabel + strings.TrimPrefix(label, oldLabel)
+		}
+		return label
+	}
+
+	changed := false
+	for _, m := range metas {
+		if renamed := renameLabel(m.Label); renamed != m.Label {
+			m.Label = renamed
+			changed = true
+		}
+
+		var aliases []string
+		for _, alias := range m.Aliases {
+			renamed := renameLabel(alias)
+			if renamed != alias {
+				changed = true
+			}
+			if renamed != m.Label {
+				aliases = append(aliases, renamed)
+			}
+		}
+		m.Aliases = gulu.Str.RemoveDuplicatedElem(aliases)
+	}
+	if changed {
+		setTagMetas(mergeDuplicatedTagMetas(metas))
+	}
+}
+
+func removeTagMeta(label string) {
+	tagMetasLock.Lock()
+	defer tagMetasLock.Unlock()
+
+	metas, err := getTagMetas()
+	if err != nil {
+		return
+	}
+
+	var tmp []*TagMeta
+	for _, m := range metas {
+		if m.Label == label || strings.HasPrefix(m.Label, label+"/") {
+			continue
+		}
+		tmp = append(tmp, m)
+	}
+	if len(tmp) != len(metas) {
+		setTagMetas(tmp)
+	}
+}
+
+// mergeTagMetas 将源标签的元数据合并到目标标签上，源标签作为目标标签的别名保留。
+func mergeTagMetas(metas []*TagMeta, sources []string, target string) (ret []*TagMeta) {
+	var targetMeta *TagMeta
+	for _, m := range metas {
+		if m.Label == target {
+			targetMeta = m
+			break
+		}
+	}
+	if nil == targetMeta {
+		targetMeta = &TagMeta{Label: target}
+		metas = append(metas, targetMeta)
+	}
+
+	for _, m := range metas {
+		if !gulu.Str.Contains(m.Label, sources) {
+			ret = append(ret, m)
+			continue
+		}
+
+		if "" == targetMeta.Color {
+			targetMeta.Color = m.Color
+		}
+		if "" == targetMeta.Icon {
+			targetMeta.Icon = m.Icon
+		}
+		if "" == targetMeta.Description {
+			targetMeta.Description = m.Description
+		}
+		targetMeta.Aliases = append(targetMeta.Aliases, m.Aliases...)
+	}
+	targetMeta.Aliases = append(targetMeta.Aliases, sources...)
+
+	var aliases []string
+	for _, alias := range targetMeta.Aliases {
+		if alias != target {
+			aliases = append(aliases, alias)
+		}
+	}
+	targetMeta.Aliases = gulu.Str.RemoveDuplicatedElem(aliases)
+	return
+}
+
+func (tx *Transaction) doSetTagMetas(operation *Operation) (ret *TxErr) {
+	var metas []*TagMeta
+	if err := gulu.JSON.UnmarshalJSON([]byte(operation.Data.(string)), &metas); err != nil {
+		logging.LogErrorf("unmarshal tag metas failed: %s", err)
+		return &TxErr{code: TxErrCodeWriteTree, msg: err.Error()}
+	}
+
+	tagMetasLock.Lock()
+	err := setTagMetas(metas)
+	tagMetasLock.Unlock()
+	if err != nil {
+		return &TxErr{code: TxErrCodeWriteTree, msg: err.Error()}
+	}
+	ReloadTag()
+	return
+}
+
+func mergeDuplicatedTagMetas(metas []*TagMeta) (ret []*TagMeta) {
+	labelMetas := map[string]*TagMeta{}
+	for _, m := range metas {
+		if existing := labelMetas[m.Label]; nil != existing {
+			existing.Aliases = gulu.Str.RemoveDuplicatedElem(append(existing.Aliases, m.Aliases...))
+			continue
+		}
+		labelMetas[m.Label] = m
+		ret = append(ret, m)
+	}
+	return
+}
+
+func normalizeTagLabel(label string) string {
+	label = strings.TrimSpace(label)
+	label = strings.TrimPrefix(label, "#")
+	label = strings.TrimSuffix(label, "#")
+	label = strings.TrimPrefix(label, "/")
+	label = strings.TrimSuffix(label, "/")
+	return strings.TrimSpace(label)
+}
+
+// MergeTags 将多个标签合并为一个标签，所有变更在一个可撤销的事务中完成。
+func MergeTags(sources []string, target string) (transaction *Transaction, err error) {
+	target = normalizeTagLabel(target)
+	if "" == target {
+		return nil, errors.New(Conf.Language(114))
+	}
+	if invalidChar := treenode.ContainsMarker(target); "" != invalidChar {
+		return nil, errors.New(fmt.Sprintf(Conf.Language(112), invalidChar))
+	}
+
+	var labels []string
+	for _, source := range sources {
+		source = normalizeTagLabel(source)
+		if "" == source || source == target {
+			continue
+		}
+		labels = append(labels, source)
+	}
+	labels = gulu.Str.RemoveDuplicatedElem(labels)
+	if 1 > len(labels) {
+		return
+	}
+
+	FlushTxQueue()
+	sql.FlushQueue()
+
+	treeBlocks := map[string][]string{}
+	for _, label := range labels {
+		for _, tag := range sql.QueryTagSpansByLabel(label) {
+			if !gulu.Str.Contains(tag.BlockID, treeBlocks[tag.RootID]) {
+				treeBlocks[tag.RootID] = append(treeBlocks[tag.RootID], tag.BlockID)
+			}
+		}
+	}
+
+	mergeLabel := func(label string) string {
+		for _, source := range labels {
+			if label == source {
+				return target
+			}
+			if strings.HasPrefix(label, source+"/") {
+				return target + strings.TrimPrefix(label, source)
+			}
+		}
+		return label
+	}
+
+	transaction = &Transaction{}
+	luteEngine := util.NewLute()
+	for treeID, blockIDs := range treeBlocks {
+		tree, loadErr := LoadTreeByBlockID(treeID)
+		if nil != loadErr {
+			logging.LogWarnf("load tree [%s] failed: %s", treeID, loadErr)
+			continue
+		}
+
+		for _, blockID := range blockIDs {
+			node := treenode.GetNodeInTree(tree, blockID)
+			if nil == node {
+				continue
+			}
+
+			if ast.NodeDocument == node.Type {
+				oldTags := node.IALAttr("tags")
+				var newTags []string
+				for _, docTag := range strings.Split(oldTags, ",") {
+					if docTag = strings.TrimSpace(docTag); "" != docTag {
+						newTags = append(newTags, mergeLabel(docTag))
+					}
+				}
+				newTagsVal := strings.Join(gulu.Str.RemoveDuplicatedElem(newTags), ",")
+				if newTagsVal == oldTags {
+					continue
+				}
+
+				doData, _ := gulu.JSON.MarshalJSON(map[string]string{"tags": newTagsVal})
+				undoData, _ := gulu.JSON.MarshalJSON(map[string]string{"tags": oldTags})
+				transaction.DoOperations = append(transaction.DoOperations, &Operation{Action: "setAttrs", ID: node.ID, Data: string(doData)})
+				transaction.UndoOperations = append(transaction.UndoOperations, &Operation{Action: "setAttrs", ID: node.ID, Data: string(undoData)})
+				continue
+			}
+
+			oldDOM := luteEngine.RenderNodeBlockDOM(node)
+			changed := false
+			ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+				if !entering || ast.NodeTextMark != n.Type || !n.IsTextMarkType("tag") {
+					return ast.WalkContinue
+				}
+
+				if merged := mergeLabel(n.TextMarkTextContent); merged != n.TextMarkTextContent {
+					n.TextMarkTextContent = merged
+					changed = true
+				}
+				return ast.WalkContinue
+			})
+			if !changed {
+				continue
+			}
+
+			newDOM := luteEngine.RenderNodeBlockDOM(node)
+			transaction.DoOperations = append(transaction.DoOperations, &Operation{Action: "update", ID: node.ID, Data: newDOM})
+			transaction.UndoOperations = append(transaction.UndoOperations, &Operation{Action: "update", ID: node.ID, Data: oldDOM})
+		}
+	}
+
+	// 标签元数据的变更放在同一个事务的最后，撤销时一起恢复
+	tagMetasLock.Lock()
+	oldMetas, err := getTagMetas()
+	tagMetasLock.Unlock()
+	if err != nil {
+		return
+	}
+	undoData, err := gulu.JSON.MarshalJSON(oldMetas)
+	if err != nil {
+		return
+	}
+	var clonedMetas []*TagMeta
+	if err = gulu.JSON.UnmarshalJSON(undoData, &clonedMetas); err != nil {
+		return
+	}
+	doData, err := gulu.JSON.MarshalJSON(mergeTagMetas(clonedMetas, labels, target))
+	if err != nil {
+		return
+	}
+	transaction.DoOperations = append(transaction.DoOperations, &Operation{Action: "setTagMetas", Data: string(doData)})
+	transaction.UndoOperations = append(transaction.UndoOperations, &Operation{Action: "setTagMetas", Data: string(undoData)})
+
+	transactions := []*Transaction{transaction}
+	PerformTransactions(&transactions)
+	FlushTxQueue()
+	ReloadTag()
+	return
+}
+
+func setTagMetas(metas []*TagMeta) (err error) {
+	dirPath := filepath.Join(util.DataDir, "storage")
+	if err = os.MkdirAll(dirPath, 0755); err != nil {
+		logging.LogErrorf("create storage [tag] dir failed: %s", err)
+		return
+	}
+
+	if nil == metas {
+		metas = []*TagMeta{}
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(metas, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal storage [tag] failed: %s", err)
+		return
+	}
+
+	lsPath := filepath.Join(dirPath, "tag.json")
+	err = filelock.WriteFile(lsPath, data)
+	if err != nil {
+		logging.LogErrorf("write storage [tag] failed: %s", err)
+		return
+	}
+	return
+}
+
+func getTagMetas() (ret []*TagMeta, err error) {
+	ret = []*TagMeta{}
+	dataPath := filepath.Join(util.DataDir, "storage/tag.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read storage [tag] failed: %s", err)
+		return
+	}
+
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal storage [tag] failed: %s", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,395 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/araddon/dateparse"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 任务元数据保存在任务列表项的块属性上，并通过 tasks 视图索引。
+
+const (
+	TaskAttrDue        = "custom-task-due"        // 截止时间
+	TaskAttrScheduled  = "custom-task-scheduled"  // 计划时间
+	TaskAttrPriority   = "custom-task-priority"   // 优先级 high/medium/low
+	TaskAttrRecurrence = "custom-task-recurrence" // 重复规则，如 daily、every 2 weeks、FREQ=MONTHLY;INTERVAL=1
+	TaskAttrNext       = "custom-task-next"       // 重复任务完成后生成的下一个任务 ID
+)
+
+// SetTaskItem 设置任务列表项的任务属性，fields 的键为 due/scheduled/priority/recurrence，值为空时移除该属性，不存在的键保持不变。
+func SetTaskItem(id string, fields map[string]string) (err error) {
+	tree, err := LoadTreeByBlockID(id)
+	if err != nil {
+		return
+	}
+	node := treenode.GetNodeInTree(tree, id)
+	if nil == node {
+		return errors.New(fmt.Sprintf(Conf.Language(15), id))
+	}
+	if !isTaskListItem(node) {
+		return errors.New("block is not a task list item")
+	}
+
+	attrs := map[string]string{}
+	for name, value := range fields {
+		value = strings.TrimSpace(value)
+		switch name {
+		case "due", "scheduled":
+			if value, err = normalizeTaskTime(value); err != nil {
+				return
+			}
+		case "priority":
+			value = strings.ToLower(value)
+			if "" != value && "high" != value && "medium" != value && "low" != value {
+				return errors.New("invalid task priority [" + value + "]")
+			}
+		case "recurrence":
+			if "" != value {
+				if _, _, ok := parseTaskRecurrence(value); !ok {
+					return errors.New("invalid task recurrence [" + value + "]")
+				}
+			}
+		default:
+			continue
+		}
+		attrs["custom-task-"+name] = value
+	}
+	if 1 > len(attrs) {
+		return
+	}
+	return SetBlockAttrs(id, attrs)
+}
+
+// QueryTaskItems 查询任务，filter 为 overdue（已过期）、today（今天到期或计划在今天）、upcoming（未来到期）或者空（所有任务）。
+func QueryTaskItems(filter, tag, box string, includeDone bool, limit int) (ret []*sql.TaskItem, err error) {
+	if 1 > limit {
+		limit = 256
+	}
+
+	var conds []string
+	var args []interface{}
+	now := time.Now()
+	today := now.Format("20060102")
+	switch filter {
+	case "overdue":
+		conds = append(conds, "'' != due AND CASE WHEN 8 = length(due) THEN due || '235959' ELSE due END < ?")
+		args = append(args, now.Format("20060102150405"))
+	case "today":
+		conds = append(conds, "(substr(due, 1, 8) = ? OR substr(scheduled, 1, 8) = ?)")
+		args = append(args, today, today)
+	case "upcoming":
+		conds = append(conds, "substr(due, 1, 8) > ?")
+		args = append(args, today)
+	case "":
+	default:
+		err = errors.New("invalid task filter [" + filter + "]")
+		return
+	}
+	if !includeDone {
+		conds = append(conds, "0 = done")
+	}
+	if "" != box {
+		conds = append(conds, "box = ?")
+		args = append(args, box)
+	}
+	if tag = strings.Trim(strings.TrimSpace(tag), "#"); "" != tag {
+		conds = append(conds, "EXISTS (SELECT 1 FROM spans s, blocks p WHERE s.block_id = p.id AND p.parent_id = tasks.id AND s.type LIKE '%tag%' AND (s.content = ? OR s.content LIKE ?))")
+		args = append(args, tag, tag+"/%")
+	}
+
+	ret = sql.QueryTaskItems(strings.Join(conds, " AND "), args, limit)
+	if nil == ret {
+		ret = []*sql.TaskItem{}
+	}
+	return
+}
+
+// collectCompletedRecurringTasks 返回本次更新中从未勾选变为勾选的重复任务列表项。
+func collectCompletedRecurringTasks(oldNode, newNode *ast.Node) (ret []string) {
+	unchecked := map[string]bool{}
+	ast.Walk(oldNode, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && isTaskListItem(n) {
+			unchecked[n.ID] = !isTaskListItemChecked(n)
+		}
+		return ast.WalkContinue
+	})
+	ast.Walk(newNode, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !isTaskListItem(n) {
+			return ast.WalkContinue
+		}
+		if unchecked[n.ID] && isTaskListItemChecked(n) && "" != n.IALAttr(TaskAttrRecurrence) {
+			ret = append(ret, n.ID)
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+// createNextTaskOccurrences 为完成的重复任务在其后插入下一个任务。
+func createNextTaskOccurrences(ids []string) {
+	defer logging.Recover()
+
+	FlushTxQueue()
+	luteEngine := util.NewLute()
+	for _, id := range ids {
+		tree, err := LoadTreeByBlockID(id)
+		if err != nil {
+			continue
+		}
+		item := treenode.GetNodeInTree(tree, id)
+		if nil == item || !isTaskListItem(item) || !isTaskListItemChecked(item) {
+			continue
+		}
+		if next := item.IALAttr(TaskAttrNext); "" != next && nil != treenode.GetBlockTree(next) {
+			// 已经生成过下一个任务，取消勾选后再次勾选不重复生成
+			continue
+		}
+
+		nextItem := newNextTaskItem(item, luteEngine)
+		if nil == nextItem {
+			continue
+		}
+
+		op := &Operation{Action: "insert", ID: nextItem.ID, PreviousID: item.ID, Data: luteEngine.RenderNodeBlockDOM(nextItem)}
+		if nil != item.Parent {
+			op.ParentID = item.Parent.ID
+		}
+		transactions := []*Transaction{{DoOperations: []*Operation{op}, UndoOperations: []*Operation{{Action: "delete", ID: nextItem.ID}}}}
+		PerformTransactions(&transactions)
+		FlushTxQueue()
+
+		evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+		evt.Data = transactions
+		util.PushEvent(evt)
+
+		if err = SetBlockAttrs(id, map[string]string{TaskAttrNext: nextItem.ID}); err != nil {
+			logging.LogWarnf("set task [%s] next occurrence failed: %s", id, err)
+		}
+	}
+}
+
+func newNextTaskItem(item *ast.Node, luteEngine *lute.Lute) (ret *ast.Node) {
+	recurrence := item.IALAttr(TaskAttrRecurrence)
+	freq, interval, ok := parseTaskRecurrence(recurrence)
+	if !ok {
+		logging.LogWarnf("invalid task [%s] recurrence [%s]", item.ID, recurrence)
+		return
+	}
+
+	due, scheduled := item.IALAttr(TaskAttrDue), item.IALAttr(TaskAttrScheduled)
+	base := due
+	if "" == base {
+		base = scheduled
+	}
+	baseTime, hasBase := parseTaskTime(base)
+	if !hasBase {
+		baseTime = time.Now()
+	}
+	// 从基准时间开始按规则递推，直到下一次时间在今天之后
+	steps := 0
+	nextTime := baseTime
+	for {
+		nextTime = addTaskRecurrence(baseTime, freq, interval*(steps+1))
+		steps++
+		if 8 == len(base) && nextTime.Format("20060102") > time.Now().Format("20060102") {
+			break
+		}
+		if 8 != len(base) && nextTime.After(time.Now()) {
+			break
+		}
+	}
+
+	// 复制整个列表项，包括子任务和备注，所有块使用新的 ID，子任务都取消勾选
+	subTree := luteEngine.BlockDOM2Tree(luteEngine.RenderNodeBlockDOM(item))
+	if nil == subTree || nil == subTree.Root.FirstChild || nil == subTree.Root.FirstChild.FirstChild {
+		return
+	}
+	ret = subTree.Root.FirstChild.FirstChild
+	if !isTaskListItem(ret) {
+		return nil
+	}
+
+	ast.Walk(ret, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() {
+			return ast.WalkContinue
+		}
+
+		n.ID = ast.NewNodeID()
+		n.SetIALAttr("id", n.ID)
+		n.SetIALAttr("updated", n.ID[:14])
+		n.RemoveIALAttr(TaskAttrNext)
+		if isTaskListItem(n) {
+			n.ListData.Checked = false
+			if nil != n.FirstChild && ast.NodeTaskListItemMarker == n.FirstChild.Type {
+				n.FirstChild.TaskListItemChecked = false
+			}
+		}
+		return ast.WalkContinue
+	})
+	ret.SetIALAttr(TaskAttrRecurrence, recurrence)
+	if priority := item.IALAttr(TaskAttrPriority); "" != priority {
+		ret.SetIALAttr(TaskAttrPriority, priority)
+	}
+	if "" != due {
+		ret.SetIALAttr(TaskAttrDue, shiftTaskTime(due, freq, interval*steps))
+	} else if !hasBase {
+		ret.SetIALAttr(TaskAttrDue, nextTime.Format("20060102"))
+	}
+	if "" != scheduled {
+		ret.SetIALAttr(TaskAttrScheduled, shiftTaskTime(scheduled, freq, interval*steps))
+	}
+	return
+}
+
+func isTaskListItem(n *ast.Node) bool {
+	return ast.NodeListItem == n.Type && nil != n.ListData && 3 == n.ListData.Typ
+}
+
+func isTaskListItemChecked(n *ast.Node) bool {
+	if nil != n.FirstChild && ast.NodeTaskListItemMarker == n.FirstChild.Type {
+		return n.FirstChild.TaskListItemChecked
+	}
+	return false
+}
+
+// parseTaskRecurrence 解析重复规则，支持 daily/weekly/monthly/yearly、every N days/weeks/months/years 和 RRULE 的 FREQ/INTERVAL。
+func parseTaskRecurrence(rule string) (freq string, interval int, ok bool) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	rule = strings.TrimPrefix(rule, "rrule:")
+	interval = 1
+	if strings.Contains(rule, "freq=") {
+		for _, part := range strings.Split(rule, ";") {
+			kv := strings.SplitN(part, "=", 2)
+			if 2 != len(kv) {
+				continue
+			}
+			switch kv[0] {
+			case "freq":
+				freq = map[string]string{"daily": "day", "weekly": "week", "monthly": "month", "yearly": "year"}[kv[1]]
+			case "interval":
+				n, err := strconv.Atoi(kv[1])
+				if nil != err || 1 > n {
+					return "", 0, false
+				}
+				interval = n
+			}
+		}
+		return freq, interval, "" != freq
+	}
+
+	switch rule {
+	case "daily":
+		return "day", 1, true
+	case "weekly":
+		return "week", 1, true
+	case "monthly":
+		return "month", 1, true
+	case "yearly":
+		return "year", 1, true
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(rule, "every "))
+	if 2 == len(fields) {
+		n, err := strconv.Atoi(fields[0])
+		if nil != err || 1 > n {
+			return
+		}
+		interval = n
+		fields = fields[1:]
+	}
+	if 1 != len(fields) {
+		return
+	}
+	freq = strings.TrimSuffix(fields[0], "s")
+	switch freq {
+	case "day", "week", "month", "year":
+		ok = true
+	}
+	return
+}
+
+func addTaskRecurrence(t time.Time, freq string, interval int) time.Time {
+	switch freq {
+	case "day":
+		return t.AddDate(0, 0, interval)
+	case "week":
+		return t.AddDate(0, 0, 7*interval)
+	case "month":
+		return t.AddDate(0, interval, 0)
+	default:
+		return t.AddDate(interval, 0, 0)
+	}
+}
+
+// normalizeTaskTime 将时间规范为 yyyyMMdd（仅日期）或者 yyyyMMddHHmmss。
+func normalizeTaskTime(value string) (ret string, err error) {
+	value = strings.TrimSpace(value)
+	if "" == value {
+		return
+	}
+	if _, ok := parseTaskTime(value); ok {
+		return value, nil
+	}
+
+	t, err := dateparse.ParseIn(value, time.Now().Location())
+	if err != nil {
+		err = errors.New("invalid task time [" + value + "]")
+		return
+	}
+	if 0 == t.Hour() && 0 == t.Minute() && 0 == t.Second() && !strings.Contains(value, ":") {
+		return t.Format("20060102"), nil
+	}
+	return t.Format("20060102150405"), nil
+}
+
+func parseTaskTime(value string) (ret time.Time, ok bool) {
+	var err error
+	switch len(value) {
+	case 8:
+		ret, err = time.ParseInLocation("20060102", value, time.Local)
+	case 14:
+		ret, err = time.ParseInLocation("20060102150405", value, time.Local)
+	default:
+		return
+	}
+	ok = nil == err
+	return
+}
+
+func shiftTaskTime(value, freq string, interval int) string {
+	t, ok := parseTaskTime(value)
+	if !ok {
+		return value
+	}
+	t = addTaskRecurrence(t, freq, interval)
+	if 8 == len(value) {
+		return t.Format("20060102")
+	}
+	return t.Format("20060102150405")
+}
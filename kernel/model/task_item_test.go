@@ -0,0 +1,167 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestParseTaskRecurrence(t *testing.T) {
+	cases := []struct {
+		rule     string
+		freq     string
+		interval int
+		ok       bool
+	}{
+		{"daily", "day", 1, true},
+		{" Weekly ", "week", 1, true},
+		{"monthly", "month", 1, true},
+		{"yearly", "year", 1, true},
+		{"every day", "day", 1, true},
+		{"every 2 weeks", "week", 2, true},
+		{"Every 3 Months", "month", 3, true},
+		{"every 1 year", "year", 1, true},
+		{"FREQ=MONTHLY;INTERVAL=2", "month", 2, true},
+		{"RRULE:FREQ=WEEKLY", "week", 1, true},
+		{"freq=daily;interval=0", "", 0, false},
+		{"FREQ=HOURLY", "", 1, false},
+		{"every 0 days", "", 1, false},
+		{"every -1 days", "", 1, false},
+		{"every two weeks", "", 1, false},
+		{"every 2 fortnights", "fortnight", 2, false},
+		{"", "", 1, false},
+	}
+	for _, c := range cases {
+		freq, interval, ok := parseTaskRecurrence(c.rule)
+		if c.ok != ok || (ok && (c.freq != freq || c.interval != interval)) {
+			t.Errorf("rule [%s] expected [%s, %d, %v], got [%s, %d, %v]", c.rule, c.freq, c.interval, c.ok, freq, interval, ok)
+		}
+	}
+}
+
+func TestNewNextTaskItem(t *testing.T) {
+	luteEngine := util.NewLute()
+	today := time.Now()
+	pastDue := today.AddDate(0, 0, -20).Format("20060102")
+	futureDue := today.AddDate(0, 0, 3).Format("20060102150405")
+
+	cases := []struct {
+		attrs             map[string]string
+		expectedDue       string
+		expectedScheduled string
+	}{
+		// 过期的任务按规则递推到今天之后
+		{map[string]string{TaskAttrRecurrence: "every 2 weeks", TaskAttrDue: pastDue, TaskAttrPriority: "high"},
+			shiftTaskTime(pastDue, "week", 4), ""},
+		{map[string]string{TaskAttrRecurrence: "weekly", TaskAttrDue: futureDue, TaskAttrScheduled: pastDue},
+			shiftTaskTime(futureDue, "week", 1), shiftTaskTime(pastDue, "week", 1)},
+		{map[string]string{TaskAttrRecurrence: "daily", TaskAttrScheduled: pastDue},
+			"", shiftTaskTime(pastDue, "day", 21)},
+		// 没有截止时间和计划时间时从今天开始计算截止时间
+		{map[string]string{TaskAttrRecurrence: "FREQ=DAILY;INTERVAL=1"},
+			today.AddDate(0, 0, 1).Format("20060102"), ""},
+	}
+	for i, c := range cases {
+		tree := parse.Parse("", []byte("* [X] Water the plants\n"), luteEngine.ParseOptions)
+		item := tree.Root.FirstChild.FirstChild
+		for k, v := range c.attrs {
+			item.SetIALAttr(k, v)
+		}
+
+		next := newNextTaskItem(item, luteEngine)
+		if nil == next {
+			t.Fatalf("case [%d] expected next task item", i)
+		}
+		if !isTaskListItem(next) || isTaskListItemChecked(next) {
+			t.Fatalf("case [%d] expected an unchecked task list item", i)
+		}
+		if "" == next.ID || item.ID == next.ID || next.ID != next.IALAttr("id") {
+			t.Fatalf("case [%d] expected a new block ID, got [%s]", i, next.ID)
+		}
+		if md := treenode.FormatNode(next, luteEngine); !strings.Contains(md, "Water the plants") {
+			t.Fatalf("case [%d] expected content to be kept, got [%s]", i, md)
+		}
+		if c.attrs[TaskAttrRecurrence] != next.IALAttr(TaskAttrRecurrence) || c.attrs[TaskAttrPriority] != next.IALAttr(TaskAttrPriority) {
+			t.Fatalf("case [%d] expected recurrence and priority to be kept", i)
+		}
+		if due := next.IALAttr(TaskAttrDue); c.expectedDue != due {
+			t.Errorf("case [%d] expected due [%s], got [%s]", i, c.expectedDue, due)
+		}
+		if scheduled := next.IALAttr(TaskAttrScheduled); c.expectedScheduled != scheduled {
+			t.Errorf("case [%d] expected scheduled [%s], got [%s]", i, c.expectedScheduled, scheduled)
+		}
+	}
+
+	// 子任务和备注一起复制，使用新的 ID 并取消勾选
+	tree := parse.Parse("", []byte("* [X] Weekly review\n\n  Notes for the review\n\n  * [X] Inbox zero\n  * [ ] Plan next week\n"), luteEngine.ParseOptions)
+	item := tree.Root.FirstChild.FirstChild
+	item.SetIALAttr(TaskAttrRecurrence, "weekly")
+	item.SetIALAttr(TaskAttrNext, "20240101000000-aaaaaaa")
+	oldIDs := map[string]bool{}
+	ast.Walk(item, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() {
+			oldIDs[n.ID] = true
+		}
+		return ast.WalkContinue
+	})
+	next := newNextTaskItem(item, luteEngine)
+	if nil == next {
+		t.Fatalf("expected next task item with sub-tasks")
+	}
+	md := treenode.FormatNode(next, luteEngine)
+	for _, text := range []string{"Weekly review", "Notes for the review", "Inbox zero", "Plan next week"} {
+		if !strings.Contains(md, text) {
+			t.Fatalf("expected [%s] to be kept, got [%s]", text, md)
+		}
+	}
+	if "" != next.IALAttr(TaskAttrNext) {
+		t.Fatalf("expected next occurrence attribute to be cleared")
+	}
+	taskItems := 0
+	ast.Walk(next, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() {
+			return ast.WalkContinue
+		}
+		if oldIDs[n.ID] || n.ID != n.IALAttr("id") {
+			t.Fatalf("expected block [%s] to have a new ID", n.ID)
+		}
+		if isTaskListItem(n) {
+			taskItems++
+			if isTaskListItemChecked(n) {
+				t.Fatalf("expected task item [%s] to be unchecked", n.ID)
+			}
+		}
+		return ast.WalkContinue
+	})
+	if 3 != taskItems {
+		t.Fatalf("expected 3 task items, got [%d]", taskItems)
+	}
+
+	tree = parse.Parse("", []byte("* [X] Invalid recurrence\n"), luteEngine.ParseOptions)
+	item = tree.Root.FirstChild.FirstChild
+	item.SetIALAttr(TaskAttrRecurrence, "sometimes")
+	if next := newNextTaskItem(item, luteEngine); nil != next {
+		t.Fatalf("expected no next task item for invalid recurrence")
+	}
+}
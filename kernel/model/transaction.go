@@ -90,6 +90,10 @@ func flushTx(tx *Transaction) {
 		flushLock.Unlock()
 	}()
 
+	flushTx0(tx)
+}
+
+func flushTx0(tx *Transaction) {
 	start := time.Now()
 	if txErr := performTx(tx); nil != txErr {
 		switch txErr.code {
@@ -101,6 +105,12 @@ func flushTx(tx *Transaction) {
 		case TxErrHandleAttributeView:
 			util.PushMsg(Conf.language(258), 5000)
 			logging.LogErrorf("handle attribute view failed: %s", txErr.msg)
+		case TxErrCodeConflict:
+			logging.LogWarnf("transaction conflicted on block [%s]: %s", txErr.id, txErr.msg)
+		case TxErrCodeReadonlyBox:
+			// 只读笔记本中的修改不会保存，重新加载文档丢弃前端的修改
+			util.PushMsg(Conf.Language(34), 5000)
+			ReloadProtyle(txErr.id)
 		default:
 			txData, _ := gulu.JSON.MarshalJSON(tx)
 			logging.LogFatalf(logging.ExitCodeFatal, "transaction failed [%d]: %s\n  tx [%s]", txErr.code, txErr.msg, txData)
@@ -127,6 +137,8 @@ const (
 	TxErrCodeDataIsSyncing   = 1
 	TxErrCodeWriteTree       = 2
 	TxErrHandleAttributeView = 3
+	TxErrCodeConflict        = 4
+	TxErrCodeReadonlyBox     = 5
 )
 
 type TxErr struct {
@@ -167,6 +179,15 @@ func performTx(tx *Transaction) (ret *TxErr) {
 		}
 	}()
 
+	if ret = tx.checkPreconditions(); nil != ret {
+		tx.rollback()
+		return
+	}
+	if ret = tx.checkReadonlyBoxes(); nil != ret {
+		tx.rollback()
+		return
+	}
+
 	isLargeInsert := tx.processLargeInsert()
 	if !isLargeInsert {
 		for _, op := range tx.DoOperations {
@@ -313,6 +334,8 @@ func performTx(tx *Transaction) (ret *TxErr) {
 				ret = tx.doRemoveAttrViewGroup(op)
 			case "sortAttrViewGroup":
 				ret = tx.doSortAttrViewGroup(op)
+			case "setTagMetas":
+				ret = tx.doSetTagMetas(op)
 			}
 
 			if nil != ret {
@@ -322,6 +345,11 @@ func performTx(tx *Transaction) (ret *TxErr) {
 		}
 	}
 
+	if ret = tx.checkReadonlyTrees(); nil != ret {
+		tx.rollback()
+		return
+	}
+
 	if cr := tx.commit(); nil != cr {
 		logging.LogErrorf("commit tx failed: %s", cr)
 		return &TxErr{msg: cr.Error()}
@@ -645,10 +673,12 @@ func (tx *Transaction) doPrependInsert(operation *Operation) (ret *TxErr) {
 
 		createdUpdated(toInsert)
 		tx.nodes[toInsert.ID] = toInsert
+		tx.statInsertedBlock(tree, toInsert)
 	}
 
 	createdUpdated(insertedNode)
 	tx.nodes[insertedNode.ID] = insertedNode
+	tx.statInsertedBlock(tree, insertedNode)
 	if err = tx.writeTree(tree); err != nil {
 		return &TxErr{code: TxErrCodeWriteTree, msg: err.Error(), id: block.ID}
 	}
@@ -773,10 +803,12 @@ func (tx *Transaction) doAppendInsert(operation *Operation) (ret *TxErr) {
 
 		createdUpdated(toInsert)
 		tx.nodes[toInsert.ID] = toInsert
+		tx.statInsertedBlock(tree, toInsert)
 	}
 
 	createdUpdated(insertedNode)
 	tx.nodes[insertedNode.ID] = insertedNode
+	tx.statInsertedBlock(tree, insertedNode)
 	if err = tx.writeTree(tree); err != nil {
 		return &TxErr{code: TxErrCodeWriteTree, msg: err.Error(), id: block.ID}
 	}
@@ -910,6 +942,7 @@ func (tx *Transaction) doDelete(operation *Operation) (ret *TxErr) {
 		node.Next.Unlink()
 	}
 
+	tx.statDeletedBlock(tree, node)
 	node.Unlink()
 	if nil != parent && ast.NodeListItem == parent.Type && nil == parent.FirstChild {
 		needAppendEmptyListItem := true
@@ -1129,6 +1162,10 @@ func (tx *Transaction) doLargeInsert(previousID string) (ret *TxErr) {
 
 		createdUpdated(insertedNode)
 		tx.nodes[insertedNode.ID] = insertedNode
+		tx.statInsertedBlock(tree, insertedNode)
+		for _, remain := range remains {
+			tx.statInsertedBlock(tree, remain)
+		}
 		tx.trees[tree.ID] = tree
 
 		// 收集引用的定义块 ID
@@ -1320,6 +1357,10 @@ func (tx *Transaction) doInsert(operation *Operation) (ret *TxErr) {
 
 	createdUpdated(insertedNode)
 	tx.nodes[insertedNode.ID] = insertedNode
+	tx.statInsertedBlock(tree, insertedNode)
+	for _, remain := range remains {
+		tx.statInsertedBlock(tree, remain)
+	}
 	if err = tx.writeTree(tree); err != nil {
 		return &TxErr{code: TxErrCodeWriteTree, msg: err.Error(), id: bt.ID}
 	}
@@ -1443,6 +1484,11 @@ func (tx *Transaction) doUpdate(operation *Operation) (ret *TxErr) {
 		return &TxErr{msg: ErrBlockNotFound.Error(), id: id}
 	}
 
+	if merged := tx.mergeConcurrentUpdate(operation, tree, oldNode, subTree); nil != merged {
+		subTree = merged
+		subTree.ID, subTree.Box, subTree.Path = tree.ID, tree.Box, tree.Path
+	}
+
 	// 收集引用的定义块 ID
 	oldDefIDs := getRefDefIDs(oldNode)
 	var newDefIDs []string
@@ -1525,6 +1571,9 @@ func (tx *Transaction) doUpdate(operation *Operation) (ret *TxErr) {
 		syncDelete2AvBlock(n, tree, tx)
 	}
 
+	tx.completedRecurringTasks = append(tx.completedRecurringTasks, collectCompletedRecurringTasks(oldNode, updatedNode)...)
+	tx.statUpdatedBlock(tree, oldNode, updatedNode)
+
 	// 替换为新节点
 	oldNode.InsertAfter(updatedNode)
 	oldNode.Unlink()
@@ -1798,6 +1847,9 @@ type Operation struct {
 	BlockIDs   []string    `json:"blockIDs"`
 	BlockID    string      `json:"blockID"`
 
+	BaseSeq int64 `json:"baseSeq,omitempty"` // 协同编辑时操作基于的文档序号
+	Merged  bool  `json:"merged,omitempty"`  // 是否被服务端合并过并发修改
+
 	DeckID string `json:"deckID"` // 用于添加/删除闪卡
 
 	AvID              string                   `json:"avID"`              // 属性视图 ID
@@ -1826,6 +1878,17 @@ type Transaction struct {
 	DoOperations   []*Operation `json:"doOperations"`
 	UndoOperations []*Operation `json:"undoOperations"`
 
+	Preconditions        []*TxPrecondition `json:"preconditions,omitempty"` // 前置条件，任一不满足时整个事务不执行
+	conflicts            []*TxConflict     // 不满足前置条件的块
+	preconditionsChecked bool              // 整批事务执行前已经检查过前置条件
+
+	DocSeqs map[string]int64 `json:"docSeqs,omitempty"` // 提交后各文档的协同序号
+	Session string           `json:"-"`                 // 发起事务的会话
+
+	completedRecurringTasks    []string       // 本次事务中完成的重复任务
+	skipSyncedBlockPropagation bool           // 是否跳过同步块内容同步，同步产生的事务和插入新实例时不再同步
+	writingStat                *txWritingStat // 本次事务中块的新增、修改和删除，用于写作统计
+
 	trees map[string]*parse.Tree // 事务中变更的树
 	nodes map[string]*ast.Node   // 事务中变更的节点
 
@@ -1857,6 +1920,31 @@ func (tx *Transaction) begin() (err error) {
 	return
 }
 
+// checkReadonlyBoxes 检查操作涉及的块是否在只读笔记本中，只读笔记本中的块不能修改。
+func (tx *Transaction) checkReadonlyBoxes() (ret *TxErr) {
+	for _, op := range tx.DoOperations {
+		for _, id := range []string{op.ID, op.ParentID, op.PreviousID, op.NextID} {
+			if "" == id {
+				continue
+			}
+			if bt := treenode.GetBlockTree(id); nil != bt && filesys.IsBoxReadonly(bt.BoxID) {
+				return &TxErr{code: TxErrCodeReadonlyBox, msg: filesys.ErrBoxReadonly.Error(), id: bt.RootID}
+			}
+		}
+	}
+	return
+}
+
+// checkReadonlyTrees 检查事务需要写入的文档是否在只读笔记本中。
+func (tx *Transaction) checkReadonlyTrees() (ret *TxErr) {
+	for _, tree := range tx.trees {
+		if filesys.IsBoxReadonly(tree.Box) {
+			return &TxErr{code: TxErrCodeReadonlyBox, msg: filesys.ErrBoxReadonly.Error(), id: tree.ID}
+		}
+	}
+	return
+}
+
 func (tx *Transaction) commit() (err error) {
 	for _, tree := range tx.trees {
 		if err = writeTreeUpsertQueue(tree); err != nil {
@@ -1870,6 +1958,23 @@ func (tx *Transaction) commit() (err error) {
 		checkUpsertInUserGuide(tree)
 	}
 	refreshDynamicRefTexts(tx.nodes, tx.trees)
+	tx.commitCollab()
+	appendTxChange(tx)
+	if 1 < len(tx.trees) {
+		// 块可能在文档之间移动，评论需要跟随移动
+		var rootIDs []string
+		for rootID := range tx.trees {
+			rootIDs = append(rootIDs, rootID)
+		}
+		go relocateComments(rootIDs)
+	}
+	tx.commitWritingStat()
+	if 0 < len(tx.completedRecurringTasks) {
+		go createNextTaskOccurrences(tx.completedRecurringTasks)
+	}
+	if sources := tx.collectSyncedBlockSources(); 0 < len(sources) {
+		go propagateSyncedBlocks(sources)
+	}
 	IncSync()
 	tx.state.Store(2)
 	tx.m.Unlock()
@@ -166,6 +166,11 @@ func loadTree(localPath string, luteEngine *lute.Lute) (ret *parse.Tree, err err
 		return
 	}
 
+	if data, err = filesys.DecryptTreeData(data); err != nil {
+		logging.LogErrorf("decrypt data [path=%s] failed: %s", localPath, err)
+		return
+	}
+
 	ret, err = dataparser.ParseJSONWithoutFix(data, luteEngine.ParseOptions)
 	if err != nil {
 		logging.LogErrorf("parse json to tree [%s] failed: %s", localPath, err)
@@ -0,0 +1,126 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sync"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// TxPrecondition 描述了事务执行前块需要满足的状态，类似 HTTP If-Match，用于乐观并发控制。
+type TxPrecondition struct {
+	ID      string `json:"id"`
+	Updated string `json:"updated"` // 期望的块更新时间，为空时不检查
+	Hash    string `json:"hash"`    // 期望的块内容哈希（同 blocks.hash），为空时不检查
+}
+
+// TxConflict 描述了前置条件不满足时块的当前状态。
+type TxConflict struct {
+	ID       string `json:"id"`
+	Exists   bool   `json:"exists"`
+	Updated  string `json:"updated"`
+	Hash     string `json:"hash"`
+	Kramdown string `json:"kramdown"`
+}
+
+// Conflicts 返回事务因前置条件不满足而未执行时的冲突块，需要在事务执行完成后调用。
+func (tx *Transaction) Conflicts() []*TxConflict {
+	return tx.conflicts
+}
+
+// PerformTransactionsIfMatch 执行一批带前置条件的事务，执行完成后才返回。
+//
+// 先检查所有事务的前置条件，任一不满足时整批事务都不执行，每个事务的 Conflicts 返回各自的冲突块；
+// 全部满足时依次执行。检查和执行都在提交锁内完成，期间不会有其他事务修改块。
+func PerformTransactionsIfMatch(transactions []*Transaction) {
+	FlushTxQueue()
+
+	defer logging.Recover()
+	flushLock.Lock()
+	isFlushing = true
+	defer func() {
+		isFlushing = false
+		flushLock.Unlock()
+	}()
+
+	conflicted := false
+	for _, tx := range transactions {
+		tx.m = &sync.Mutex{}
+		checker := &Transaction{Preconditions: tx.Preconditions, trees: map[string]*parse.Tree{}, luteEngine: util.NewLute()}
+		checker.checkPreconditions()
+		tx.conflicts = checker.conflicts
+		conflicted = conflicted || 0 < len(tx.conflicts)
+	}
+	if conflicted {
+		return
+	}
+
+	for _, tx := range transactions {
+		// 前面的事务可能修改了后面事务前置条件中的块，整批事务已经检查过了，执行时不再检查
+		tx.preconditionsChecked = true
+		flushTx0(tx)
+	}
+}
+
+// checkPreconditions 检查事务的所有前置条件，任一不满足时整个事务都不执行。
+func (tx *Transaction) checkPreconditions() (ret *TxErr) {
+	if tx.preconditionsChecked {
+		return
+	}
+
+	tx.conflicts = nil
+	for _, precondition := range tx.Preconditions {
+		if "" == precondition.Updated && "" == precondition.Hash {
+			continue
+		}
+
+		conflict := &TxConflict{ID: precondition.ID}
+		var node *ast.Node
+		tree, err := tx.loadTree(precondition.ID)
+		if nil == err {
+			node = treenode.GetNodeInTree(tree, precondition.ID)
+		}
+		if nil == node {
+			tx.conflicts = append(tx.conflicts, conflict)
+			continue
+		}
+
+		conflict.Exists = true
+		conflict.Updated = node.IALAttr("updated")
+		if "" == conflict.Updated && 14 <= len(node.ID) {
+			conflict.Updated = node.ID[:14]
+		}
+		conflict.Hash = treenode.NodeHash(node, tree, tx.luteEngine)
+		if ("" == precondition.Updated || precondition.Updated == conflict.Updated) &&
+			("" == precondition.Hash || precondition.Hash == conflict.Hash) {
+			continue
+		}
+
+		conflict.Kramdown = treenode.FormatNode(node, tx.luteEngine)
+		tx.conflicts = append(tx.conflicts, conflict)
+	}
+
+	if 0 < len(tx.conflicts) {
+		ret = &TxErr{code: TxErrCodeConflict, msg: "precondition failed", id: tx.conflicts[0].ID}
+	}
+	return
+}
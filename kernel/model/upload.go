@@ -166,6 +166,10 @@ func Upload(c *gin.Context) {
 	if nil != form.Value["skipIfDuplicated"] {
 		skipIfDuplicated = "true" == form.Value["skipIfDuplicated"][0]
 	}
+	needOptimizeImage := Conf.Image.Optimize // 请求参数可以覆盖图片优化配置
+	if nil != form.Value["optimize"] {
+		needOptimizeImage = "true" == form.Value["optimize"][0]
+	}
 
 	for _, file := range files {
 		baseName := file.Filename
@@ -262,6 +266,10 @@ func Upload(c *gin.Context) {
 			}
 			f.Close()
 
+			if needOptimizeImage && !needUnzip2Dir {
+				fName = optimizeUploadImage(writePath, Conf.Image)
+			}
+
 			if needUnzip2Dir {
 				baseName = strings.TrimSuffix(file.Filename, ".rtfd.zip") + ".rtfd"
 				fName = baseName
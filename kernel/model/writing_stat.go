@@ -0,0 +1,433 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// WritingStat 描述一段时间内的写作统计，字数和字符数按块内容的增减计算。
+type WritingStat struct {
+	WordsAdded    int `json:"wordsAdded"`
+	WordsRemoved  int `json:"wordsRemoved"`
+	CharsAdded    int `json:"charsAdded"`
+	CharsRemoved  int `json:"charsRemoved"`
+	BlocksAdded   int `json:"blocksAdded"`
+	BlocksChanged int `json:"blocksChanged"`
+	BlocksRemoved int `json:"blocksRemoved"`
+}
+
+func (stat *WritingStat) add(other *WritingStat) {
+	stat.WordsAdded += other.WordsAdded
+	stat.WordsRemoved += other.WordsRemoved
+	stat.CharsAdded += other.CharsAdded
+	stat.CharsRemoved += other.CharsRemoved
+	stat.BlocksAdded += other.BlocksAdded
+	stat.BlocksChanged += other.BlocksChanged
+	stat.BlocksRemoved += other.BlocksRemoved
+}
+
+func (stat *WritingStat) addDelta(words, chars int) {
+	if 0 < words {
+		stat.WordsAdded += words
+	} else {
+		stat.WordsRemoved -= words
+	}
+	if 0 < chars {
+		stat.CharsAdded += chars
+	} else {
+		stat.CharsRemoved -= chars
+	}
+}
+
+// WritingDocStat 描述文档一天的写作统计。
+type WritingDocStat struct {
+	Box string `json:"box"`
+	*WritingStat
+}
+
+// WritingDay 描述一天的写作统计，每个设备单独存储一份，查询时合并，避免同步时互相覆盖。
+type WritingDay struct {
+	Date  string                     `json:"date"`
+	Total *WritingStat               `json:"total"`
+	Boxes map[string]*WritingStat    `json:"boxes"`
+	Docs  map[string]*WritingDocStat `json:"docs"`
+}
+
+func newWritingDay(date string) *WritingDay {
+	return &WritingDay{Date: date, Total: &WritingStat{}, Boxes: map[string]*WritingStat{}, Docs: map[string]*WritingDocStat{}}
+}
+
+func (day *WritingDay) add(rootID, box string, stat *WritingStat) {
+	day.Total.add(stat)
+	boxStat := day.Boxes[box]
+	if nil == boxStat {
+		boxStat = &WritingStat{}
+		day.Boxes[box] = boxStat
+	}
+	boxStat.add(stat)
+	docStat := day.Docs[rootID]
+	if nil == docStat {
+		docStat = &WritingDocStat{Box: box, WritingStat: &WritingStat{}}
+		day.Docs[rootID] = docStat
+	}
+	docStat.add(stat)
+}
+
+func (day *WritingDay) merge(other *WritingDay) {
+	for rootID, docStat := range other.Docs {
+		day.add(rootID, docStat.Box, docStat.WritingStat)
+	}
+}
+
+// WritingStatPoint 描述写作统计时间序列中的一天。
+type WritingStatPoint struct {
+	Date string `json:"date"`
+	*WritingStat
+}
+
+// WritingStreak 描述连续写作天数，当天新增字数达到目标字数才计入。
+type WritingStreak struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+	Goal    int `json:"goal"`
+}
+
+// txWritingBlock 记录事务中变更块的字数和字符数。
+type txWritingBlock struct {
+	rootID, box  string
+	words, chars int
+}
+
+// txWritingStat 收集事务中块的新增、修改和删除，提交时汇总到写作统计。
+type txWritingStat struct {
+	inserted map[string]*txWritingBlock
+	deleted  map[string]*txWritingBlock
+	updated  map[string][2]*txWritingBlock
+}
+
+func newTxWritingBlock(tree *parse.Tree, node *ast.Node) *txWritingBlock {
+	chars, words, _, _, _ := node.Stat()
+	return &txWritingBlock{rootID: tree.ID, box: tree.Box, words: words, chars: chars}
+}
+
+func (tx *Transaction) getWritingStat() *txWritingStat {
+	if nil == tx.writingStat {
+		tx.writingStat = &txWritingStat{inserted: map[string]*txWritingBlock{}, deleted: map[string]*txWritingBlock{}, updated: map[string][2]*txWritingBlock{}}
+	}
+	return tx.writingStat
+}
+
+func (tx *Transaction) statInsertedBlock(tree *parse.Tree, node *ast.Node) {
+	if nil == node || tx.skipSyncedBlockPropagation {
+		return
+	}
+	tx.getWritingStat().inserted[node.ID] = newTxWritingBlock(tree, node)
+}
+
+func (tx *Transaction) statDeletedBlock(tree *parse.Tree, node *ast.Node) {
+	if nil == node || tx.skipSyncedBlockPropagation {
+		return
+	}
+	tx.getWritingStat().deleted[node.ID] = newTxWritingBlock(tree, node)
+}
+
+func (tx *Transaction) statUpdatedBlock(tree *parse.Tree, oldNode, newNode *ast.Node) {
+	if nil == oldNode || nil == newNode || tx.skipSyncedBlockPropagation {
+		return
+	}
+
+	stat := tx.getWritingStat()
+	if prev, ok := stat.updated[newNode.ID]; ok { // 同一个事务中多次更新时使用最早的旧值
+		stat.updated[newNode.ID] = [2]*txWritingBlock{prev[0], newTxWritingBlock(tree, newNode)}
+		return
+	}
+	stat.updated[newNode.ID] = [2]*txWritingBlock{newTxWritingBlock(tree, oldNode), newTxWritingBlock(tree, newNode)}
+}
+
+var (
+	writingStatPending   = map[string]*WritingDay{} // 尚未写入磁盘的统计，按日期分组
+	writingStatLock      = sync.Mutex{}
+	writingStatFlushLock = sync.Mutex{} // 串行化统计文件的读取、合并和写入，避免并发写入时丢失统计
+)
+
+// commitWritingStat 汇总事务中的块变更。先删除后插入的相同块（比如移动）视为修改。
+func (tx *Transaction) commitWritingStat() {
+	stat := tx.writingStat
+	if nil == stat {
+		return
+	}
+
+	docStats := map[string]*WritingStat{}
+	docBoxes := map[string]string{}
+	getDocStat := func(b *txWritingBlock) *WritingStat {
+		ret := docStats[b.rootID]
+		if nil == ret {
+			ret = &WritingStat{}
+			docStats[b.rootID] = ret
+			docBoxes[b.rootID] = b.box
+		}
+		return ret
+	}
+
+	for id, inserted := range stat.inserted {
+		docStat := getDocStat(inserted)
+		if deleted := stat.deleted[id]; nil != deleted {
+			delete(stat.deleted, id)
+			if deleted.rootID != inserted.rootID {
+				// 跨文档移动
+				getDocStat(deleted).addDelta(-deleted.words, -deleted.chars)
+				docStat.addDelta(inserted.words, inserted.chars)
+				continue
+			}
+			if deleted.words != inserted.words || deleted.chars != inserted.chars {
+				docStat.BlocksChanged++
+				docStat.addDelta(inserted.words-deleted.words, inserted.chars-deleted.chars)
+			}
+			continue
+		}
+		docStat.BlocksAdded++
+		docStat.addDelta(inserted.words, inserted.chars)
+	}
+	for _, deleted := range stat.deleted {
+		docStat := getDocStat(deleted)
+		docStat.BlocksRemoved++
+		docStat.addDelta(-deleted.words, -deleted.chars)
+	}
+	for _, updated := range stat.updated {
+		oldBlock, newBlock := updated[0], updated[1]
+		if oldBlock.words == newBlock.words && oldBlock.chars == newBlock.chars {
+			continue
+		}
+		docStat := getDocStat(newBlock)
+		docStat.BlocksChanged++
+		docStat.addDelta(newBlock.words-oldBlock.words, newBlock.chars-oldBlock.chars)
+	}
+	if 1 > len(docStats) {
+		return
+	}
+
+	date := time.Now().Format("2006-01-02")
+	writingStatLock.Lock()
+	defer writingStatLock.Unlock()
+	day := writingStatPending[date]
+	if nil == day {
+		day = newWritingDay(date)
+		writingStatPending[date] = day
+	}
+	for rootID, docStat := range docStats {
+		day.add(rootID, docBoxes[rootID], docStat)
+	}
+}
+
+func FlushWritingStatJob() {
+	flushWritingStat()
+}
+
+func flushWritingStat() {
+	writingStatFlushLock.Lock()
+	defer writingStatFlushLock.Unlock()
+
+	writingStatLock.Lock()
+	pending := writingStatPending
+	writingStatPending = map[string]*WritingDay{}
+	writingStatLock.Unlock()
+
+	deviceID := util.GetDeviceID()
+	for date, day := range pending {
+		dataPath := filepath.Join(util.DataDir, "storage", "writing", date, deviceID+".json")
+		if saved := loadWritingDayFile(dataPath); nil != saved {
+			day.merge(saved)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
+			logging.LogErrorf("create storage [writing] dir failed: %s", err)
+			continue
+		}
+		data, err := gulu.JSON.MarshalIndentJSON(day, "", "  ")
+		if err != nil {
+			logging.LogErrorf("marshal storage [writing] failed: %s", err)
+			continue
+		}
+		if err = filelock.WriteFile(dataPath, data); err != nil {
+			logging.LogErrorf("write storage [writing] failed: %s", err)
+		}
+	}
+}
+
+func loadWritingDayFile(dataPath string) (ret *WritingDay) {
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read storage [writing] failed: %s", err)
+		return
+	}
+	ret = &WritingDay{}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); err != nil {
+		logging.LogErrorf("unmarshal storage [writing] failed: %s", err)
+		return nil
+	}
+	for rootID, docStat := range ret.Docs {
+		if nil == docStat || nil == docStat.WritingStat {
+			delete(ret.Docs, rootID)
+		}
+	}
+	return
+}
+
+// loadWritingDays 读取所有日期的写作统计，合并各个设备的数据。
+func loadWritingDays() (ret map[string]*WritingDay) {
+	flushWritingStat()
+
+	ret = map[string]*WritingDay{}
+	dir := filepath.Join(util.DataDir, "storage", "writing")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		date := entry.Name()
+		if _, parseErr := time.ParseInLocation("2006-01-02", date, time.Local); nil != parseErr {
+			continue
+		}
+
+		files, _ := os.ReadDir(filepath.Join(dir, date))
+		for _, file := range files {
+			if !strings.HasSuffix(file.Name(), ".json") {
+				continue
+			}
+
+			saved := loadWritingDayFile(filepath.Join(dir, date, file.Name()))
+			if nil == saved {
+				continue
+			}
+			day := ret[date]
+			if nil == day {
+				day = newWritingDay(date)
+				ret[date] = day
+			}
+			day.merge(saved)
+		}
+	}
+	return
+}
+
+// GetWritingStat 返回 [from, to] 日期范围内每天的写作统计和连续写作天数，box 和 rootID 用于按笔记本或文档过滤。
+func GetWritingStat(from, to, box, rootID string, goal int) (series []*WritingStatPoint, total *WritingStat, streak *WritingStreak, err error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	toDate := today
+	if "" != to {
+		if toDate, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			return
+		}
+	}
+	fromDate := toDate.AddDate(0, 0, -29)
+	if "" != from {
+		if fromDate, err = time.ParseInLocation("2006-01-02", from, time.Local); err != nil {
+			return
+		}
+	}
+	if fromDate.After(toDate) {
+		fromDate, toDate = toDate, fromDate
+	}
+	if 1 > goal {
+		goal = 1
+	}
+
+	days := loadWritingDays()
+	dayStat := func(date string) (ret *WritingStat) {
+		ret = &WritingStat{}
+		day := days[date]
+		if nil == day {
+			return
+		}
+
+		if "" != rootID {
+			if docStat := day.Docs[rootID]; nil != docStat {
+				ret.add(docStat.WritingStat)
+			}
+		} else if "" != box {
+			if boxStat := day.Boxes[box]; nil != boxStat {
+				ret.add(boxStat)
+			}
+		} else {
+			ret.add(day.Total)
+		}
+		return
+	}
+
+	series, total = []*WritingStatPoint{}, &WritingStat{}
+	for d := fromDate; !d.After(toDate); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		stat := dayStat(date)
+		total.add(stat)
+		series = append(series, &WritingStatPoint{Date: date, WritingStat: stat})
+	}
+
+	streak = &WritingStreak{Goal: goal}
+	var dates []string
+	for date := range days {
+		if goal <= dayStat(date).WordsAdded {
+			dates = append(dates, date)
+		}
+	}
+	streak.Current, streak.Longest = writingStreak(dates, today)
+	return
+}
+
+// writingStreak 根据达到目标的日期计算当前和最长连续天数，今天还没有达到目标时不中断当前连续天数。
+func writingStreak(dates []string, today time.Time) (current, longest int) {
+	sort.Strings(dates)
+	run := 0
+	var prev time.Time
+	for _, date := range dates {
+		d, _ := time.ParseInLocation("2006-01-02", date, time.Local)
+		if 0 < run && prev.AddDate(0, 0, 1).Equal(d) {
+			run++
+		} else {
+			run = 1
+		}
+		if longest < run {
+			longest = run
+		}
+		prev = d
+	}
+	if 0 < len(dates) && (prev.Equal(today) || prev.Equal(today.AddDate(0, 0, -1))) {
+		current = run
+	}
+	return
+}
@@ -0,0 +1,97 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommitWritingStat(t *testing.T) {
+	writingStatPending = map[string]*WritingDay{}
+	defer func() { writingStatPending = map[string]*WritingDay{} }()
+
+	tx := &Transaction{}
+	stat := tx.getWritingStat()
+	// 新增块
+	stat.inserted["b1"] = &txWritingBlock{rootID: "d1", box: "box1", words: 5, chars: 10}
+	// 删除块
+	stat.deleted["b2"] = &txWritingBlock{rootID: "d1", box: "box1", words: 2, chars: 4}
+	// 文档内移动且内容不变
+	stat.deleted["b3"] = &txWritingBlock{rootID: "d1", box: "box1", words: 3, chars: 6}
+	stat.inserted["b3"] = &txWritingBlock{rootID: "d1", box: "box1", words: 3, chars: 6}
+	// 跨文档移动
+	stat.deleted["b4"] = &txWritingBlock{rootID: "d1", box: "box1", words: 4, chars: 8}
+	stat.inserted["b4"] = &txWritingBlock{rootID: "d2", box: "box2", words: 4, chars: 8}
+	// 修改块
+	stat.updated["b5"] = [2]*txWritingBlock{{rootID: "d2", box: "box2", words: 6, chars: 12}, {rootID: "d2", box: "box2", words: 1, chars: 2}}
+	// 内容不变的修改
+	stat.updated["b6"] = [2]*txWritingBlock{{rootID: "d2", box: "box2", words: 1, chars: 1}, {rootID: "d2", box: "box2", words: 1, chars: 1}}
+	tx.commitWritingStat()
+
+	if 1 != len(writingStatPending) {
+		t.Fatalf("expected one pending day, got [%d]", len(writingStatPending))
+	}
+	var day *WritingDay
+	for _, pending := range writingStatPending {
+		day = pending
+	}
+
+	d1 := day.Docs["d1"]
+	if nil == d1 || "box1" != d1.Box || 1 != d1.BlocksAdded || 1 != d1.BlocksRemoved || 0 != d1.BlocksChanged ||
+		5 != d1.WordsAdded || 6 != d1.WordsRemoved || 10 != d1.CharsAdded || 12 != d1.CharsRemoved {
+		t.Fatalf("unexpected doc [d1] stat [%+v]", d1)
+	}
+	d2 := day.Docs["d2"]
+	if nil == d2 || "box2" != d2.Box || 0 != d2.BlocksAdded || 0 != d2.BlocksRemoved || 1 != d2.BlocksChanged ||
+		4 != d2.WordsAdded || 5 != d2.WordsRemoved || 8 != d2.CharsAdded || 10 != d2.CharsRemoved {
+		t.Fatalf("unexpected doc [d2] stat [%+v]", d2)
+	}
+	if 9 != day.Total.WordsAdded || 11 != day.Total.WordsRemoved || 4 != day.Boxes["box2"].WordsAdded {
+		t.Fatalf("unexpected total stat [%+v]", day.Total)
+	}
+
+	// 没有变更的事务不产生统计
+	writingStatPending = map[string]*WritingDay{}
+	(&Transaction{}).commitWritingStat()
+	if 0 != len(writingStatPending) {
+		t.Fatalf("expected no pending day, got [%d]", len(writingStatPending))
+	}
+}
+
+func TestWritingStreak(t *testing.T) {
+	today := time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local)
+	cases := []struct {
+		dates            []string
+		current, longest int
+	}{
+		{nil, 0, 0},
+		{[]string{"2024-03-10", "2024-03-08", "2024-03-09"}, 3, 3},
+		// 今天还没有达到目标时不中断
+		{[]string{"2024-03-07", "2024-03-08", "2024-03-09"}, 3, 3},
+		{[]string{"2024-03-01", "2024-03-02", "2024-03-03", "2024-03-04", "2024-03-09", "2024-03-10"}, 2, 4},
+		{[]string{"2024-03-05", "2024-03-06", "2024-03-07", "2024-03-08"}, 0, 4},
+		// 跨月
+		{[]string{"2024-02-28", "2024-02-29", "2024-03-01"}, 0, 3},
+	}
+	for i, c := range cases {
+		current, longest := writingStreak(c.dates, today)
+		if c.current != current || c.longest != longest {
+			t.Fatalf("case [%d]: expected [%d, %d], got [%d, %d]", i, c.current, c.longest, current, longest)
+		}
+	}
+}
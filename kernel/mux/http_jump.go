@@ -2,13 +2,33 @@ package mux
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/88250/lute/ast"
 	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
 )
 
 func Jump(c *gin.Context) {
 	// 获取 block_id 参数
-	blockID := c.Param("block_id")
+	blockID := strings.TrimPrefix(c.Param("block_id"), "/")
+	if !ast.IsNodeIDPattern(blockID) {
+		// 使用文档标题或者可读路径跳转时解析为文档 ID，文档重命名或移动后通过重定向解析
+		// 解析可读路径会暴露文档是否存在，所以需要先鉴权，鉴权失败时 CheckAuth 已经写入响应
+		if model.CheckAuth(c); c.IsAborted() {
+			return
+		}
+		if id := model.GetDocIDByHPath(blockID); "" != id {
+			blockID = id
+		}
+	}
+
+	if model.RoleReader == model.GetGinContextRole(c) {
+		// 通过发布服务访问时在 Web 端打开
+		c.Redirect(http.StatusFound, "/?id="+url.QueryEscape(blockID)+"&focus=1")
+		return
+	}
 
 	// 构建跳转 URL
 	redirectURL := "siyuan://blocks/" + blockID + "?focus=1"
@@ -0,0 +1,142 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/siyuan-note/logging"
+)
+
+// AssetMeta 描述资源文件的元数据，对应 asset_meta 表。
+type AssetMeta struct {
+	ID       string
+	Path     string
+	Name     string
+	Ext      string
+	Type     string // image/audio/video/pdf
+	Size     int64
+	Updated  int64
+	Width    int
+	Height   int
+	Taken    string  // 拍摄时间，格式同块的 created
+	Camera   string  // 相机厂商和型号
+	Lat      float64 // 纬度
+	Lng      float64 // 经度
+	Duration float64 // 音视频时长，单位：秒
+	Pages    int     // PDF 页数
+	Author   string
+	Title    string
+}
+
+const (
+	AssetMetaInsert      = "INSERT INTO asset_meta (id, path, name, ext, type, size, updated, width, height, taken, camera, lat, lng, duration, pages, author, title) VALUES %s"
+	AssetMetaPlaceholder = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+)
+
+func insertAssetMetas(tx *sql.Tx, assetMetas []*AssetMeta) (err error) {
+	if 1 > len(assetMetas) {
+		return
+	}
+
+	var bulk []*AssetMeta
+	for _, assetMeta := range assetMetas {
+		if err = deleteAssetMetaByPath(tx, assetMeta.Path); err != nil {
+			return
+		}
+
+		bulk = append(bulk, assetMeta)
+		if 512 > len(bulk) {
+			continue
+		}
+
+		if err = insertAssetMetas0(tx, bulk); err != nil {
+			return
+		}
+		bulk = []*AssetMeta{}
+	}
+	if 0 < len(bulk) {
+		if err = insertAssetMetas0(tx, bulk); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func insertAssetMetas0(tx *sql.Tx, bulk []*AssetMeta) (err error) {
+	valueStrings := make([]string, 0, len(bulk))
+	valueArgs := make([]interface{}, 0, len(bulk)*strings.Count(AssetMetaPlaceholder, "?"))
+	for _, b := range bulk {
+		valueStrings = append(valueStrings, AssetMetaPlaceholder)
+		valueArgs = append(valueArgs, b.ID, b.Path, b.Name, b.Ext, b.Type, b.Size, b.Updated, b.Width, b.Height, b.Taken, b.Camera, b.Lat, b.Lng, b.Duration, b.Pages, b.Author, b.Title)
+	}
+
+	stmt := fmt.Sprintf(AssetMetaInsert, strings.Join(valueStrings, ","))
+	err = prepareExecInsertTx(tx, stmt, valueArgs)
+	return
+}
+
+func deleteAssetMetaByPath(tx *sql.Tx, path string) (err error) {
+	err = execStmtTx(tx, "DELETE FROM asset_meta WHERE path = ?", path)
+	return
+}
+
+func GetAssetMeta(path string) (ret *AssetMeta) {
+	rows, err := query("SELECT * FROM asset_meta WHERE path = ?", path)
+	if err != nil {
+		logging.LogErrorf("sql query failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ret = scanAssetMetaRows(rows)
+	}
+	return
+}
+
+// GetAssetMetaUpdated 返回所有已索引资源文件的路径和更新时间，用于增量索引。
+func GetAssetMetaUpdated() (ret map[string]int64) {
+	ret = map[string]int64{}
+	rows, err := query("SELECT path, updated FROM asset_meta")
+	if err != nil {
+		logging.LogErrorf("sql query failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		var updated int64
+		if err = rows.Scan(&path, &updated); err != nil {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret[path] = updated
+	}
+	return
+}
+
+func scanAssetMetaRows(rows *sql.Rows) (ret *AssetMeta) {
+	var assetMeta AssetMeta
+	if err := rows.Scan(&assetMeta.ID, &assetMeta.Path, &assetMeta.Name, &assetMeta.Ext, &assetMeta.Type, &assetMeta.Size, &assetMeta.Updated, &assetMeta.Width, &assetMeta.Height, &assetMeta.Taken, &assetMeta.Camera, &assetMeta.Lat, &assetMeta.Lng, &assetMeta.Duration, &assetMeta.Pages, &assetMeta.Author, &assetMeta.Title); err != nil {
+		logging.LogErrorf("query scan field failed: %s", err)
+		return
+	}
+	ret = &assetMeta
+	return
+}
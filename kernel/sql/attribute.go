@@ -16,6 +16,10 @@
 
 package sql
 
+import (
+	"github.com/siyuan-note/logging"
+)
+
 type Attribute struct {
 	ID      string
 	Name    string
@@ -26,3 +30,22 @@ type Attribute struct {
 	Box     string
 	Path    string
 }
+
+// QueryBlockIDsByAttr 返回属性 name 的值为 value 的块 ID。
+func QueryBlockIDsByAttr(name, value string) (ret []string) {
+	rows, err := query("SELECT block_id FROM attributes WHERE name = ? AND value = ?", name, value)
+	if err != nil {
+		logging.LogErrorf("sql query failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret = append(ret, id)
+	}
+	return
+}
@@ -0,0 +1,134 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/siyuan-note/logging"
+)
+
+// Comment 描述块上的评论，对应 comments 表。
+type Comment struct {
+	ID       string
+	ParentID string // 回复的主题评论 ID，为空表示主题评论
+	BlockID  string
+	RootID   string
+	Box      string
+	Author   string
+	Content  string
+	Resolved bool
+	Created  int64
+	Updated  int64
+}
+
+const (
+	CommentsInsert      = "INSERT INTO comments (id, parent_id, block_id, root_id, box, author, content, resolved, created, updated) VALUES %s"
+	CommentsPlaceholder = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+)
+
+func upsertComments(tx *sql.Tx, rootID string, comments []*Comment) (err error) {
+	if err = deleteCommentsByRootID(tx, rootID); err != nil {
+		return
+	}
+
+	var bulk []*Comment
+	for _, comment := range comments {
+		bulk = append(bulk, comment)
+		if 512 > len(bulk) {
+			continue
+		}
+
+		if err = insertComments0(tx, bulk); err != nil {
+			return
+		}
+		bulk = []*Comment{}
+	}
+	if 0 < len(bulk) {
+		if err = insertComments0(tx, bulk); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func insertComments0(tx *sql.Tx, bulk []*Comment) (err error) {
+	valueStrings := make([]string, 0, len(bulk))
+	valueArgs := make([]interface{}, 0, len(bulk)*strings.Count(CommentsPlaceholder, "?"))
+	for _, c := range bulk {
+		valueStrings = append(valueStrings, CommentsPlaceholder)
+		valueArgs = append(valueArgs, c.ID, c.ParentID, c.BlockID, c.RootID, c.Box, c.Author, c.Content, c.Resolved, c.Created, c.Updated)
+	}
+
+	stmt := fmt.Sprintf(CommentsInsert, strings.Join(valueStrings, ","))
+	err = prepareExecInsertTx(tx, stmt, valueArgs)
+	return
+}
+
+func deleteCommentsByRootID(tx *sql.Tx, rootID string) (err error) {
+	err = execStmtTx(tx, "DELETE FROM comments WHERE root_id = ?", rootID)
+	return
+}
+
+func GetComment(id string) (ret *Comment) {
+	rows, err := query("SELECT * FROM comments WHERE id = ?", id)
+	if err != nil {
+		logging.LogErrorf("sql query failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ret = scanCommentRows(rows)
+	}
+	return
+}
+
+// GetOpenComments 返回未解决的主题评论，box 为空时查询所有笔记本。
+func GetOpenComments(box string, limit int) (ret []*Comment) {
+	stmt := "SELECT * FROM comments WHERE parent_id = '' AND resolved = 0"
+	var args []interface{}
+	if "" != box {
+		stmt += " AND box = ?"
+		args = append(args, box)
+	}
+	stmt += " ORDER BY updated DESC LIMIT ?"
+	args = append(args, limit)
+	rows, err := query(stmt, args...)
+	if err != nil {
+		logging.LogErrorf("sql query failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if comment := scanCommentRows(rows); nil != comment {
+			ret = append(ret, comment)
+		}
+	}
+	return
+}
+
+func scanCommentRows(rows *sql.Rows) (ret *Comment) {
+	var comment Comment
+	if err := rows.Scan(&comment.ID, &comment.ParentID, &comment.BlockID, &comment.RootID, &comment.Box, &comment.Author, &comment.Content, &comment.Resolved, &comment.Created, &comment.Updated); err != nil {
+		logging.LogErrorf("query scan field failed: %s", err)
+		return
+	}
+	ret = &comment
+	return
+}
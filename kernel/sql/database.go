@@ -190,6 +190,32 @@ func initDBTables() {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_assets_root_id] failed: %s", err)
 	}
 
+	_, err = db.Exec("DROP TABLE IF EXISTS asset_meta")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [asset_meta] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE TABLE asset_meta (id, path, name, ext, type, size, updated, width, height, taken, camera, lat, lng, duration, pages, author, title)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [asset_meta] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE INDEX idx_asset_meta_path ON asset_meta(path)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_asset_meta_path] failed: %s", err)
+	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS comments")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [comments] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE TABLE comments (id, parent_id, block_id, root_id, box, author, content, resolved, created, updated)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [comments] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE INDEX idx_comments_root_id ON comments(root_id)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_comments_root_id] failed: %s", err)
+	}
+
 	_, err = db.Exec("DROP TABLE IF EXISTS attributes")
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [attributes] failed: %s", err)
@@ -224,6 +250,15 @@ func initDBTables() {
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [refs] failed: %s", err)
 	}
+
+	_, err = db.Exec("DROP VIEW IF EXISTS tasks")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop view [tasks] failed: %s", err)
+	}
+	_, err = db.Exec(TasksViewCreate)
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create view [tasks] failed: %s", err)
+	}
 }
 
 func initDBConnection() {
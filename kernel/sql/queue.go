@@ -41,17 +41,21 @@ var (
 
 type dbQueueOperation struct {
 	inQueueTime                   time.Time
-	action                        string      // upsert/delete/delete_id/rename/rename_sub_tree/delete_box/delete_box_refs/index/delete_ids/update_block_content/delete_assets
-	indexTree                     *parse.Tree // index
-	upsertTree                    *parse.Tree // upsert/update_refs/delete_refs
-	removeTreeBox, removeTreePath string      // delete
-	removeTreeID                  string      // delete_id
-	removeTreeIDs                 []string    // delete_ids
-	box                           string      // delete_box/delete_box_refs/index
-	renameTree                    *parse.Tree // rename/rename_sub_tree
-	block                         *Block      // update_block_content
-	id                            string      // index_node
-	removeAssetHashes             []string    // delete_assets
+	action                        string       // upsert/delete/delete_id/rename/rename_sub_tree/delete_box/delete_box_refs/index/delete_ids/update_block_content/delete_assets/index_asset_meta/delete_asset_meta/index_comments
+	indexTree                     *parse.Tree  // index
+	upsertTree                    *parse.Tree  // upsert/update_refs/delete_refs
+	removeTreeBox, removeTreePath string       // delete
+	removeTreeID                  string       // delete_id
+	removeTreeIDs                 []string     // delete_ids
+	box                           string       // delete_box/delete_box_refs/index
+	renameTree                    *parse.Tree  // rename/rename_sub_tree
+	block                         *Block       // update_block_content
+	id                            string       // index_node
+	removeAssetHashes             []string     // delete_assets
+	assetMetas                    []*AssetMeta // index_asset_meta
+	assetMetaPath                 string       // delete_asset_meta
+	commentRootID                 string       // index_comments
+	comments                      []*Comment   // index_comments
 }
 
 func FlushTxJob() {
@@ -172,6 +176,12 @@ func execOp(op *dbQueueOperation, tx *sql.Tx, context map[string]interface{}) (e
 		err = deleteAssetsByHashes(tx, op.removeAssetHashes)
 	case "index_node":
 		err = indexNode(tx, op.id)
+	case "index_asset_meta":
+		err = insertAssetMetas(tx, op.assetMetas)
+	case "delete_asset_meta":
+		err = deleteAssetMetaByPath(tx, op.assetMetaPath)
+	case "index_comments":
+		err = upsertComments(tx, op.commentRootID, op.comments)
 	default:
 		msg := fmt.Sprintf("unknown operation [%s]", op.action)
 		logging.LogErrorf(msg)
@@ -194,6 +204,35 @@ func IndexNodeQueue(id string) {
 	appendOperation(newOp)
 }
 
+func IndexAssetMetaQueue(assetMetas []*AssetMeta) {
+	if 1 > len(assetMetas) {
+		return
+	}
+
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+
+	newOp := &dbQueueOperation{assetMetas: assetMetas, inQueueTime: time.Now(), action: "index_asset_meta"}
+	appendOperation(newOp)
+}
+
+func DeleteAssetMetaQueue(path string) {
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+
+	newOp := &dbQueueOperation{assetMetaPath: path, inQueueTime: time.Now(), action: "delete_asset_meta"}
+	appendOperation(newOp)
+}
+
+// IndexCommentsQueue 重建文档的评论索引，comments 为空时仅删除索引。
+func IndexCommentsQueue(rootID string, comments []*Comment) {
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+
+	newOp := &dbQueueOperation{commentRootID: rootID, comments: comments, inQueueTime: time.Now(), action: "index_comments"}
+	appendOperation(newOp)
+}
+
 func BatchRemoveAssetsQueue(hashes []string) {
 	if 1 > len(hashes) {
 		return
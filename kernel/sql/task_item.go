@@ -0,0 +1,86 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+
+	"github.com/siyuan-note/logging"
+)
+
+// TaskItem 描述任务列表项及其任务属性，对应 tasks 视图。
+type TaskItem struct {
+	ID         string
+	ParentID   string
+	RootID     string
+	Box        string
+	Path       string
+	HPath      string
+	Content    string
+	Done       bool
+	Due        string // 截止时间，格式为 yyyyMMdd 或者 yyyyMMddHHmmss
+	Scheduled  string // 计划时间，格式同截止时间
+	Priority   string // high/medium/low
+	Recurrence string // 重复规则
+	Created    string
+	Updated    string
+}
+
+// TasksViewCreate 任务视图，任务属性保存在列表项块属性 custom-task-* 中。
+//
+// 任务列表项（subtype 为 t）的 Markdown 以列表标记开头，紧跟任务标记 [ ] 或者 [x]，完成状态只取决于列表标记后的任务标记，
+// 不受列表项内容中 [x] 文本的影响。
+const TasksViewCreate = "CREATE VIEW tasks AS SELECT b.id AS id, b.parent_id AS parent_id, b.root_id AS root_id, b.box AS box, b.path AS path, b.hpath AS hpath, b.content AS content, " +
+	"CASE WHEN '[X]' = upper(substr(ltrim(substr(b.markdown, instr(b.markdown, ' '))), 1, 3)) THEN 1 ELSE 0 END AS done, " +
+	"ifnull((SELECT a.value FROM attributes a WHERE a.block_id = b.id AND a.name = 'custom-task-due'), '') AS due, " +
+	"ifnull((SELECT a.value FROM attributes a WHERE a.block_id = b.id AND a.name = 'custom-task-scheduled'), '') AS scheduled, " +
+	"ifnull((SELECT a.value FROM attributes a WHERE a.block_id = b.id AND a.name = 'custom-task-priority'), '') AS priority, " +
+	"ifnull((SELECT a.value FROM attributes a WHERE a.block_id = b.id AND a.name = 'custom-task-recurrence'), '') AS recurrence, " +
+	"b.created AS created, b.updated AS updated " +
+	"FROM blocks b WHERE b.type = 'i' AND b.subtype = 't'"
+
+// QueryTaskItems 查询任务视图，where 为空时查询所有任务。
+func QueryTaskItems(where string, args []interface{}, limit int) (ret []*TaskItem) {
+	stmt := "SELECT id, parent_id, root_id, box, path, hpath, content, done, due, scheduled, priority, recurrence, created, updated FROM tasks"
+	if "" != where {
+		stmt += " WHERE " + where
+	}
+	stmt += " ORDER BY CASE WHEN '' = due THEN 1 ELSE 0 END, due, CASE priority WHEN 'high' THEN 0 WHEN 'medium' THEN 1 WHEN 'low' THEN 2 ELSE 3 END, updated DESC LIMIT ?"
+	args = append(args, limit)
+	rows, err := query(stmt, args...)
+	if err != nil {
+		logging.LogErrorf("sql query [%s] failed: %s", stmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if taskItem := scanTaskItemRows(rows); nil != taskItem {
+			ret = append(ret, taskItem)
+		}
+	}
+	return
+}
+
+func scanTaskItemRows(rows *sql.Rows) (ret *TaskItem) {
+	var taskItem TaskItem
+	if err := rows.Scan(&taskItem.ID, &taskItem.ParentID, &taskItem.RootID, &taskItem.Box, &taskItem.Path, &taskItem.HPath, &taskItem.Content, &taskItem.Done, &taskItem.Due, &taskItem.Scheduled, &taskItem.Priority, &taskItem.Recurrence, &taskItem.Created, &taskItem.Updated); err != nil {
+		logging.LogErrorf("query scan field failed: %s", err)
+		return
+	}
+	ret = &taskItem
+	return
+}
@@ -120,6 +120,7 @@ func getCurrentTasks() (ret []*Task) {
 const (
 	RepoCheckout                    = "task.repo.checkout"                 // 从快照中检出
 	RepoAutoPurge                   = "task.repo.autoPurge"                // 自动清理数据仓库
+	RepoBackupTarget                = "task.repo.backupTarget"             // 推送快照到备份目标
 	DatabaseIndexFull               = "task.database.index.full"           // 重建索引
 	DatabaseIndex                   = "task.database.index"                // 数据库索引
 	DatabaseIndexCommit             = "task.database.index.commit"         // 数据库索引提交
@@ -133,6 +134,8 @@ const (
 	ReloadUI                        = "task.reload.ui"                     // 重载 UI
 	AssetContentDatabaseIndexFull   = "task.asset.database.index.full"     // 资源文件数据库重建索引
 	AssetContentDatabaseIndexCommit = "task.asset.database.index.commit"   // 资源文件数据库索引提交
+	AssetMetaDatabaseIndex          = "task.asset.meta.database.index"     // 资源文件元数据索引
+	CommentDatabaseIndex            = "task.comment.database.index"        // 块评论索引
 	CacheVirtualBlockRef            = "task.cache.virtualBlockRef"         // 缓存虚拟块引用
 	ReloadAttributeView             = "task.reload.attributeView"          // 重新加载属性视图
 	ReloadProtyle                   = "task.reload.protyle"                // 重新加载编辑器
@@ -148,6 +151,7 @@ const (
 var uniqueActions = []string{
 	RepoCheckout,
 	RepoAutoPurge,
+	RepoBackupTarget,
 	DatabaseIndexFull,
 	DatabaseIndexCommit,
 	OCRImage,
@@ -156,6 +160,8 @@ var uniqueActions = []string{
 	HistoryDatabaseIndexCommit,
 	AssetContentDatabaseIndexFull,
 	AssetContentDatabaseIndexCommit,
+	AssetMetaDatabaseIndex,
+	CommentDatabaseIndex,
 	ReloadAttributeView,
 	ReloadProtyle,
 	ReloadTag,
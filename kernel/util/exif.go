@@ -0,0 +1,347 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+const (
+	ExifTagMake             = 0x010F
+	ExifTagModel            = 0x0110
+	ExifTagOrientation      = 0x0112
+	ExifTagDateTime         = 0x0132
+	ExifTagExifIFD          = 0x8769
+	ExifTagGPSIFD           = 0x8825
+	ExifTagDateTimeOriginal = 0x9003
+	ExifTagGPSLatitudeRef   = 0x0001
+	ExifTagGPSLatitude      = 0x0002
+	ExifTagGPSLongitudeRef  = 0x0003
+	ExifTagGPSLongitude     = 0x0004
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// ExifEntry 描述 EXIF（TIFF）IFD 中的一个条目。
+type ExifEntry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	Value int // 条目值字段在 EXIF 数据中的偏移
+}
+
+// size 返回条目数据的字节数，小于等于 4 时数据直接存放在值字段中。
+func (entry *ExifEntry) size() int {
+	typeSizes := map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+	return typeSizes[entry.Type] * int(entry.Count)
+}
+
+// JPEGExif 返回 JPEG 数据中 APP1 段的 EXIF 数据，不包含 Exif 头。
+func JPEGExif(data []byte) []byte {
+	return jpegSegment(data, 0xE1, exifHeader)
+}
+
+// JPEGComment 返回 JPEG 数据中第一个以 prefix 开头的 COM 段数据。
+func JPEGComment(data, prefix []byte) []byte {
+	return jpegSegment(data, 0xFE, prefix)
+}
+
+// jpegSegment 返回 JPEG 数据中第一个以 prefix 开头的指定标记段数据，不包含 prefix。
+func jpegSegment(data []byte, segMarker byte, prefix []byte) []byte {
+	if 4 > len(data) || 0xFF != data[0] || 0xD8 != data[1] {
+		return nil
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if 0xFF != data[i] {
+			return nil
+		}
+		marker := data[i+1]
+		if 0xFF == marker || 0x01 == marker || (0xD0 <= marker && 0xD7 >= marker) {
+			i++
+			if 0xFF != marker {
+				i++
+			}
+			continue
+		}
+		if 0xDA == marker || 0xD9 == marker { // 图像数据开始后不再有元数据段
+			return nil
+		}
+
+		segLen := int(binary.BigEndian.Uint16(data[i+2:]))
+		if 2 > segLen || i+2+segLen > len(data) {
+			return nil
+		}
+		seg := data[i+4 : i+2+segLen]
+		if segMarker == marker && bytes.HasPrefix(seg, prefix) {
+			return seg[len(prefix):]
+		}
+		i += 2 + segLen
+	}
+	return nil
+}
+
+// InsertJPEGExif 在 JPEG 数据的 SOI 之后插入 EXIF 数据。
+func InsertJPEGExif(data, exif []byte) []byte {
+	return insertJPEGSegment(data, 0xE1, append(bytes.Clone(exifHeader), exif...))
+}
+
+// InsertJPEGComment 在 JPEG 数据的 SOI 之后插入 COM 段。
+func InsertJPEGComment(data, comment []byte) []byte {
+	return insertJPEGSegment(data, 0xFE, comment)
+}
+
+func insertJPEGSegment(data []byte, marker byte, seg []byte) []byte {
+	segLen := 2 + len(seg)
+	if 0xFFFF < segLen || 2 > len(data) || 0xFF != data[0] || 0xD8 != data[1] {
+		return data
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)+segLen+2))
+	buf.Write(data[:2])
+	buf.Write([]byte{0xFF, marker, byte(segLen >> 8), byte(segLen)})
+	buf.Write(seg)
+	buf.Write(data[2:])
+	return buf.Bytes()
+}
+
+// ExifByteOrder 解析 TIFF 头，返回字节序和 IFD0 的偏移。
+func ExifByteOrder(exif []byte) (order binary.ByteOrder, ifd0 int, ok bool) {
+	if 8 > len(exif) {
+		return
+	}
+	switch string(exif[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+	if 42 != order.Uint16(exif[2:]) {
+		return
+	}
+	ifd0 = int(order.Uint32(exif[4:]))
+	ok = 0 < ifd0 && ifd0 < len(exif)
+	return
+}
+
+// ExifIFDEntries 返回偏移 offset 处 IFD 中的条目。
+func ExifIFDEntries(exif []byte, order binary.ByteOrder, offset int) (ret []*ExifEntry) {
+	if 0 >= offset || offset+2 > len(exif) {
+		return
+	}
+
+	count := int(order.Uint16(exif[offset:]))
+	for i := 0; i < count; i++ {
+		p := offset + 2 + i*12
+		if p+12 > len(exif) {
+			return
+		}
+		ret = append(ret, &ExifEntry{
+			Tag:   order.Uint16(exif[p:]),
+			Type:  order.Uint16(exif[p+2:]),
+			Count: order.Uint32(exif[p+4:]),
+			Value: p + 8,
+		})
+	}
+	return
+}
+
+// ExifEntryData 返回条目的数据，数据越界时返回 nil。
+func ExifEntryData(exif []byte, order binary.ByteOrder, entry *ExifEntry) []byte {
+	size := entry.size()
+	if 0 >= size {
+		return nil
+	}
+	offset := entry.Value
+	if 4 < size {
+		offset = int(order.Uint32(exif[entry.Value:]))
+	}
+	if 0 > offset || offset+size > len(exif) {
+		return nil
+	}
+	return exif[offset : offset+size]
+}
+
+// StripExifGPS 原地清除 EXIF 数据中的 GPS 信息，返回是否存在 GPS 信息。
+//
+// GPS IFD 的条目数据会被清零，条目数量置为 0，其他 EXIF 信息保持不变。
+func StripExifGPS(exif []byte) (stripped bool) {
+	order, ifd0, ok := ExifByteOrder(exif)
+	if !ok {
+		return
+	}
+
+	for _, entry := range ExifIFDEntries(exif, order, ifd0) {
+		if ExifTagGPSIFD != entry.Tag {
+			continue
+		}
+
+		gpsIFD := int(order.Uint32(exif[entry.Value:]))
+		gpsEntries := ExifIFDEntries(exif, order, gpsIFD)
+		for _, gpsEntry := range gpsEntries {
+			if data := ExifEntryData(exif, order, gpsEntry); nil != data {
+				clear(data)
+			}
+			clear(exif[gpsEntry.Value : gpsEntry.Value+4])
+		}
+		if 0 < len(gpsEntries) {
+			order.PutUint16(exif[gpsIFD:], 0)
+			stripped = true
+		}
+	}
+	return
+}
+
+// ResetExifOrientation 原地将 EXIF 数据中的方向置为正常，用于像素已经按方向旋转过的图片。
+func ResetExifOrientation(exif []byte) {
+	order, ifd0, ok := ExifByteOrder(exif)
+	if !ok {
+		return
+	}
+
+	for _, entry := range ExifIFDEntries(exif, order, ifd0) {
+		if ExifTagOrientation == entry.Tag && 3 == entry.Type {
+			order.PutUint16(exif[entry.Value:], 1)
+		}
+	}
+}
+
+// ExifInfo 描述从 EXIF 数据中提取的常用信息。
+type ExifInfo struct {
+	Taken  time.Time // 拍摄时间，优先使用 DateTimeOriginal
+	Make   string
+	Model  string
+	HasGPS bool
+	Lat    float64 // 纬度，南纬为负
+	Lng    float64 // 经度，西经为负
+}
+
+// ParseExif 解析 EXIF 数据，数据不合法时返回 nil。
+func ParseExif(exif []byte) (ret *ExifInfo) {
+	order, ifd0, ok := ExifByteOrder(exif)
+	if !ok {
+		return
+	}
+
+	ret = &ExifInfo{}
+	var dateTime, dateTimeOriginal string
+	for _, entry := range ExifIFDEntries(exif, order, ifd0) {
+		switch entry.Tag {
+		case ExifTagMake:
+			ret.Make = exifString(ExifEntryData(exif, order, entry))
+		case ExifTagModel:
+			ret.Model = exifString(ExifEntryData(exif, order, entry))
+		case ExifTagDateTime:
+			dateTime = exifString(ExifEntryData(exif, order, entry))
+		case ExifTagExifIFD:
+			for _, exifEntry := range ExifIFDEntries(exif, order, int(order.Uint32(exif[entry.Value:]))) {
+				if ExifTagDateTimeOriginal == exifEntry.Tag {
+					dateTimeOriginal = exifString(ExifEntryData(exif, order, exifEntry))
+				}
+			}
+		case ExifTagGPSIFD:
+			var latRef, lngRef string
+			var lat, lng []float64
+			for _, gpsEntry := range ExifIFDEntries(exif, order, int(order.Uint32(exif[entry.Value:]))) {
+				data := ExifEntryData(exif, order, gpsEntry)
+				switch gpsEntry.Tag {
+				case ExifTagGPSLatitudeRef:
+					latRef = exifString(data)
+				case ExifTagGPSLatitude:
+					lat = exifRationals(data, order)
+				case ExifTagGPSLongitudeRef:
+					lngRef = exifString(data)
+				case ExifTagGPSLongitude:
+					lng = exifRationals(data, order)
+				}
+			}
+			if 3 == len(lat) && 3 == len(lng) {
+				ret.HasGPS = true
+				ret.Lat = lat[0] + lat[1]/60 + lat[2]/3600
+				ret.Lng = lng[0] + lng[1]/60 + lng[2]/3600
+				if "S" == latRef {
+					ret.Lat = -ret.Lat
+				}
+				if "W" == lngRef {
+					ret.Lng = -ret.Lng
+				}
+			}
+		}
+	}
+
+	if "" == dateTimeOriginal {
+		dateTimeOriginal = dateTime
+	}
+	if taken, err := time.ParseInLocation("2006:01:02 15:04:05", dateTimeOriginal, time.Local); nil == err {
+		ret.Taken = taken
+	}
+	return
+}
+
+// ImageExif 返回图片数据中的 EXIF 数据，支持 JPEG、TIFF、PNG 和 WebP。
+func ImageExif(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return JPEGExif(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		return data
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		for i := 8; i+8 <= len(data); {
+			chunkLen := int(binary.BigEndian.Uint32(data[i:]))
+			if 0 > chunkLen || i+12+chunkLen > len(data) {
+				return nil
+			}
+			if "eXIf" == string(data[i+4:i+8]) {
+				return data[i+8 : i+8+chunkLen]
+			}
+			i += 12 + chunkLen
+		}
+	case bytes.HasPrefix(data, []byte("RIFF")) && 12 <= len(data) && "WEBP" == string(data[8:12]):
+		for i := 12; i+8 <= len(data); {
+			chunkLen := int(binary.LittleEndian.Uint32(data[i+4:]))
+			if 0 > chunkLen || i+8+chunkLen > len(data) {
+				return nil
+			}
+			if "EXIF" == string(data[i:i+4]) {
+				return bytes.TrimPrefix(data[i+8:i+8+chunkLen], exifHeader)
+			}
+			i += 8 + chunkLen + chunkLen%2
+		}
+	}
+	return nil
+}
+
+func exifString(data []byte) string {
+	return strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+}
+
+func exifRationals(data []byte, order binary.ByteOrder) (ret []float64) {
+	for i := 0; i+8 <= len(data); i += 8 {
+		num, den := order.Uint32(data[i:]), order.Uint32(data[i+4:])
+		if 0 == den {
+			ret = append(ret, 0)
+			continue
+		}
+		ret = append(ret, float64(num)/float64(den))
+	}
+	return
+}
@@ -0,0 +1,189 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+type testByteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type testExifEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func testExifASCII(tag uint16, s string) *testExifEntry {
+	return &testExifEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+func testExifRationals(tag uint16, order testByteOrder, values ...uint32) *testExifEntry {
+	var data []byte
+	for _, v := range values {
+		data = order.AppendUint32(data, v)
+		data = order.AppendUint32(data, 1)
+	}
+	return &testExifEntry{tag: tag, typ: 5, count: uint32(len(values)), data: data}
+}
+
+// buildTestExif 构造一个 TIFF 格式的 EXIF 数据，gps 不为空时在 IFD0 中添加 GPS IFD 指针。
+func buildTestExif(order testByteOrder, ifd0, gps []*testExifEntry) (ret []byte) {
+	ifdSize := func(entries []*testExifEntry) (size int) {
+		size = 2 + 12*len(entries) + 4
+		for _, e := range entries {
+			if 4 < len(e.data) {
+				size += len(e.data)
+			}
+		}
+		return
+	}
+	writeIFD := func(entries []*testExifEntry) {
+		start := len(ret)
+		dataOffset := start + 2 + 12*len(entries) + 4
+		var dataArea []byte
+		ret = order.AppendUint16(ret, uint16(len(entries)))
+		for _, e := range entries {
+			ret = order.AppendUint16(ret, e.tag)
+			ret = order.AppendUint16(ret, e.typ)
+			ret = order.AppendUint32(ret, e.count)
+			if 4 < len(e.data) {
+				ret = order.AppendUint32(ret, uint32(dataOffset+len(dataArea)))
+				dataArea = append(dataArea, e.data...)
+			} else {
+				value := make([]byte, 4)
+				copy(value, e.data)
+				ret = append(ret, value...)
+			}
+		}
+		ret = order.AppendUint32(ret, 0)
+		ret = append(ret, dataArea...)
+	}
+
+	if binary.LittleEndian == order {
+		ret = []byte("II*\x00")
+	} else {
+		ret = []byte("MM\x00*")
+	}
+	ret = order.AppendUint32(ret, 8)
+	if 0 < len(gps) {
+		pointer := &testExifEntry{tag: ExifTagGPSIFD, typ: 4, count: 1}
+		ifd0 = append(ifd0, pointer)
+		pointer.data = order.AppendUint32(nil, uint32(8+ifdSize(ifd0)))
+	}
+	writeIFD(ifd0)
+	if 0 < len(gps) {
+		writeIFD(gps)
+	}
+	return
+}
+
+func TestParseExif(t *testing.T) {
+	for _, order := range []testByteOrder{binary.LittleEndian, binary.BigEndian} {
+		exif := buildTestExif(order, []*testExifEntry{
+			testExifASCII(ExifTagMake, "Canon"),
+			testExifASCII(ExifTagModel, "EOS R5"),
+			testExifASCII(ExifTagDateTime, "2024:05:06 07:08:09"),
+		}, []*testExifEntry{
+			testExifASCII(ExifTagGPSLatitudeRef, "N"),
+			testExifRationals(ExifTagGPSLatitude, order, 30, 15, 0),
+			testExifASCII(ExifTagGPSLongitudeRef, "W"),
+			testExifRationals(ExifTagGPSLongitude, order, 120, 30, 36),
+		})
+
+		info := ParseExif(exif)
+		if nil == info {
+			t.Fatalf("parse exif [%s] failed", order)
+		}
+		if "Canon" != info.Make || "EOS R5" != info.Model {
+			t.Fatalf("unexpected camera [%s, %s]", info.Make, info.Model)
+		}
+		if expected := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local); !expected.Equal(info.Taken) {
+			t.Fatalf("unexpected taken time [%s]", info.Taken)
+		}
+		if !info.HasGPS || 1e-9 < math.Abs(30.25-info.Lat) || 1e-9 < math.Abs(-120.51-info.Lng) {
+			t.Fatalf("unexpected GPS [%v, %f, %f]", info.HasGPS, info.Lat, info.Lng)
+		}
+
+		if !StripExifGPS(exif) {
+			t.Fatalf("strip GPS [%s] failed", order)
+		}
+		if info = ParseExif(exif); nil == info || info.HasGPS || "Canon" != info.Make {
+			t.Fatalf("unexpected exif after stripping GPS [%+v]", info)
+		}
+		if StripExifGPS(exif) {
+			t.Fatalf("expected no GPS to strip")
+		}
+	}
+}
+
+func TestParseExifInvalid(t *testing.T) {
+	cases := [][]byte{
+		nil,
+		[]byte("II"),
+		[]byte("XX*\x00\x08\x00\x00\x00"),
+		[]byte("II+\x00\x08\x00\x00\x00"),
+		[]byte("II*\x00\xFF\x00\x00\x00"),
+	}
+	for i, exif := range cases {
+		if info := ParseExif(exif); nil != info {
+			t.Fatalf("case [%d] expected nil, got [%+v]", i, info)
+		}
+	}
+
+	// IFD 条目越界时只忽略越界的部分
+	exif := buildTestExif(binary.LittleEndian, []*testExifEntry{testExifASCII(ExifTagMake, "Canon")}, nil)
+	binary.LittleEndian.PutUint16(exif[8:], 100)
+	if info := ParseExif(exif); nil == info || "Canon" != info.Make {
+		t.Fatalf("unexpected exif [%+v]", info)
+	}
+}
+
+func TestJPEGSegments(t *testing.T) {
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9}
+	if nil != JPEGExif(jpeg) || nil != JPEGComment(jpeg, []byte("x")) {
+		t.Fatalf("expected no segments")
+	}
+
+	exif := buildTestExif(binary.BigEndian, []*testExifEntry{testExifASCII(ExifTagMake, "Sony")}, nil)
+	data := InsertJPEGComment(jpeg, []byte("SiYuan optimized"))
+	data = InsertJPEGExif(data, exif)
+	if got := JPEGExif(data); string(exif) != string(got) {
+		t.Fatalf("unexpected exif [%x]", got)
+	}
+	if got := ImageExif(data); string(exif) != string(got) {
+		t.Fatalf("unexpected image exif [%x]", got)
+	}
+	if nil == JPEGComment(data, []byte("SiYuan optimized")) || nil != JPEGComment(data, []byte("other")) {
+		t.Fatalf("unexpected comment")
+	}
+	if info := ParseExif(ImageExif(data)); nil == info || "Sony" != info.Make {
+		t.Fatalf("unexpected exif [%+v]", info)
+	}
+
+	// 不是 JPEG 时不插入
+	if got := InsertJPEGExif([]byte("PNG"), exif); "PNG" != string(got) {
+		t.Fatalf("unexpected data [%x]", got)
+	}
+}
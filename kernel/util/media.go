@@ -0,0 +1,290 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MediaDuration 返回音视频文件的时长（秒），无法解析时返回 0。
+//
+// 支持 MP4/MOV 系列、WAV、FLAC、MP3 和 Ogg（Vorbis/Opus），只读取文件头尾的少量数据。
+func MediaDuration(absPath string) (ret float64) {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(absPath)) {
+	case ".mp4", ".m4a", ".m4v", ".mov", ".3gp":
+		return mp4Duration(f, info.Size())
+	case ".wav":
+		return wavDuration(f)
+	case ".flac":
+		return flacDuration(f)
+	case ".mp3":
+		return mp3Duration(f, info.Size())
+	case ".ogg", ".oga", ".opus":
+		return oggDuration(f, info.Size())
+	}
+	return
+}
+
+func mp4Duration(f *os.File, size int64) float64 {
+	// 在顶层 box 中找到 moov，再在 moov 中找到 mvhd
+	moovStart, moovEnd, ok := findMP4Box(f, 0, size, "moov")
+	if !ok {
+		return 0
+	}
+	mvhdStart, _, ok := findMP4Box(f, moovStart, moovEnd, "mvhd")
+	if !ok {
+		return 0
+	}
+
+	buf := make([]byte, 32)
+	if _, err := f.ReadAt(buf, mvhdStart); err != nil {
+		return 0
+	}
+	var timescale uint32
+	var duration uint64
+	if 1 == buf[0] {
+		timescale = binary.BigEndian.Uint32(buf[20:])
+		duration = binary.BigEndian.Uint64(buf[24:])
+	} else {
+		timescale = binary.BigEndian.Uint32(buf[12:])
+		duration = uint64(binary.BigEndian.Uint32(buf[16:]))
+	}
+	if 0 == timescale {
+		return 0
+	}
+	return float64(duration) / float64(timescale)
+}
+
+// findMP4Box 在 [start, end) 范围内查找指定类型的 box，返回 box 内容的起止偏移。
+func findMP4Box(f *os.File, start, end int64, typ string) (contentStart, contentEnd int64, ok bool) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = end - offset
+		case 1:
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+		if boxSize < headerSize {
+			return
+		}
+
+		if typ == string(header[4:8]) {
+			return offset + headerSize, offset + boxSize, true
+		}
+		offset += boxSize
+	}
+	return
+}
+
+func wavDuration(f *os.File) float64 {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil || "RIFF" != string(header[:4]) || "WAVE" != string(header[8:]) {
+		return 0
+	}
+
+	var byteRate uint32
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(f, chunk); err != nil {
+			return 0
+		}
+		chunkSize := binary.LittleEndian.Uint32(chunk[4:])
+		switch string(chunk[:4]) {
+		case "fmt ":
+			// 只读取 fmt 块中 byteRate 之前的 12 个字节，块大小来自文件本身，不能按它分配内存
+			if 12 > chunkSize {
+				return 0
+			}
+			fmtData := make([]byte, 12)
+			if _, err := io.ReadFull(f, fmtData); err != nil {
+				return 0
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtData[8:])
+			if _, err := f.Seek(int64(chunkSize-12+chunkSize%2), io.SeekCurrent); err != nil {
+				return 0
+			}
+			continue
+		case "data":
+			if 0 == byteRate {
+				return 0
+			}
+			return float64(chunkSize) / float64(byteRate)
+		}
+		if _, err := f.Seek(int64(chunkSize+chunkSize%2), io.SeekCurrent); err != nil {
+			return 0
+		}
+	}
+}
+
+func flacDuration(f *os.File) float64 {
+	// fLaC 之后的第一个元数据块是 STREAMINFO
+	buf := make([]byte, 4+4+34)
+	if _, err := io.ReadFull(f, buf); err != nil || "fLaC" != string(buf[:4]) || 0 != buf[4]&0x7F {
+		return 0
+	}
+
+	info := buf[8:]
+	sampleRate := uint64(info[10])<<12 | uint64(info[11])<<4 | uint64(info[12])>>4
+	totalSamples := uint64(info[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(info[14:]))
+	if 0 == sampleRate {
+		return 0
+	}
+	return float64(totalSamples) / float64(sampleRate)
+}
+
+var (
+	mp3BitratesV1 = []int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mp3BitratesV2 = []int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+	mp3Samplerate = []int{44100, 48000, 32000}
+)
+
+func mp3Duration(f *os.File, size int64) float64 {
+	buf := make([]byte, 64*1024)
+	n, _ := io.ReadFull(f, buf)
+	buf = buf[:n]
+
+	// 跳过 ID3v2 标签
+	audioStart := int64(0)
+	if 10 <= len(buf) && "ID3" == string(buf[:3]) {
+		tagSize := int64(buf[6]&0x7F)<<21 | int64(buf[7]&0x7F)<<14 | int64(buf[8]&0x7F)<<7 | int64(buf[9]&0x7F)
+		audioStart = 10 + tagSize
+		if 0 != buf[5]&0x10 {
+			audioStart += 10
+		}
+		if audioStart >= size {
+			return 0
+		}
+		buf = make([]byte, 64*1024)
+		n, _ = f.ReadAt(buf, audioStart)
+		buf = buf[:n]
+	}
+
+	for i := 0; i+4 <= len(buf); i++ {
+		if 0xFF != buf[i] || 0xE0 != buf[i+1]&0xE0 {
+			continue
+		}
+
+		version := (buf[i+1] >> 3) & 0x03 // 3：MPEG1，2：MPEG2，0：MPEG2.5
+		layer := (buf[i+1] >> 1) & 0x03   // 1：Layer III
+		bitrateIndex := int(buf[i+2] >> 4)
+		samplerateIndex := int((buf[i+2] >> 2) & 0x03)
+		if 1 == version || 1 != layer || 0 == bitrateIndex || 15 == bitrateIndex || 3 == samplerateIndex {
+			continue
+		}
+
+		sampleRate := mp3Samplerate[samplerateIndex]
+		bitrate := mp3BitratesV1[bitrateIndex]
+		samplesPerFrame := 1152
+		sideInfo := 32
+		mono := 3 == buf[i+3]>>6
+		if mono {
+			sideInfo = 17
+		}
+		if 3 != version {
+			sampleRate /= 2
+			if 0 == version {
+				sampleRate /= 2
+			}
+			bitrate = mp3BitratesV2[bitrateIndex]
+			samplesPerFrame = 576
+			sideInfo = 17
+			if mono {
+				sideInfo = 9
+			}
+		}
+
+		// VBR 文件的第一帧中带有 Xing/Info 头，记录了总帧数
+		xing := i + 4 + sideInfo
+		if xing+12 <= len(buf) && (bytes.Equal(buf[xing:xing+4], []byte("Xing")) || bytes.Equal(buf[xing:xing+4], []byte("Info"))) {
+			if flags := binary.BigEndian.Uint32(buf[xing+4:]); 0 != flags&0x01 {
+				frames := binary.BigEndian.Uint32(buf[xing+8:])
+				return float64(frames) * float64(samplesPerFrame) / float64(sampleRate)
+			}
+		}
+		return float64(size-audioStart-int64(i)) * 8 / float64(bitrate*1000)
+	}
+	return 0
+}
+
+func oggDuration(f *os.File, size int64) float64 {
+	head := make([]byte, 64)
+	if _, err := io.ReadFull(f, head); err != nil || "OggS" != string(head[:4]) {
+		return 0
+	}
+
+	// 第一页的数据是编码标识头，Opus 的粒度位置固定为 48kHz
+	if 27+int(head[26]) >= len(head) {
+		return 0
+	}
+	packet := head[27+int(head[26]):]
+	sampleRate := uint32(0)
+	preSkip := uint64(0)
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && 16 <= len(packet):
+		sampleRate = binary.LittleEndian.Uint32(packet[12:])
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && 12 <= len(packet):
+		sampleRate = 48000
+		preSkip = uint64(binary.LittleEndian.Uint16(packet[10:]))
+	}
+	if 0 == sampleRate {
+		return 0
+	}
+
+	tailSize := int64(64 * 1024)
+	if tailSize > size {
+		tailSize = size
+	}
+	tail := make([]byte, tailSize)
+	if _, err := f.ReadAt(tail, size-tailSize); err != nil {
+		return 0
+	}
+	idx := bytes.LastIndex(tail, []byte("OggS"))
+	if -1 == idx || idx+14 > len(tail) {
+		return 0
+	}
+	granule := binary.LittleEndian.Uint64(tail[idx+6:])
+	if granule < preSkip {
+		return 0
+	}
+	return float64(granule-preSkip) / float64(sampleRate)
+}
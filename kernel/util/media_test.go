@@ -0,0 +1,155 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestMedia(t *testing.T, name string, data []byte) string {
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		t.Fatalf("write test media failed: %s", err)
+	}
+	return p
+}
+
+func testWAV(fmtSize uint32, extraChunk bool, dataSize uint32) (ret []byte) {
+	ret = append([]byte("RIFF"), 0, 0, 0, 0)
+	ret = append(ret, "WAVE"...)
+	ret = append(ret, "fmt "...)
+	ret = binary.LittleEndian.AppendUint32(ret, fmtSize)
+	fmtData := make([]byte, 16)
+	binary.LittleEndian.PutUint16(fmtData[0:], 1)      // PCM
+	binary.LittleEndian.PutUint16(fmtData[2:], 2)      // 声道数
+	binary.LittleEndian.PutUint32(fmtData[4:], 44100)  // 采样率
+	binary.LittleEndian.PutUint32(fmtData[8:], 176400) // byteRate
+	ret = append(ret, fmtData...)
+	if 16 < fmtSize && 1<<20 > fmtSize {
+		ret = append(ret, make([]byte, fmtSize-16+fmtSize%2)...)
+	}
+	if extraChunk {
+		ret = append(ret, "LIST"...)
+		ret = binary.LittleEndian.AppendUint32(ret, 3)
+		ret = append(ret, "abc\x00"...)
+	}
+	ret = append(ret, "data"...)
+	ret = binary.LittleEndian.AppendUint32(ret, dataSize)
+	return
+}
+
+func testFLAC(sampleRate uint32, totalSamples uint64) (ret []byte) {
+	ret = append([]byte("fLaC"), 0x80, 0, 0, 34)
+	info := make([]byte, 34)
+	info[10] = byte(sampleRate >> 12)
+	info[11] = byte(sampleRate >> 4)
+	info[12] = byte(sampleRate<<4) | 0x02
+	info[13] = 0xF0 | byte(totalSamples>>32)
+	binary.BigEndian.PutUint32(info[14:], uint32(totalSamples))
+	return append(ret, info...)
+}
+
+func testMP3(size int, xingFrames uint32) (ret []byte) {
+	ret = make([]byte, size)
+	// ID3v2 标签
+	copy(ret, []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 10})
+	frame := ret[20:]
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00}) // MPEG1 Layer III 128kbps 44100Hz
+	if 0 < xingFrames {
+		copy(frame[4+32:], "Xing")
+		binary.BigEndian.PutUint32(frame[4+32+4:], 1)
+		binary.BigEndian.PutUint32(frame[4+32+8:], xingFrames)
+	}
+	return
+}
+
+func testOgg(head []byte, granule uint64) (ret []byte) {
+	page := func(packet []byte, granule uint64) (p []byte) {
+		p = append([]byte("OggS"), 0, 0)
+		p = binary.LittleEndian.AppendUint64(p, granule)
+		p = append(p, make([]byte, 12)...)
+		p = append(p, 1, byte(len(packet)))
+		return append(p, packet...)
+	}
+	ret = page(head, 0)
+	ret = append(ret, make([]byte, 128)...)
+	return append(ret, page([]byte("audio"), granule)...)
+}
+
+func testMP4(version byte, timescale uint32, duration uint64) (ret []byte) {
+	box := func(typ string, content []byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+		return append(append(b, typ...), content...)
+	}
+
+	mvhd := []byte{version, 0, 0, 0}
+	if 1 == version {
+		mvhd = append(mvhd, make([]byte, 16)...)
+		mvhd = binary.BigEndian.AppendUint32(mvhd, timescale)
+		mvhd = binary.BigEndian.AppendUint64(mvhd, duration)
+	} else {
+		mvhd = append(mvhd, make([]byte, 8)...)
+		mvhd = binary.BigEndian.AppendUint32(mvhd, timescale)
+		mvhd = binary.BigEndian.AppendUint32(mvhd, uint32(duration))
+	}
+	mvhd = append(mvhd, make([]byte, 80)...)
+
+	ret = box("ftyp", []byte("isom\x00\x00\x02\x00"))
+	ret = append(ret, box("free", nil)...)
+	return append(ret, box("moov", append(box("trak", nil), box("mvhd", mvhd)...))...)
+}
+
+func TestMediaDuration(t *testing.T) {
+	vorbisHead := append([]byte("\x01vorbis\x00\x00\x00\x00\x02"), binary.LittleEndian.AppendUint32(nil, 44100)...)
+	vorbisHead = append(vorbisHead, make([]byte, 14)...)
+	opusHead := append([]byte("OpusHead\x01\x02"), binary.LittleEndian.AppendUint16(nil, 312)...)
+	opusHead = append(opusHead, make([]byte, 8)...)
+
+	cases := []struct {
+		name     string
+		data     []byte
+		expected float64
+	}{
+		{"a.wav", testWAV(16, false, 352800), 2},
+		{"b.wav", testWAV(18, true, 176400), 1},
+		{"huge-fmt.wav", testWAV(0xFFFFFFF0, false, 176400), 0},
+		{"short-fmt.wav", testWAV(8, false, 176400), 0},
+		{"a.flac", testFLAC(44100, 88200), 2},
+		{"cbr.mp3", testMP3(16020, 0), 1},
+		{"vbr.mp3", testMP3(4096, 100), 100 * 1152.0 / 44100},
+		{"a.ogg", testOgg(vorbisHead, 88200), 2},
+		{"a.opus", testOgg(opusHead, 96312), 2},
+		{"a.mp4", testMP4(0, 1000, 2500), 2.5},
+		{"a.m4a", testMP4(1, 600, 1800), 3},
+		{"invalid.mp4", []byte("not a video"), 0},
+		{"a.txt", testWAV(16, false, 352800), 0},
+	}
+	for _, c := range cases {
+		got := MediaDuration(writeTestMedia(t, c.name, c.data))
+		if 1e-6 < math.Abs(c.expected-got) {
+			t.Errorf("case [%s] expected [%f], got [%f]", c.name, c.expected, got)
+		}
+	}
+
+	if got := MediaDuration(filepath.Join(t.TempDir(), "missing.wav")); 0 != got {
+		t.Errorf("expected 0 for missing file, got [%f]", got)
+	}
+}
@@ -88,6 +88,15 @@ func IsOnline(checkURL string, skipTlsVerify bool, timeout int) bool {
 		_, err := os.Stat(filePath)
 		return err == nil
 	}
+	if u.Scheme == "sftp" {
+		conn, err := net.DialTimeout("tcp", u.Host, time.Duration(timeout)*time.Millisecond)
+		if err != nil {
+			logging.LogWarnf("network is offline [checkURL=%s]", checkURL)
+			return false
+		}
+		conn.Close()
+		return true
+	}
 
 	if isOnline(checkURL, skipTlsVerify, timeout) {
 		return true
@@ -18,6 +18,8 @@ package util
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -88,6 +90,60 @@ func ChatGPT(msg string, contextMsgs []string, c *openai.Client, model string, m
 	return
 }
 
+// ChatGPTStream 以流式方式请求对话补全，每收到一段增量内容就调用 onDelta，onDelta 返回 false 时中止请求。
+func ChatGPTStream(reqMsgs []openai.ChatCompletionMessage, c *openai.Client, model string, maxTokens int, temperature float64, timeout int, onDelta func(delta string) bool) (ret string, err error) {
+	if 1 > len(reqMsgs) {
+		return
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:       model,
+		MaxTokens:   maxTokens,
+		Temperature: float32(temperature),
+		Messages:    reqMsgs,
+	}
+	// 流式请求的超时时间只限制等待首个响应的时间，后续内容持续返回时不会中断
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	timer := time.AfterFunc(time.Duration(timeout)*time.Second, cancel)
+	stream, err := c.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		timer.Stop()
+		logging.LogErrorf("create chat completion stream failed: %s", err)
+		return
+	}
+	defer stream.Close()
+
+	buf := &strings.Builder{}
+	for {
+		resp, recvErr := stream.Recv()
+		timer.Stop()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if nil != recvErr {
+			logging.LogErrorf("receive chat completion stream failed: %s", recvErr)
+			err = recvErr
+			break
+		}
+		if 1 > len(resp.Choices) {
+			continue
+		}
+
+		delta := resp.Choices[0].Delta.Content
+		if "" == delta {
+			continue
+		}
+		buf.WriteString(delta)
+		if !onDelta(delta) {
+			break
+		}
+	}
+
+	ret = strings.TrimSpace(buf.String())
+	return
+}
+
 func NewOpenAIClient(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, apiProvider string) *openai.Client {
 	config := openai.DefaultConfig(apiKey)
 	if "Azure" == apiProvider {
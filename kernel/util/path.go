@@ -231,6 +231,14 @@ func NormalizeConcurrentReqs(concurrentReqs int, provider int) int {
 			concurrentReqs = 1024
 		default:
 		}
+	case 5: // SFTP
+		switch {
+		case concurrentReqs < 1:
+			concurrentReqs = 4
+		case concurrentReqs > 16:
+			concurrentReqs = 16
+		default:
+		}
 	}
 	return concurrentReqs
 }